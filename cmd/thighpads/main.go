@@ -6,7 +6,9 @@ import (
 	"os"
 	"strings"
 
+	"github.com/s42yt/thighpads/pkg/api"
 	"github.com/s42yt/thighpads/pkg/app"
+	"github.com/s42yt/thighpads/pkg/config"
 )
 
 func main() {
@@ -17,8 +19,54 @@ func main() {
 	uninstall := flag.Bool("uninstall", false, "Uninstall ThighPads from your system")
 	checkUpdate := flag.Bool("check-update", false, "Check for updates")
 	update := flag.Bool("update", false, "Update ThighPads to the latest version")
+	migrateDryRun := flag.Bool("migrate-dry-run", false, "Show pending database migrations without applying them")
+	syncNow := flag.Bool("sync", false, "Sync with your other devices through the shared folder and exit")
+	syncFolder := flag.String("sync-folder", "", "Set the shared folder used for sync")
+	storage := flag.String("storage", "", "Move your data to another storage backend: git or sqlite")
 	flag.Parse()
 
+	// thighpads sync [--remote URL] is the same as --sync [--sync-folder DIR]
+	syncServer, syncToken := "", ""
+	if flag.Arg(0) == "sync" {
+		syncFlags := flag.NewFlagSet("sync", flag.ExitOnError)
+		remote := syncFlags.String("remote", "", "Set the git remote, or the shared folder without git storage")
+		server := syncFlags.String("server", "", "Sync through the sync server at this URL")
+		token := syncFlags.String("token", "", "Token of the sync server")
+		syncFlags.Parse(flag.Args()[1:])
+
+		*syncNow = true
+		if *remote != "" {
+			*syncFolder = *remote
+		}
+		syncServer, syncToken = *server, *token
+	}
+
+	if flag.Arg(0) == "api" {
+		apiFlags := flag.NewFlagSet("api", flag.ExitOnError)
+		listen := apiFlags.String("listen", api.DefaultAddress, "Address to listen on")
+		token := apiFlags.String("token", os.Getenv("THIGHPADS_API_TOKEN"), "Token clients need, generated if empty")
+		apiFlags.Parse(flag.Args()[1:])
+
+		if err := runAPI(*listen, *token); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to serve the API: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if flag.Arg(0) == "serve" {
+		serveFlags := flag.NewFlagSet("serve", flag.ExitOnError)
+		listen := serveFlags.String("listen", config.DefaultServerAddress, "Address to listen on")
+		token := serveFlags.String("token", os.Getenv("THIGHPADS_TOKEN"), "Token clients need, generated if empty")
+		serveFlags.Parse(flag.Args()[1:])
+
+		if err := runServe(*listen, *token); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to serve: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	if *uninstall {
 		fmt.Println("Uninstalling ThighPads...")
 		if err := uninstallGlobal(); err != nil {
@@ -59,6 +107,30 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *migrateDryRun {
+		if err := showPendingMigrations(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to check migrations: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *storage != "" {
+		if err := switchStorage(*storage); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to switch storage: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *syncNow || *syncFolder != "" {
+		if err := runSync(*syncFolder, syncServer, syncToken); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to sync: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	if *checkUpdate || *update {
 		fmt.Println("Checking for updates...")
 		hasUpdate, newVersion, downloadURL, err := checkForUpdates(true)
@@ -2,10 +2,14 @@ package main
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 
+	"github.com/s42yt/thighpads/pkg/api"
 	"github.com/s42yt/thighpads/pkg/config"
+	"github.com/s42yt/thighpads/pkg/database"
+	"github.com/s42yt/thighpads/pkg/syncer"
 )
 
 func version() error {
@@ -32,3 +36,154 @@ func isFirstRun() bool {
 	_, err = os.Stat(configFile)
 	return os.IsNotExist(err)
 }
+
+func showPendingMigrations() error {
+	if _, err := config.EnsureConfigFolderExists(); err != nil {
+		return err
+	}
+
+	plan, err := database.DryRunMigrations()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Storage backend: %s\n", plan.Backend)
+	fmt.Printf("Schema version: %d (latest: %d)\n", plan.CurrentVersion, plan.TargetVersion)
+
+	if len(plan.Pending) == 0 {
+		fmt.Println("Database is up to date.")
+		return nil
+	}
+
+	fmt.Println("Pending migrations:")
+	for _, m := range plan.Pending {
+		fmt.Printf("  %d. %s\n", m.Version, m.Description)
+	}
+	return nil
+}
+
+// runSync sets the shared folder, or the remote with git storage, or the sync
+// server if one is given and syncs once
+func runSync(folder, server, token string) error {
+	if _, err := config.EnsureConfigFolderExists(); err != nil {
+		return err
+	}
+
+	if err := database.Initialize(); err != nil {
+		return err
+	}
+
+	if server != "" {
+		if err := syncer.SetServer(server, token); err != nil {
+			return err
+		}
+		// Printed from the config, which keeps the token out of the URL
+		if syncConfig, err := config.LoadSyncConfig(); err == nil {
+			fmt.Printf("Sync server set to %s\n", syncConfig.Server)
+		}
+	}
+
+	if folder != "" {
+		if err := syncer.SetFolder(folder); err != nil {
+			return err
+		}
+		if database.UsingGit() {
+			fmt.Printf("Git remote set to %s\n", folder)
+		} else {
+			fmt.Printf("Shared folder set to %s\n", folder)
+		}
+	}
+
+	result, err := syncer.Sync()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Sent %d changes, received %d.\n", result.Sent, result.Received)
+	if result.Pending > 0 {
+		fmt.Printf("%d changes are still incomplete in the shared folder and will be applied later.\n", result.Pending)
+	}
+	if result.Conflicts > 0 && database.UsingGit() {
+		fmt.Printf("%d entries were edited on both sides, the remote versions were kept as copies marked (conflict).\n", result.Conflicts)
+	} else if result.Conflicts > 0 {
+		fmt.Printf("%d new conflicts, review them on the sync screen.\n", result.Conflicts)
+	}
+	for _, device := range result.Devices {
+		fmt.Printf("  %s, last synced %s\n", device.Name, device.LastSync.Format("Jan 02, 2006 15:04"))
+	}
+	return nil
+}
+
+// switchStorage moves all data to another storage backend
+func switchStorage(storage string) error {
+	if _, err := config.EnsureConfigFolderExists(); err != nil {
+		return err
+	}
+
+	count, err := database.SwitchStorage(storage)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Copied %d entries, ThighPads now uses %s storage.\n", count, storage)
+	return nil
+}
+
+// runServe shares the local tables with other devices until it is stopped
+func runServe(listen, token string) error {
+	if _, err := config.EnsureConfigFolderExists(); err != nil {
+		return err
+	}
+
+	if err := database.Initialize(); err != nil {
+		return err
+	}
+
+	if token == "" {
+		var err error
+		if token, err = syncer.ServerToken(); err != nil {
+			return err
+		}
+	}
+
+	server, err := syncer.NewServer(token)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Serving your tables on http://%s\n", listen)
+	fmt.Printf("Connect other devices with: thighpads sync --server http://%s --token %s\n", listen, token)
+	return http.ListenAndServe(listen, server.Handler())
+}
+
+// runAPI serves the REST API until it is stopped
+func runAPI(listen, token string) error {
+	if _, err := config.EnsureConfigFolderExists(); err != nil {
+		return err
+	}
+
+	if err := database.Initialize(); err != nil {
+		return err
+	}
+
+	if token == "" {
+		var err error
+		if token, err = api.Token(); err != nil {
+			return err
+		}
+	}
+
+	user := ""
+	if cfg, err := config.LoadConfig(); err == nil {
+		user = cfg.Username
+	}
+
+	server, err := api.NewServer(token, user)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Serving the API on http://%s/api/v1, described at /api/v1/openapi.json\n", listen)
+	fmt.Printf("Send the header: Authorization: Bearer %s\n", token)
+	return http.ListenAndServe(listen, server.Handler())
+}
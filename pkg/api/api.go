@@ -0,0 +1,485 @@
+// Package api serves a REST/JSON API over the local database so that other
+// tools, such as chat bots or CI jobs, can read and write tables and entries
+// without going through the TUI. It is separate from sync: see the syncer
+// package for keeping devices in step.
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/config"
+	"github.com/s42yt/thighpads/pkg/data"
+	"github.com/s42yt/thighpads/pkg/database"
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+// DefaultAddress is where thighpads api listens unless told otherwise
+const DefaultAddress = "127.0.0.1:8421"
+
+const (
+	tokenFileName = "api_token"
+	maxBodySize   = 16 << 20
+)
+
+type Table struct {
+	ID         uint      `json:"id"`
+	UUID       string    `json:"uuid"`
+	Name       string    `json:"name"`
+	Author     string    `json:"author"`
+	CreatedAt  time.Time `json:"createdAt"`
+	EntryCount int       `json:"entryCount"`
+}
+
+type Entry struct {
+	ID        uint      `json:"id"`
+	UUID      string    `json:"uuid"`
+	TableID   uint      `json:"tableId"`
+	Title     string    `json:"title"`
+	Tags      string    `json:"tags"`
+	Content   string    `json:"content"`
+	Language  string    `json:"language"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// tableInput and entryInput are request bodies. Fields left out of a PATCH
+// keep their value.
+type tableInput struct {
+	Name   *string `json:"name"`
+	Author *string `json:"author"`
+}
+
+type entryInput struct {
+	TableID  *uint   `json:"tableId"`
+	Title    *string `json:"title"`
+	Tags     *string `json:"tags"`
+	Content  *string `json:"content"`
+	Language *string `json:"language"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server handles API requests. Writes are serialized so that a request never
+// sees another one half done.
+type Server struct {
+	mu    sync.Mutex
+	token string
+	user  string
+}
+
+// Token returns the token thighpads api uses when none is given, creating
+// one on first use
+func Token() (string, error) {
+	configPath, err := config.EnsureConfigFolderExists()
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(configPath, tokenFileName)
+	if content, err := os.ReadFile(path); err == nil {
+		if token := strings.TrimSpace(string(content)); token != "" {
+			return token, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(random)
+	return token, os.WriteFile(path, []byte(token+"\n"), 0600)
+}
+
+// NewServer returns an API server. The database has to be initialized.
+// Tables created without an author are attributed to user.
+func NewServer(token, user string) (*Server, error) {
+	if token == "" {
+		return nil, errors.New("the API needs a token")
+	}
+	return &Server{token: token, user: user}, nil
+}
+
+// Handler returns the HTTP API described by OpenAPI
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/tables", s.listTables)
+	mux.HandleFunc("POST /api/v1/tables", s.createTable)
+	mux.HandleFunc("GET /api/v1/tables/{id}", s.getTable)
+	mux.HandleFunc("PATCH /api/v1/tables/{id}", s.updateTable)
+	mux.HandleFunc("DELETE /api/v1/tables/{id}", s.deleteTable)
+	mux.HandleFunc("GET /api/v1/tables/{id}/entries", s.listEntries)
+	mux.HandleFunc("POST /api/v1/tables/{id}/entries", s.createEntry)
+	mux.HandleFunc("GET /api/v1/entries/{id}", s.getEntry)
+	mux.HandleFunc("PATCH /api/v1/entries/{id}", s.updateEntry)
+	mux.HandleFunc("DELETE /api/v1/entries/{id}", s.deleteEntry)
+	mux.HandleFunc("GET /api/v1/search", s.search)
+	mux.HandleFunc("GET /api/v1/export", s.export)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The description is public so that tools can discover the API
+		if r.Method == http.MethodGet && r.URL.Path == "/api/v1/openapi.json" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(OpenAPI))
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) listTables(w http.ResponseWriter, r *http.Request) {
+	tables, err := database.GetTables()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	result := []Table{}
+	for _, table := range tables {
+		result = append(result, tableJSON(table))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) createTable(w http.ResponseWriter, r *http.Request) {
+	var input tableInput
+	if !readJSON(w, r, &input) {
+		return
+	}
+	if input.Name == nil || strings.TrimSpace(*input.Name) == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	table := models.Table{Name: strings.TrimSpace(*input.Name), Author: s.user}
+	if input.Author != nil {
+		table.Author = *input.Author
+	}
+
+	if err := database.CreateTable(&table); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, tableJSON(table))
+}
+
+func (s *Server) getTable(w http.ResponseWriter, r *http.Request) {
+	table, ok := findTable(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, tableJSON(table))
+}
+
+func (s *Server) updateTable(w http.ResponseWriter, r *http.Request) {
+	table, ok := findTable(w, r)
+	if !ok {
+		return
+	}
+
+	var input tableInput
+	if !readJSON(w, r, &input) {
+		return
+	}
+	if input.Name != nil {
+		if strings.TrimSpace(*input.Name) == "" {
+			writeError(w, http.StatusBadRequest, "name must not be empty")
+			return
+		}
+		table.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Author != nil {
+		table.Author = *input.Author
+	}
+
+	if err := database.UpdateTable(&table); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, tableJSON(table))
+}
+
+func (s *Server) deleteTable(w http.ResponseWriter, r *http.Request) {
+	table, ok := findTable(w, r)
+	if !ok {
+		return
+	}
+
+	if err := database.DeleteTable(table.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listEntries(w http.ResponseWriter, r *http.Request) {
+	table, ok := findTable(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := database.GetEntries(table.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	result := []Entry{}
+	for _, entry := range entries {
+		result = append(result, entryJSON(entry))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) createEntry(w http.ResponseWriter, r *http.Request) {
+	table, ok := findTable(w, r)
+	if !ok {
+		return
+	}
+
+	var input entryInput
+	if !readJSON(w, r, &input) {
+		return
+	}
+	if input.Title == nil || strings.TrimSpace(*input.Title) == "" {
+		writeError(w, http.StatusBadRequest, "title is required")
+		return
+	}
+
+	entry := models.Entry{TableID: table.ID, Title: strings.TrimSpace(*input.Title)}
+	if input.Tags != nil {
+		entry.Tags = *input.Tags
+	}
+	if input.Content != nil {
+		entry.Content = *input.Content
+	}
+	if input.Language != nil {
+		entry.Language = data.NormalizeLanguage(*input.Language)
+	}
+
+	if err := database.CreateEntry(&entry); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, entryJSON(entry))
+}
+
+func (s *Server) getEntry(w http.ResponseWriter, r *http.Request) {
+	entry, ok := findEntry(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, entryJSON(entry))
+}
+
+func (s *Server) updateEntry(w http.ResponseWriter, r *http.Request) {
+	entry, ok := findEntry(w, r)
+	if !ok {
+		return
+	}
+
+	var input entryInput
+	if !readJSON(w, r, &input) {
+		return
+	}
+	if input.TableID != nil {
+		if _, err := database.GetTable(*input.TableID); err != nil {
+			writeError(w, http.StatusBadRequest, "table not found")
+			return
+		}
+		entry.TableID = *input.TableID
+	}
+	if input.Title != nil {
+		if strings.TrimSpace(*input.Title) == "" {
+			writeError(w, http.StatusBadRequest, "title must not be empty")
+			return
+		}
+		entry.Title = strings.TrimSpace(*input.Title)
+	}
+	if input.Tags != nil {
+		entry.Tags = *input.Tags
+	}
+	if input.Content != nil {
+		entry.Content = *input.Content
+	}
+	if input.Language != nil {
+		entry.Language = data.NormalizeLanguage(*input.Language)
+	}
+
+	if err := database.UpdateEntry(&entry); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Read it back for the new update time
+	if updated, err := database.GetEntry(entry.ID); err == nil {
+		entry = updated
+	}
+	writeJSON(w, http.StatusOK, entryJSON(entry))
+}
+
+func (s *Server) deleteEntry(w http.ResponseWriter, r *http.Request) {
+	entry, ok := findEntry(w, r)
+	if !ok {
+		return
+	}
+
+	if err := database.DeleteEntry(entry.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// search finds entries whose title, tags or content contain every word of q,
+// in all tables or only in the table given by the table parameter
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	words := strings.Fields(strings.ToLower(r.URL.Query().Get("q")))
+	if len(words) == 0 {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+
+	tables, err := database.GetTables()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if tableParam := r.URL.Query().Get("table"); tableParam != "" {
+		id, err := strconv.ParseUint(tableParam, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid table")
+			return
+		}
+		table, err := database.GetTable(uint(id))
+		if err != nil {
+			writeError(w, http.StatusNotFound, "table not found")
+			return
+		}
+		tables = []models.Table{table}
+	}
+
+	result := []Entry{}
+	for _, table := range tables {
+		entries, err := database.GetEntries(table.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		for _, entry := range entries {
+			text := strings.ToLower(entry.Title + "\n" + entry.Tags + "\n" + entry.Content)
+			matches := true
+			for _, word := range words {
+				if !strings.Contains(text, word) {
+					matches = false
+					break
+				}
+			}
+			if matches {
+				result = append(result, entryJSON(entry))
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func findTable(w http.ResponseWriter, r *http.Request) (models.Table, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid table id")
+		return models.Table{}, false
+	}
+
+	table, err := database.GetTable(uint(id))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "table not found")
+		return table, false
+	}
+	return table, true
+}
+
+func findEntry(w http.ResponseWriter, r *http.Request) (models.Entry, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid entry id")
+		return models.Entry{}, false
+	}
+
+	entry, err := database.GetEntry(uint(id))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "entry not found")
+		return entry, false
+	}
+	return entry, true
+}
+
+func tableJSON(table models.Table) Table {
+	entries, _ := database.GetEntries(table.ID)
+	return Table{
+		ID:         table.ID,
+		UUID:       table.UUID,
+		Name:       table.Name,
+		Author:     table.Author,
+		CreatedAt:  table.CreatedAt,
+		EntryCount: len(entries),
+	}
+}
+
+func entryJSON(entry models.Entry) Entry {
+	return Entry{
+		ID:        entry.ID,
+		UUID:      entry.UUID,
+		TableID:   entry.TableID,
+		Title:     entry.Title,
+		Tags:      entry.Tags,
+		Content:   entry.Content,
+		Language:  entry.Language,
+		CreatedAt: entry.CreatedAt,
+		UpdatedAt: entry.UpdatedAt,
+	}
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, value interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
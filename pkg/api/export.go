@@ -0,0 +1,134 @@
+package api
+
+import (
+	"archive/zip"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/s42yt/thighpads/pkg/data"
+)
+
+// exportFormats are the names the export endpoint accepts
+var exportFormats = map[string]data.ExportFormat{
+	"thighpad": data.ThighpadFormat,
+	"markdown": data.MarkdownFormat,
+	"html":     data.HTMLFormat,
+	"print":    data.PrintHTMLFormat,
+	"site":     data.SiteFormat,
+	"csv":      data.CSVFormat,
+	"jsonl":    data.JSONLFormat,
+	"bundle":   data.BundleFormat,
+}
+
+// export runs an export into a temporary folder and sends the result: the
+// file itself when the format writes a single file, otherwise a zip archive
+// of everything that was written
+func (s *Server) export(w http.ResponseWriter, r *http.Request) {
+	formatName := r.URL.Query().Get("format")
+	if formatName == "" {
+		formatName = "thighpad"
+	}
+	format, ok := exportFormats[formatName]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "unknown format "+formatName)
+		return
+	}
+
+	var tableIDs []uint
+	for _, field := range strings.FieldsFunc(r.URL.Query().Get("tables"), func(r rune) bool { return r == ',' }) {
+		id, err := strconv.ParseUint(strings.TrimSpace(field), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid table id "+field)
+			return
+		}
+		tableIDs = append(tableIDs, uint(id))
+	}
+
+	dir, err := os.MkdirTemp("", "thighpads-api-export-")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	target := data.ExportTarget{
+		Dirs:     []string{dir},
+		Template: data.DefaultFilenameTemplate,
+		Policy:   data.OverwriteExisting,
+		User:     s.user,
+	}
+	if _, err := data.ExportTablesAs(tableIDs, s.user, target, format); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	files, err := listFiles(dir)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if len(files) == 1 {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filepath.Base(files[0])+`"`)
+		http.ServeFile(w, r, filepath.Join(dir, files[0]))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="thighpads-`+formatName+`.zip"`)
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		if err := addToZip(archive, dir, file); err != nil {
+			// Headers are sent already, all that is left is to cut the
+			// archive short
+			return
+		}
+	}
+	archive.Close()
+}
+
+// listFiles returns the files below dir, relative to it
+func listFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		files = append(files, rel)
+		return err
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+func addToZip(archive *zip.Writer, dir, name string) error {
+	file, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(name)
+	header.Method = zip.Deflate
+
+	writer, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, file)
+	return err
+}
@@ -0,0 +1,211 @@
+package api
+
+// OpenAPI describes the API. It is served at /api/v1/openapi.json.
+const OpenAPI = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "ThighPads API",
+    "version": "1.0.0",
+    "description": "Read and write ThighPads tables and entries. Every request except this description needs an Authorization: Bearer <token> header."
+  },
+  "servers": [{"url": "http://127.0.0.1:8421"}],
+  "security": [{"bearer": []}],
+  "paths": {
+    "/api/v1/tables": {
+      "get": {
+        "summary": "List tables",
+        "responses": {
+          "200": {"description": "All tables", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Table"}}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"}
+        }
+      },
+      "post": {
+        "summary": "Create a table",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/TableInput"}}}},
+        "responses": {
+          "201": {"description": "The new table", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Table"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"}
+        }
+      }
+    },
+    "/api/v1/tables/{id}": {
+      "parameters": [{"$ref": "#/components/parameters/ID"}],
+      "get": {
+        "summary": "Get a table",
+        "responses": {
+          "200": {"description": "The table", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Table"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "404": {"$ref": "#/components/responses/NotFound"}
+        }
+      },
+      "patch": {
+        "summary": "Rename a table or change its author",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/TableInput"}}}},
+        "responses": {
+          "200": {"description": "The updated table", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Table"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "404": {"$ref": "#/components/responses/NotFound"}
+        }
+      },
+      "delete": {
+        "summary": "Delete a table with all its entries",
+        "responses": {
+          "204": {"description": "Deleted"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "404": {"$ref": "#/components/responses/NotFound"}
+        }
+      }
+    },
+    "/api/v1/tables/{id}/entries": {
+      "parameters": [{"$ref": "#/components/parameters/ID"}],
+      "get": {
+        "summary": "List the entries of a table",
+        "responses": {
+          "200": {"description": "The entries", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Entry"}}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "404": {"$ref": "#/components/responses/NotFound"}
+        }
+      },
+      "post": {
+        "summary": "Add an entry to a table",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/EntryInput"}}}},
+        "responses": {
+          "201": {"description": "The new entry", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Entry"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "404": {"$ref": "#/components/responses/NotFound"}
+        }
+      }
+    },
+    "/api/v1/entries/{id}": {
+      "parameters": [{"$ref": "#/components/parameters/ID"}],
+      "get": {
+        "summary": "Get an entry",
+        "responses": {
+          "200": {"description": "The entry", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Entry"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "404": {"$ref": "#/components/responses/NotFound"}
+        }
+      },
+      "patch": {
+        "summary": "Edit an entry or move it to another table",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/EntryInput"}}}},
+        "responses": {
+          "200": {"description": "The updated entry", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Entry"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "404": {"$ref": "#/components/responses/NotFound"}
+        }
+      },
+      "delete": {
+        "summary": "Delete an entry",
+        "responses": {
+          "204": {"description": "Deleted"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "404": {"$ref": "#/components/responses/NotFound"}
+        }
+      }
+    },
+    "/api/v1/search": {
+      "get": {
+        "summary": "Find entries whose title, tags or content contain every word of q",
+        "parameters": [
+          {"name": "q", "in": "query", "required": true, "schema": {"type": "string"}},
+          {"name": "table", "in": "query", "description": "Only search this table", "schema": {"type": "integer"}}
+        ],
+        "responses": {
+          "200": {"description": "Matching entries", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Entry"}}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"}
+        }
+      }
+    },
+    "/api/v1/export": {
+      "get": {
+        "summary": "Export tables. Formats that write several files are sent as a zip archive.",
+        "parameters": [
+          {"name": "format", "in": "query", "schema": {"type": "string", "default": "thighpad", "enum": ["thighpad", "markdown", "html", "print", "site", "csv", "jsonl", "bundle"]}},
+          {"name": "tables", "in": "query", "description": "Comma-separated table IDs, all tables if left out", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"description": "The exported file", "content": {"application/octet-stream": {"schema": {"type": "string", "format": "binary"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"}
+        }
+      }
+    },
+    "/api/v1/openapi.json": {
+      "get": {
+        "summary": "This description",
+        "security": [],
+        "responses": {"200": {"description": "OpenAPI document"}}
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearer": {"type": "http", "scheme": "bearer"}
+    },
+    "parameters": {
+      "ID": {"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}}
+    },
+    "responses": {
+      "BadRequest": {"description": "Invalid request", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+      "Unauthorized": {"description": "Missing or wrong token", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+      "NotFound": {"description": "No such table or entry", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+    },
+    "schemas": {
+      "Table": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "integer"},
+          "uuid": {"type": "string"},
+          "name": {"type": "string"},
+          "author": {"type": "string"},
+          "createdAt": {"type": "string", "format": "date-time"},
+          "entryCount": {"type": "integer"}
+        }
+      },
+      "TableInput": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string", "description": "Required when creating a table"},
+          "author": {"type": "string", "description": "Defaults to the ThighPads username"}
+        }
+      },
+      "Entry": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "integer"},
+          "uuid": {"type": "string"},
+          "tableId": {"type": "integer"},
+          "title": {"type": "string"},
+          "tags": {"type": "string", "description": "Comma-separated"},
+          "content": {"type": "string"},
+          "language": {"type": "string", "description": "Language of the code in the entry, empty when detected from its fenced code blocks"},
+          "createdAt": {"type": "string", "format": "date-time"},
+          "updatedAt": {"type": "string", "format": "date-time"}
+        }
+      },
+      "EntryInput": {
+        "type": "object",
+        "properties": {
+          "title": {"type": "string", "description": "Required when creating an entry"},
+          "tags": {"type": "string", "description": "Comma-separated"},
+          "content": {"type": "string"},
+          "language": {"type": "string", "description": "e.g. go or python, empty to detect it from the fenced code blocks"},
+          "tableId": {"type": "integer", "description": "Moves the entry to another table"}
+        }
+      },
+      "Error": {
+        "type": "object",
+        "properties": {
+          "error": {"type": "string"}
+        }
+      }
+    }
+  }
+}
+`
@@ -15,10 +15,47 @@ const (
 	DBFileName            = "thighpads.db"
 	ExportFolderName      = "exports"
 	ExportsConfigFileName = "exports_config.json"
+	RecentImportsFileName = "recent_imports.json"
+	AttachmentsFolderName = "attachments"
+	SyncConfigFileName    = "sync.json"
+	SyncStateFileName     = "sync_state.json"
+	GitRepoFolderName     = "repository"
+	ServerFolderName      = "server"
+	ThemesFolderName      = "themes"
+	KeymapFileName        = "keymap.json"
+	DraftsFolderName      = "drafts"
 )
 
+// DefaultServerAddress is where thighpads serve listens unless told otherwise
+const DefaultServerAddress = "127.0.0.1:8420"
+
+// DefaultSyncInterval is how often the app syncs while it is running when
+// sync.json does not say otherwise
+const DefaultSyncInterval = 5
+
+// SyncConfig holds the shared folder settings of folder sync
+type SyncConfig struct {
+	Folder string `json:"folder"`
+	// Server is the URL of a sync server used instead of a folder, see
+	// thighpads serve. Token authenticates with it.
+	Server string `json:"server,omitempty"`
+	Token  string `json:"token,omitempty"`
+	// IntervalMinutes between syncs while the app is running
+	IntervalMinutes int    `json:"intervalMinutes,omitempty"`
+	DeviceName      string `json:"deviceName,omitempty"`
+}
+
 type ExportsConfig struct {
-	DesktopPath string `json:"desktopPath"`
+	DesktopPath      string              `json:"desktopPath"`
+	Destinations     []ExportDestination `json:"destinations,omitempty"`
+	FilenameTemplate string              `json:"filenameTemplate,omitempty"`
+	OverwritePolicy  string              `json:"overwritePolicy,omitempty"`
+}
+
+// ExportDestination is a named export folder offered on the export screen
+type ExportDestination struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
 }
 
 func GetConfigPath() (string, error) {
@@ -109,6 +146,54 @@ func GetExportPath() (string, error) {
 	return filepath.Join(configPath, ExportFolderName), nil
 }
 
+func GetAttachmentsPath() (string, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configPath, AttachmentsFolderName), nil
+}
+
+func GetGitRepoPath() (string, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configPath, GitRepoFolderName), nil
+}
+
+func GetServerPath() (string, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configPath, ServerFolderName), nil
+}
+
+func GetThemesPath() (string, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configPath, ThemesFolderName), nil
+}
+
+func GetKeymapPath() (string, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configPath, KeymapFileName), nil
+}
+
+func GetDraftsPath() (string, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configPath, DraftsFolderName), nil
+}
+
 func IsFirstRun() (bool, error) {
 	configPath, err := GetConfigPath()
 	if err != nil {
@@ -121,24 +206,47 @@ func IsFirstRun() (bool, error) {
 	return os.IsNotExist(err), nil
 }
 
-func GetDesktopExportPath() (string, error) {
-
+// LoadExportsConfig reads exports_config.json. A missing file is not an error
+// and yields an empty config.
+func LoadExportsConfig() (*ExportsConfig, error) {
 	configPath, err := GetConfigPath()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	exportsConfigFile := filepath.Join(configPath, ExportsConfigFileName)
-	if _, err := os.Stat(exportsConfigFile); err == nil {
-		data, err := os.ReadFile(exportsConfigFile)
-		if err == nil {
-			var exportsConfig ExportsConfig
-			if err := json.Unmarshal(data, &exportsConfig); err == nil && exportsConfig.DesktopPath != "" {
+	data, err := os.ReadFile(filepath.Join(configPath, ExportsConfigFileName))
+	if os.IsNotExist(err) {
+		return &ExportsConfig{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var exportsConfig ExportsConfig
+	if err := json.Unmarshal(data, &exportsConfig); err != nil {
+		return nil, err
+	}
+
+	return &exportsConfig, nil
+}
+
+func SaveExportsConfig(exportsConfig *ExportsConfig) error {
+	configPath, err := EnsureConfigFolderExists()
+	if err != nil {
+		return err
+	}
 
-				if _, err := os.Stat(exportsConfig.DesktopPath); err == nil {
-					return exportsConfig.DesktopPath, nil
-				}
-			}
+	data, err := json.MarshalIndent(exportsConfig, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(configPath, ExportsConfigFileName), data, 0644)
+}
+
+func GetDesktopExportPath() (string, error) {
+	if exportsConfig, err := LoadExportsConfig(); err == nil && exportsConfig.DesktopPath != "" {
+		if _, err := os.Stat(exportsConfig.DesktopPath); err == nil {
+			return exportsConfig.DesktopPath, nil
 		}
 	}
 
@@ -158,3 +266,97 @@ func GetDesktopExportPath() (string, error) {
 
 	return desktopExportsDir, nil
 }
+
+// maxRecentImportDirs is how many recently used import locations are kept
+const maxRecentImportDirs = 8
+
+// LoadRecentImportDirs returns the folders imports were recently made from,
+// most recent first
+func LoadRecentImportDirs() ([]string, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(configPath, RecentImportsFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	err = json.Unmarshal(data, &dirs)
+	return dirs, err
+}
+
+// AddRecentImportDir moves dir to the front of the recently used import
+// locations
+func AddRecentImportDir(dir string) error {
+	dirs, _ := LoadRecentImportDirs()
+
+	recent := []string{dir}
+	for _, existing := range dirs {
+		if existing != dir && len(recent) < maxRecentImportDirs {
+			recent = append(recent, existing)
+		}
+	}
+
+	configPath, err := EnsureConfigFolderExists()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(recent, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(configPath, RecentImportsFileName), data, 0644)
+}
+
+// LoadSyncConfig reads sync.json. A missing file is not an error and yields a
+// config without a folder, i.e. sync is disabled.
+func LoadSyncConfig() (*SyncConfig, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	syncConfig := &SyncConfig{IntervalMinutes: DefaultSyncInterval}
+
+	data, err := os.ReadFile(filepath.Join(configPath, SyncConfigFileName))
+	if os.IsNotExist(err) {
+		return syncConfig, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, syncConfig); err != nil {
+		return nil, err
+	}
+	if syncConfig.IntervalMinutes <= 0 {
+		syncConfig.IntervalMinutes = DefaultSyncInterval
+	}
+
+	return syncConfig, nil
+}
+
+func SaveSyncConfig(syncConfig *SyncConfig) error {
+	configPath, err := EnsureConfigFolderExists()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(syncConfig, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// May hold the token of a sync server
+	path := filepath.Join(configPath, SyncConfigFileName)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
+}
@@ -0,0 +1,204 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/s42yt/thighpads/pkg/database"
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+// MaxAttachmentSize keeps the database and exports manageable. Attachments are
+// meant for screenshots, log excerpts and config files, not videos.
+const MaxAttachmentSize = 25 << 20
+
+// AttachFile copies the file at path into the attachment store and attaches it
+// to the entry
+func AttachFile(entryID uint, path string) (models.Attachment, error) {
+	path, err := ExpandHome(strings.TrimSpace(path))
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	if info.IsDir() {
+		return models.Attachment{}, errors.New(path + " is a folder")
+	}
+	if info.Size() > MaxAttachmentSize {
+		return models.Attachment{}, fmt.Errorf("%s is larger than %s", filepath.Base(path), FormatSize(MaxAttachmentSize))
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	return AttachData(entryID, filepath.Base(path), content)
+}
+
+// AttachData stores content under filename and attaches it to the entry. The
+// MIME type is guessed from the extension, then from the content.
+func AttachData(entryID uint, filename string, content []byte) (models.Attachment, error) {
+	if len(content) > MaxAttachmentSize {
+		return models.Attachment{}, fmt.Errorf("%s is larger than %s", filename, FormatSize(MaxAttachmentSize))
+	}
+
+	hash, err := database.StoreBlob(content)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	attachment := models.Attachment{
+		EntryID:  entryID,
+		Filename: sanitizeFilename(filename),
+		MimeType: detectMimeType(filename, content),
+		Size:     int64(len(content)),
+		Hash:     hash,
+	}
+
+	err = database.CreateAttachment(&attachment)
+	return attachment, err
+}
+
+func detectMimeType(filename string, content []byte) string {
+	if mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(filename))); mimeType != "" {
+		return mimeType
+	}
+	return http.DetectContentType(content)
+}
+
+// SaveAttachmentAs writes the attachment to path. If path is a folder the
+// original filename is used inside it; existing files are never overwritten.
+func SaveAttachmentAs(attachment models.Attachment, path string) (string, error) {
+	path, err := ExpandHome(strings.TrimSpace(path))
+	if err != nil {
+		return "", err
+	}
+	if path == "" {
+		return "", errors.New("enter a file or folder to save to")
+	}
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, attachment.Filename)
+	}
+
+	content, err := database.ReadBlob(attachment.Hash)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	path = uniqueFilename(path, filepath.Ext(path))
+	return path, os.WriteFile(path, content, 0644)
+}
+
+// OpenAttachment opens the attachment with the system's default application.
+// The content is copied to a temporary file under its original name first so
+// that the application can tell what kind of file it is.
+func OpenAttachment(attachment models.Attachment) error {
+	dir, err := os.MkdirTemp("", "thighpads-")
+	if err != nil {
+		return err
+	}
+
+	path, err := SaveAttachmentAs(attachment, dir)
+	if err != nil {
+		return err
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", path)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not open %s: %w", attachment.Filename, err)
+	}
+	go cmd.Wait()
+
+	return nil
+}
+
+// loadAttachments fills in the attachments of entries
+func loadAttachments(entries []models.Entry) error {
+	for i := range entries {
+		attachments, err := database.GetAttachments(entries[i].ID)
+		if err != nil {
+			return err
+		}
+		entries[i].Attachments = attachments
+	}
+	return nil
+}
+
+// storeAttachments adds imported attachments to an entry, skipping content
+// the entry already has. Attachments exported without their data are only
+// restored when the content is already in the local store.
+func storeAttachments(entryID uint, attachments []models.Attachment, existing []models.Attachment) error {
+	have := map[string]bool{}
+	for _, attachment := range existing {
+		have[attachment.Hash] = true
+	}
+
+	for _, attachment := range attachments {
+		if attachment.Data == nil {
+			if _, err := database.ReadBlob(attachment.Hash); err != nil {
+				continue
+			}
+		} else {
+			hash, err := database.StoreBlob(attachment.Data)
+			if err != nil {
+				return err
+			}
+			attachment.Hash = hash
+			attachment.Size = int64(len(attachment.Data))
+		}
+
+		if have[attachment.Hash] {
+			continue
+		}
+		have[attachment.Hash] = true
+
+		attachment.ID = 0
+		attachment.EntryID = entryID
+		attachment.Data = nil
+		if err := database.CreateAttachment(&attachment); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FormatSize renders a byte count for humans, e.g. "1.5 MB"
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
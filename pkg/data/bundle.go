@@ -0,0 +1,293 @@
+package data
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/config"
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+const (
+	BundleExtension   = ".thighpads"
+	BundleFormatName  = "thighpads-bundle"
+	BundleVersion     = "1.0"
+	AccountBundleName = "ThighPads Account"
+
+	bundleManifest  = "manifest.json"
+	bundleTablesDir = "tables"
+	bundleConfigDir = "config"
+)
+
+// bundleConfigFiles are the files from the config folder that a full-account
+// bundle carries along
+var bundleConfigFiles = []string{config.ConfigFileName, config.ExportsConfigFileName}
+
+// BundleManifest describes the contents of a bundle. Every table is stored as
+// its own version 2 .thighpad file below tables/.
+type BundleManifest struct {
+	Format      string             `json:"format"`
+	Version     string             `json:"version"`
+	ExportedAt  time.Time          `json:"exportedAt"`
+	ExportedBy  string             `json:"exportedBy"`
+	FullAccount bool               `json:"fullAccount"`
+	Tables      []BundleTableEntry `json:"tables"`
+}
+
+type BundleTableEntry struct {
+	File    string `json:"file"`
+	ID      string `json:"id,omitempty"`
+	Name    string `json:"name"`
+	Entries int    `json:"entries"`
+}
+
+// ExportBundle writes the given tables into a single zip bundle in the target
+// directories. When tableIDs is empty the whole database is exported
+// together with the config files, so the bundle can restore a full account.
+func ExportBundle(tableIDs []uint, exportedBy string, target ExportTarget) (string, error) {
+	fullAccount := len(tableIDs) == 0
+
+	tables, err := tablesForExport(tableIDs)
+	if err != nil {
+		return "", err
+	}
+
+	name := AllTablesName
+	switch {
+	case fullAccount:
+		name = AccountBundleName
+	case len(tables) == 1:
+		name = tables[0].Name
+	}
+
+	var lastExportedPath string
+	for _, dir := range target.Dirs {
+		filename := target.filePath(dir, name, BundleExtension)
+
+		if err := writeBundle(filename, tables, exportedBy, fullAccount); err != nil {
+			os.Remove(filename)
+			fmt.Printf("Warning: could not write bundle %s: %v\n", filename, err)
+			continue
+		}
+
+		lastExportedPath = filename
+	}
+
+	if lastExportedPath == "" {
+		return "", errors.New("failed to export to any location")
+	}
+
+	return lastExportedPath, nil
+}
+
+func writeBundle(filename string, tables []models.Table, exportedBy string, fullAccount bool) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+
+	manifest := BundleManifest{
+		Format:      BundleFormatName,
+		Version:     BundleVersion,
+		ExportedAt:  time.Now(),
+		ExportedBy:  exportedBy,
+		FullAccount: fullAccount,
+	}
+
+	slugs := uniqueSlugs{}
+	for _, table := range tables {
+		file := path.Join(bundleTablesDir, slugs.next(slugify(table.Name))+FileExtension)
+		data, err := json.MarshalIndent(NewThighpadFileV2(table, table.Entries, exportedBy), "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeZipFile(zw, file, data); err != nil {
+			return err
+		}
+
+		manifest.Tables = append(manifest.Tables, BundleTableEntry{
+			File:    file,
+			ID:      table.UUID,
+			Name:    table.Name,
+			Entries: len(table.Entries),
+		})
+	}
+
+	if fullAccount {
+		configPath, err := config.GetConfigPath()
+		if err != nil {
+			return err
+		}
+		for _, name := range bundleConfigFiles {
+			data, err := os.ReadFile(filepath.Join(configPath, name))
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			if err := writeZipFile(zw, path.Join(bundleConfigDir, name), data); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeZipFile(zw, bundleManifest, data); err != nil {
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// previewBundle reads every table of a bundle, and for full-account bundles the
+// config files, into a preview
+func previewBundle(filename string) (*ImportPreview, error) {
+	zr, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	files := map[string]*zip.File{}
+	for _, file := range zr.File {
+		files[file.Name] = file
+	}
+
+	manifestFile, ok := files[bundleManifest]
+	if !ok {
+		return nil, errors.New("not a ThighPads bundle: " + bundleManifest + " is missing")
+	}
+
+	var manifest BundleManifest
+	data, err := readZipFile(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	if manifest.Format != BundleFormatName {
+		return nil, errors.New("not a ThighPads bundle: unexpected format " + manifest.Format)
+	}
+
+	preview := &ImportPreview{
+		Source:     BundleSource,
+		ExportedAt: manifest.ExportedAt,
+		ExportedBy: manifest.ExportedBy,
+	}
+	for _, info := range manifest.Tables {
+		file, ok := files[info.File]
+		if !ok {
+			return nil, errors.New("bundle is missing " + info.File)
+		}
+
+		data, err := readZipFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		thighpadFile, err := ParseThighpadFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", info.File, err)
+		}
+
+		table, entries := thighpadFile.ToModels()
+		table.Entries = entries
+		preview.Tables = append(preview.Tables, table)
+	}
+
+	if manifest.FullAccount {
+		preview.ConfigFiles = map[string][]byte{}
+		for _, name := range bundleConfigFiles {
+			file, ok := files[path.Join(bundleConfigDir, name)]
+			if !ok {
+				continue
+			}
+			data, err := readZipFile(file)
+			if err != nil {
+				return nil, err
+			}
+			preview.ConfigFiles[name] = data
+		}
+	}
+
+	return preview, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// restoreConfigFiles writes config files carried by a bundle back into the
+// config folder, replacing the current ones. Settings that belong to this
+// device, such as the storage backend the restored data goes into, are kept.
+func restoreConfigFiles(files map[string][]byte) error {
+	configPath, err := config.EnsureConfigFolderExists()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range bundleConfigFiles {
+		data, ok := files[name]
+		if !ok {
+			continue
+		}
+		if name == config.ConfigFileName {
+			if err := restoreConfig(data); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(configPath, name), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func restoreConfig(data []byte) error {
+	var restored models.Config
+	if err := json.Unmarshal(data, &restored); err != nil {
+		return fmt.Errorf("invalid %s in bundle: %w", config.ConfigFileName, err)
+	}
+
+	if local, err := config.LoadConfig(); err == nil {
+		restored.Storage = local.Storage
+		restored.DisableMouse = local.DisableMouse
+	} else {
+		restored.Storage, restored.DisableMouse = "", false
+	}
+
+	return config.SaveConfig(&restored)
+}
@@ -0,0 +1,128 @@
+package data
+
+import (
+	"path"
+	"strings"
+)
+
+// CodeBlock is a fenced code block of an entry. Start and End are the lines
+// of its opening and closing fence; End is the number of lines when the block
+// is never closed.
+type CodeBlock struct {
+	Language   string
+	Code       string
+	Start, End int
+}
+
+// languageAliases maps the names code blocks are commonly tagged with to the
+// ones ThighPads uses
+var languageAliases = map[string]string{
+	"golang":        "go",
+	"py":            "python",
+	"python3":       "python",
+	"js":            "javascript",
+	"node":          "javascript",
+	"jsx":           "javascript",
+	"ts":            "typescript",
+	"tsx":           "typescript",
+	"sh":            "shell",
+	"bash":          "shell",
+	"zsh":           "shell",
+	"console":       "shell",
+	"shell-session": "shell",
+	"yml":           "yaml",
+	"rs":            "rust",
+	"rb":            "ruby",
+	"c++":           "cpp",
+	"cc":            "cpp",
+	"h":             "c",
+	"hpp":           "cpp",
+	"psql":          "sql",
+	"postgresql":    "sql",
+	"mysql":         "sql",
+	"md":            "markdown",
+	"kt":            "kotlin",
+	"cs":            "csharp",
+	"c#":            "csharp",
+}
+
+// NormalizeLanguage turns the info string of a code block, or a language
+// typed by the user, into a lower case language name, resolving aliases
+// such as "py" and "bash"
+func NormalizeLanguage(language string) string {
+	fields := strings.Fields(strings.ToLower(language))
+	if len(fields) == 0 {
+		return ""
+	}
+	name := strings.Trim(fields[0], "{}.")
+	if alias, ok := languageAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// CodeBlocks finds the fenced code blocks of content. Like the Markdown
+// renderer it accepts ``` and ~~~ fences, which may be indented.
+func CodeBlocks(content string) []CodeBlock {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	var blocks []CodeBlock
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(trimmed, "```") && !strings.HasPrefix(trimmed, "~~~") {
+			continue
+		}
+		fence := trimmed[:3]
+		block := CodeBlock{Language: NormalizeLanguage(trimmed[3:]), Start: i, End: len(lines)}
+		for j := i + 1; j < len(lines); j++ {
+			if strings.HasPrefix(strings.TrimSpace(lines[j]), fence) {
+				block.End = j
+				break
+			}
+		}
+		block.Code = strings.Join(lines[i+1:min(block.End, len(lines))], "\n")
+		blocks = append(blocks, block)
+		i = block.End
+	}
+	return blocks
+}
+
+// DetectLanguage guesses the language of content from its first tagged code
+// block, or from the interpreter of a "#!" line at its top
+func DetectLanguage(content string) string {
+	for _, block := range CodeBlocks(content) {
+		if block.Language != "" {
+			return block.Language
+		}
+	}
+
+	first, _, _ := strings.Cut(content, "\n")
+	if !strings.HasPrefix(first, "#!") {
+		return ""
+	}
+	fields := strings.Fields(first[2:])
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		// The interpreter is the first argument that is not an option of env
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") {
+				interpreter = path.Base(field)
+				break
+			}
+		}
+	}
+	return NormalizeLanguage(strings.TrimRight(interpreter, "0123456789."))
+}
+
+// EntryLanguage is the language set on an entry or, when there is none, the
+// detected one. detected tells which of the two it is.
+func EntryLanguage(language, content string) (name string, detected bool) {
+	if language != "" {
+		return language, false
+	}
+	name = DetectLanguage(content)
+	return name, name != ""
+}
@@ -0,0 +1,223 @@
+package data
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/config"
+)
+
+// DefaultFilenameTemplate reproduces the plain table name used before
+// templates existed
+const DefaultFilenameTemplate = "{table}"
+
+// OverwritePolicy decides what happens when an export file already exists.
+// Folder exports such as Markdown and static sites are always refreshed in
+// place so that they can be kept under version control.
+type OverwritePolicy int
+
+const (
+	VersionExisting OverwritePolicy = iota
+	OverwriteExisting
+)
+
+var OverwritePolicies = []OverwritePolicy{VersionExisting, OverwriteExisting}
+
+func (p OverwritePolicy) String() string {
+	switch p {
+	case VersionExisting:
+		return "Keep both (add _1, _2, ...)"
+	case OverwriteExisting:
+		return "Overwrite"
+	}
+	return "Unknown"
+}
+
+// configName is the value used for the policy in exports_config.json
+func (p OverwritePolicy) configName() string {
+	if p == OverwriteExisting {
+		return "overwrite"
+	}
+	return "version"
+}
+
+// ParseOverwritePolicy reads a policy from exports_config.json, falling back
+// to VersionExisting
+func ParseOverwritePolicy(name string) OverwritePolicy {
+	for _, policy := range OverwritePolicies {
+		if policy.configName() == strings.ToLower(strings.TrimSpace(name)) {
+			return policy
+		}
+	}
+	return VersionExisting
+}
+
+// ExportTarget describes where an export is written and how it is named.
+// Name takes precedence over Template; both may use the placeholders {table},
+// {date}, {time} and {user}.
+type ExportTarget struct {
+	Dirs     []string
+	Name     string
+	Template string
+	Policy   OverwritePolicy
+	User     string
+}
+
+// Target resolves a location to its directories, using the filename template
+// and overwrite policy from exports_config.json
+func (l ExportLocation) Target() (ExportTarget, error) {
+	dirs, err := exportPaths(l)
+	if err != nil {
+		return ExportTarget{}, err
+	}
+	return newExportTarget(dirs), nil
+}
+
+func newExportTarget(dirs []string) ExportTarget {
+	target := ExportTarget{Dirs: dirs, Template: DefaultFilenameTemplate}
+
+	if exportsConfig, err := config.LoadExportsConfig(); err == nil {
+		if exportsConfig.FilenameTemplate != "" {
+			target.Template = exportsConfig.FilenameTemplate
+		}
+		target.Policy = ParseOverwritePolicy(exportsConfig.OverwritePolicy)
+	}
+
+	return target
+}
+
+// Filename expands the name or template for an export whose default name is
+// base, without directory or extension
+func (t ExportTarget) Filename(base string) string {
+	pattern := t.Name
+	if pattern == "" {
+		pattern = t.Template
+	}
+	if pattern == "" {
+		pattern = DefaultFilenameTemplate
+	}
+
+	now := time.Now()
+	name := strings.NewReplacer(
+		"{table}", base,
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("15-04-05"),
+		"{user}", t.User,
+	).Replace(pattern)
+
+	return sanitizeFilename(strings.TrimSpace(name))
+}
+
+// filePath returns where a file export named base with extension ext is
+// written in dir, honouring the overwrite policy
+func (t ExportTarget) filePath(dir, base, ext string) string {
+	filename := filepath.Join(dir, t.Filename(base)+ext)
+	if t.Policy == OverwriteExisting {
+		return filename
+	}
+	return uniqueFilename(filename, ext)
+}
+
+// folderPath returns the folder a folder export named base is written to in dir
+func (t ExportTarget) folderPath(dir, base string) string {
+	return filepath.Join(dir, t.Filename(base))
+}
+
+// perTable returns a target for exports that write one file per table. A
+// fixed name would make the files collide, so the table name is appended.
+func (t ExportTarget) perTable() ExportTarget {
+	if t.Name != "" && !strings.Contains(t.Name, "{table}") {
+		t.Name += "-{table}"
+	}
+	return t
+}
+
+// ExportDestination is a named place exports can be written to: one of the
+// built-in locations or a folder given by Path
+type ExportDestination struct {
+	Name     string
+	Location ExportLocation
+	Path     string
+}
+
+// ExportDestinations lists the built-in locations followed by the named
+// destinations from exports_config.json
+func ExportDestinations() []ExportDestination {
+	destinations := []ExportDestination{
+		{Name: "Default (config folder)", Location: DefaultLocation},
+		{Name: "Desktop", Location: DesktopLocation},
+		{Name: "Both config folder and desktop", Location: BothLocations},
+	}
+
+	if exportsConfig, err := config.LoadExportsConfig(); err == nil {
+		for _, destination := range exportsConfig.Destinations {
+			if destination.Path == "" {
+				continue
+			}
+			name := destination.Name
+			if name == "" {
+				name = destination.Path
+			}
+			destinations = append(destinations, ExportDestination{Name: name, Location: PathLocation, Path: destination.Path})
+		}
+	}
+
+	return destinations
+}
+
+// Target resolves the destination to an export target, creating its folder
+// if needed
+func (d ExportDestination) Target() (ExportTarget, error) {
+	if d.Location != PathLocation {
+		return d.Location.Target()
+	}
+
+	dir, err := ExpandHome(strings.TrimSpace(d.Path))
+	if err != nil {
+		return ExportTarget{}, err
+	}
+	if dir == "" {
+		return ExportTarget{}, fmt.Errorf("enter a folder to export to")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return ExportTarget{}, err
+	}
+
+	return newExportTarget([]string{dir}), nil
+}
+
+// AddExportDestination saves path as a named destination in
+// exports_config.json, replacing a destination with the same name
+func AddExportDestination(name, path string) error {
+	exportsConfig, err := config.LoadExportsConfig()
+	if err != nil {
+		return err
+	}
+
+	destination := config.ExportDestination{Name: name, Path: path}
+	for i, existing := range exportsConfig.Destinations {
+		if existing.Name == name {
+			exportsConfig.Destinations[i] = destination
+			return config.SaveExportsConfig(exportsConfig)
+		}
+	}
+
+	exportsConfig.Destinations = append(exportsConfig.Destinations, destination)
+	return config.SaveExportsConfig(exportsConfig)
+}
+
+// ExpandHome replaces a leading ~ in path with the home directory
+func ExpandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
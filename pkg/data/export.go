@@ -0,0 +1,48 @@
+package data
+
+import "errors"
+
+// ExportTablesAs exports the given tables, or all tables when tableIDs is
+// empty, in the given format to the target. The target's {user} placeholder
+// defaults to exportedBy.
+func ExportTablesAs(tableIDs []uint, exportedBy string, target ExportTarget, format ExportFormat) (string, error) {
+	if target.User == "" {
+		target.User = exportedBy
+	}
+
+	switch format {
+	case ThighpadFormat:
+		return ExportThighpadFiles(tableIDs, exportedBy, target)
+	case MarkdownFormat:
+		return ExportToMarkdown(tableIDs, target)
+	case HTMLFormat:
+		return ExportToHTML(tableIDs, exportedBy, target, false)
+	case PrintHTMLFormat:
+		return ExportToHTML(tableIDs, exportedBy, target, true)
+	case SiteFormat:
+		return ExportToSite(tableIDs, exportedBy, target)
+	case CSVFormat, JSONLFormat:
+		return exportTabular(tableIDs, target, format)
+	case BundleFormat:
+		return ExportBundle(tableIDs, exportedBy, target)
+	}
+	return "", errors.New("unsupported export format")
+}
+
+// ExportTableAs exports a table in the given format to the specified location
+func ExportTableAs(tableID uint, exportedBy string, location ExportLocation, format ExportFormat) (string, error) {
+	target, err := location.Target()
+	if err != nil {
+		return "", err
+	}
+	return ExportTablesAs([]uint{tableID}, exportedBy, target, format)
+}
+
+// ExportAllAs exports every table in the given format to the specified location
+func ExportAllAs(exportedBy string, location ExportLocation, format ExportFormat) (string, error) {
+	target, err := location.Target()
+	if err != nil {
+		return "", err
+	}
+	return ExportTablesAs(nil, exportedBy, target, format)
+}
@@ -0,0 +1,439 @@
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/database"
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+const (
+	FormatName    = "thighpad"
+	FileVersionV1 = "1.0"
+	FileVersionV2 = "2.0"
+
+	checksumPrefix = "sha256:"
+)
+
+var (
+	uuidPattern      = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	arrayIndexInPath = regexp.MustCompile(`\.(\d+)`)
+)
+
+// ThighpadFileV2 is the current .thighpad format. Unlike version 1 it does not
+// carry database IDs: tables and entries are identified by UUIDs that stay the
+// same across exports, so a re-exported table can be matched to an earlier one.
+// The checksum covers table and entries and detects truncated or edited files.
+type ThighpadFileV2 struct {
+	Format   string            `json:"format"`
+	Version  string            `json:"version"`
+	Meta     ThighpadMetaV2    `json:"meta"`
+	Table    ThighpadTableV2   `json:"table"`
+	Entries  []ThighpadEntryV2 `json:"entries"`
+	Checksum string            `json:"checksum"`
+}
+
+type ThighpadMetaV2 struct {
+	ExportedAt time.Time `json:"exportedAt"`
+	ExportedBy string    `json:"exportedBy"`
+}
+
+type ThighpadTableV2 struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ThighpadEntryV2 struct {
+	ID          string                 `json:"id"`
+	Title       string                 `json:"title"`
+	Tags        []string               `json:"tags"`
+	Content     string                 `json:"content"`
+	Language    string                 `json:"language,omitempty"`
+	CreatedAt   time.Time              `json:"createdAt"`
+	UpdatedAt   time.Time              `json:"updatedAt"`
+	Attachments []ThighpadAttachmentV2 `json:"attachments,omitempty"`
+}
+
+// ThighpadAttachmentV2 describes a file attached to an entry. Data holds the
+// base64 encoded content and may be left out to export metadata only.
+type ThighpadAttachmentV2 struct {
+	Filename string `json:"filename"`
+	MimeType string `json:"mimeType"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+	Data     string `json:"data,omitempty"`
+}
+
+// ValidationError points at the offending field using a JSON-path like
+// location such as "entries[3].createdAt".
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "invalid " + FileExtension + " file: " + strings.Join(messages, "; ")
+}
+
+// NewThighpadFileV2 builds a version 2 document for table and its entries
+func NewThighpadFileV2(table models.Table, entries []models.Entry, exportedBy string) ThighpadFileV2 {
+	file := ThighpadFileV2{
+		Format:  FormatName,
+		Version: FileVersionV2,
+		Meta: ThighpadMetaV2{
+			ExportedAt: time.Now(),
+			ExportedBy: exportedBy,
+		},
+		Table: ThighpadTableV2{
+			ID:        table.UUID,
+			Name:      table.Name,
+			Author:    table.Author,
+			CreatedAt: table.CreatedAt,
+		},
+		Entries: make([]ThighpadEntryV2, 0, len(entries)),
+	}
+
+	for _, entry := range entries {
+		updated := entry.UpdatedAt
+		if updated.IsZero() {
+			updated = entry.CreatedAt
+		}
+
+		file.Entries = append(file.Entries, ThighpadEntryV2{
+			ID:          entry.UUID,
+			Title:       entry.Title,
+			Tags:        nonNilTags(splitTags(entry.Tags)),
+			Content:     entry.Content,
+			Language:    entry.Language,
+			CreatedAt:   entry.CreatedAt,
+			UpdatedAt:   updated,
+			Attachments: attachmentsToV2(entry.Attachments),
+		})
+	}
+
+	file.Checksum = file.ComputeChecksum()
+	return file
+}
+
+// attachmentsToV2 embeds the attachments' content. Attachments whose blob is
+// missing are exported as metadata only.
+func attachmentsToV2(attachments []models.Attachment) []ThighpadAttachmentV2 {
+	var result []ThighpadAttachmentV2
+	for _, attachment := range attachments {
+		exported := ThighpadAttachmentV2{
+			Filename: attachment.Filename,
+			MimeType: attachment.MimeType,
+			Size:     attachment.Size,
+			SHA256:   attachment.Hash,
+		}
+		if content, err := database.ReadBlob(attachment.Hash); err == nil {
+			exported.Data = base64.StdEncoding.EncodeToString(content)
+		}
+		result = append(result, exported)
+	}
+	return result
+}
+
+func nonNilTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// ComputeChecksum hashes the canonical JSON encoding of table and entries
+func (f ThighpadFileV2) ComputeChecksum() string {
+	payload, _ := json.Marshal(struct {
+		Table   ThighpadTableV2   `json:"table"`
+		Entries []ThighpadEntryV2 `json:"entries"`
+	}{f.Table, f.Entries})
+
+	sum := sha256.Sum256(payload)
+	return checksumPrefix + hex.EncodeToString(sum[:])
+}
+
+// ToModels converts the document to a table and entries ready to be stored
+func (f ThighpadFileV2) ToModels() (models.Table, []models.Entry) {
+	table := models.Table{
+		UUID:      f.Table.ID,
+		Name:      f.Table.Name,
+		Author:    f.Table.Author,
+		CreatedAt: f.Table.CreatedAt,
+	}
+
+	entries := make([]models.Entry, len(f.Entries))
+	for i, entry := range f.Entries {
+		entries[i] = models.Entry{
+			UUID:      entry.ID,
+			Title:     entry.Title,
+			Tags:      joinTags(entry.Tags),
+			Content:   entry.Content,
+			Language:  NormalizeLanguage(entry.Language),
+			CreatedAt: entry.CreatedAt,
+			UpdatedAt: entry.UpdatedAt,
+		}
+
+		for _, attachment := range entry.Attachments {
+			imported := models.Attachment{
+				Filename: attachment.Filename,
+				MimeType: attachment.MimeType,
+				Size:     attachment.Size,
+				Hash:     strings.ToLower(attachment.SHA256),
+			}
+			if content, err := base64.StdEncoding.DecodeString(attachment.Data); err == nil && attachment.Data != "" {
+				imported.Data = content
+			}
+			entries[i].Attachments = append(entries[i].Attachments, imported)
+		}
+	}
+
+	return table, entries
+}
+
+// ValidateThighpadFile checks raw file contents against the version 2 schema
+// and returns every problem found rather than stopping at the first one.
+// Version 1 files are checked for the fields the upgrade needs.
+func ValidateThighpadFile(data []byte) ValidationErrors {
+	version, err := detectFileVersion(data)
+	if err != nil {
+		return ValidationErrors{{Message: err.Error()}}
+	}
+
+	switch version {
+	case FileVersionV1:
+		var file ThighpadFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return ValidationErrors{jsonValidationError(err)}
+		}
+		return validateV1(file)
+	case FileVersionV2:
+		var file ThighpadFileV2
+		if err := json.Unmarshal(data, &file); err != nil {
+			return ValidationErrors{jsonValidationError(err)}
+		}
+		return validateV2(file)
+	}
+
+	return ValidationErrors{{Path: "version", Message: fmt.Sprintf("unsupported file version %q", version)}}
+}
+
+// detectFileVersion reads the version from the top level (version 2 and later)
+// or from meta.version (version 1).
+func detectFileVersion(data []byte) (string, error) {
+	var probe struct {
+		Version string `json:"version"`
+		Meta    struct {
+			Version string `json:"version"`
+		} `json:"meta"`
+	}
+
+	if err := json.Unmarshal(data, &probe); err != nil {
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			return "", fmt.Errorf("not valid JSON at byte %d: %v", syntaxErr.Offset, err)
+		}
+		return "", errors.New("not a " + FileExtension + " file: " + err.Error())
+	}
+
+	if probe.Version != "" {
+		return probe.Version, nil
+	}
+	if probe.Meta.Version != "" {
+		return probe.Meta.Version, nil
+	}
+	return "", errors.New("missing version, this is not a " + FileExtension + " file")
+}
+
+func jsonValidationError(err error) ValidationError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return ValidationError{
+			Path:    arrayIndexInPath.ReplaceAllString(typeErr.Field, "[$1]"),
+			Message: fmt.Sprintf("expected %s but found %s", typeErr.Type, typeErr.Value),
+		}
+	}
+	return ValidationError{Message: err.Error()}
+}
+
+func validateV1(file ThighpadFile) ValidationErrors {
+	var errs ValidationErrors
+	if strings.TrimSpace(file.Table.Name) == "" {
+		errs = append(errs, ValidationError{Path: "table.Name", Message: "is required"})
+	}
+	for i, entry := range file.Entries {
+		if strings.TrimSpace(entry.Title) == "" {
+			errs = append(errs, ValidationError{Path: fmt.Sprintf("entries[%d].Title", i), Message: "is required"})
+		}
+	}
+	return errs
+}
+
+func validateV2(file ThighpadFileV2) ValidationErrors {
+	var errs ValidationErrors
+	add := func(path, message string, args ...any) {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf(message, args...)})
+	}
+
+	if file.Format != FormatName {
+		add("format", "must be %q, found %q", FormatName, file.Format)
+	}
+	if file.Meta.ExportedAt.IsZero() {
+		add("meta.exportedAt", "is required")
+	}
+
+	if !uuidPattern.MatchString(file.Table.ID) {
+		add("table.id", "must be a UUID, found %q", file.Table.ID)
+	}
+	if strings.TrimSpace(file.Table.Name) == "" {
+		add("table.name", "is required")
+	}
+	if file.Table.CreatedAt.IsZero() {
+		add("table.createdAt", "is required")
+	}
+
+	ids := map[string]int{}
+	for i, entry := range file.Entries {
+		path := fmt.Sprintf("entries[%d]", i)
+
+		if !uuidPattern.MatchString(entry.ID) {
+			add(path+".id", "must be a UUID, found %q", entry.ID)
+		} else if first, ok := ids[strings.ToLower(entry.ID)]; ok {
+			add(path+".id", "duplicates entries[%d].id", first)
+		} else {
+			ids[strings.ToLower(entry.ID)] = i
+		}
+
+		if strings.TrimSpace(entry.Title) == "" {
+			add(path+".title", "is required")
+		}
+		if entry.Tags == nil {
+			add(path+".tags", "must be an array, use [] for no tags")
+		}
+		for j, tag := range entry.Tags {
+			if strings.TrimSpace(tag) == "" || strings.Contains(tag, ",") {
+				add(fmt.Sprintf("%s.tags[%d]", path, j), "must be non-empty and must not contain commas")
+			}
+		}
+		if entry.CreatedAt.IsZero() {
+			add(path+".createdAt", "is required")
+		}
+		if entry.UpdatedAt.IsZero() {
+			add(path+".updatedAt", "is required")
+		} else if entry.UpdatedAt.Before(entry.CreatedAt) {
+			add(path+".updatedAt", "is before createdAt")
+		}
+
+		for j, attachment := range entry.Attachments {
+			apath := fmt.Sprintf("%s.attachments[%d]", path, j)
+			if strings.TrimSpace(attachment.Filename) == "" {
+				add(apath+".filename", "is required")
+			}
+			if attachment.Size < 0 {
+				add(apath+".size", "must not be negative")
+			}
+			if len(attachment.SHA256) != 64 {
+				add(apath+".sha256", "must be a hex encoded SHA-256 hash")
+			}
+			if attachment.Data != "" {
+				content, err := base64.StdEncoding.DecodeString(attachment.Data)
+				if err != nil {
+					add(apath+".data", "must be base64 encoded")
+				} else if sum := sha256.Sum256(content); !strings.EqualFold(hex.EncodeToString(sum[:]), attachment.SHA256) {
+					add(apath+".data", "does not match sha256")
+				}
+			}
+		}
+	}
+
+	if file.Checksum == "" {
+		add("checksum", "is required")
+	} else if expected := file.ComputeChecksum(); !strings.EqualFold(file.Checksum, expected) {
+		add("checksum", "does not match the contents, the file was modified or is incomplete")
+	}
+
+	return errs
+}
+
+// upgradeV1 converts a version 1 file. Version 1 had no stable IDs, so the
+// resulting entries have none either and are matched by title when merging.
+func upgradeV1(file ThighpadFile) ThighpadFileV2 {
+	upgraded := ThighpadFileV2{
+		Format:  FormatName,
+		Version: FileVersionV2,
+		Meta: ThighpadMetaV2{
+			ExportedAt: file.Meta.ExportedAt,
+			ExportedBy: file.Meta.ExportedBy,
+		},
+		Table: ThighpadTableV2{
+			Name:      file.Table.Name,
+			Author:    file.Table.Author,
+			CreatedAt: file.Table.CreatedAt,
+		},
+	}
+
+	for _, entry := range file.Entries {
+		upgraded.Entries = append(upgraded.Entries, ThighpadEntryV2{
+			Title:     entry.Title,
+			Tags:      nonNilTags(splitTags(entry.Tags)),
+			Content:   entry.Content,
+			CreatedAt: entry.CreatedAt,
+			UpdatedAt: entry.CreatedAt,
+		})
+	}
+
+	return upgraded
+}
+
+// ReadThighpadFile reads and validates a .thighpad file of any supported
+// version and returns it in the current format.
+func ReadThighpadFile(filePath string) (ThighpadFileV2, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return ThighpadFileV2{}, err
+	}
+
+	return ParseThighpadFile(data)
+}
+
+// ParseThighpadFile is ReadThighpadFile for contents already in memory
+func ParseThighpadFile(data []byte) (ThighpadFileV2, error) {
+	if errs := ValidateThighpadFile(data); len(errs) > 0 {
+		return ThighpadFileV2{}, errs
+	}
+
+	version, _ := detectFileVersion(data)
+	if version == FileVersionV1 {
+		var file ThighpadFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return ThighpadFileV2{}, err
+		}
+		return upgradeV1(file), nil
+	}
+
+	var file ThighpadFileV2
+	err := json.Unmarshal(data, &file)
+	return file, err
+}
@@ -0,0 +1,151 @@
+package data
+
+import (
+	"strconv"
+	"strings"
+)
+
+// frontMatter is the small subset of YAML used at the top of Markdown notes:
+// scalar "key: value" pairs and lists, either inline ([a, b]) or as "- item"
+// lines below the key.
+type frontMatter struct {
+	fields map[string]string
+	lists  map[string][]string
+}
+
+// parseFrontMatter splits a Markdown document into its front matter and body.
+// Documents without a leading "---" block are returned unchanged.
+func parseFrontMatter(text string) (frontMatter, string) {
+	fm := frontMatter{fields: map[string]string{}, lists: map[string][]string{}}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if !strings.HasPrefix(text, "---\n") {
+		return fm, text
+	}
+
+	rest := text[len("---\n"):]
+	end := strings.Index(rest, "\n---")
+	if end == -1 {
+		return fm, text
+	}
+
+	header := rest[:end]
+	body := rest[end+len("\n---"):]
+	if i := strings.Index(body, "\n"); i != -1 {
+		body = body[i+1:]
+	} else {
+		body = ""
+	}
+
+	lastKey := ""
+	for _, line := range strings.Split(header, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "- ") && lastKey != "" {
+			fm.lists[lastKey] = append(fm.lists[lastKey], unquoteYAML(strings.TrimSpace(trimmed[2:])))
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		lastKey = key
+
+		if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = unquoteYAML(strings.TrimSpace(item)); item != "" {
+					fm.lists[key] = append(fm.lists[key], item)
+				}
+			}
+			continue
+		}
+
+		fm.fields[key] = unquoteYAML(value)
+	}
+
+	return fm, body
+}
+
+func (fm frontMatter) get(key string) string {
+	return fm.fields[key]
+}
+
+// list returns the values stored under key. A scalar value is treated as a
+// comma separated list so that "tags: a, b" works as well as "tags: [a, b]".
+func (fm frontMatter) list(key string) []string {
+	if values, ok := fm.lists[key]; ok {
+		return values
+	}
+
+	var values []string
+	for _, item := range strings.Split(fm.fields[key], ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+func unquoteYAML(value string) string {
+	if len(value) >= 2 {
+		switch {
+		case value[0] == '"' && value[len(value)-1] == '"':
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				return unquoted
+			}
+			return value[1 : len(value)-1]
+		case value[0] == '\'' && value[len(value)-1] == '\'':
+			return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		}
+	}
+	return value
+}
+
+func quoteYAML(value string) string {
+	return strconv.Quote(value)
+}
+
+// writeFrontMatter renders fields in the given order, followed by the tags
+// list and, if there are any, the attachment paths, as a YAML front matter block.
+func writeFrontMatter(b *strings.Builder, fields [][2]string, tags []string, attachments []string) {
+	b.WriteString("---\n")
+	for _, field := range fields {
+		b.WriteString(field[0] + ": " + quoteYAML(field[1]) + "\n")
+	}
+
+	b.WriteString("tags: " + yamlList(tags) + "\n")
+	if len(attachments) > 0 {
+		b.WriteString("attachments: " + yamlList(attachments) + "\n")
+	}
+	b.WriteString("---\n")
+}
+
+func yamlList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = quoteYAML(item)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// splitTags turns the comma separated Tags column into individual tags.
+func splitTags(tags string) []string {
+	var result []string
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// joinTags is the inverse of splitTags.
+func joinTags(tags []string) string {
+	return strings.Join(tags, ", ")
+}
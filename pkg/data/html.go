@@ -0,0 +1,413 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/s42yt/thighpads/pkg/database"
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+const (
+	HTMLExtension  = ".html"
+	AllTablesName  = "ThighPads"
+	siteFolderName = "Site"
+)
+
+type htmlTag struct {
+	Name string
+	Path string
+}
+
+type htmlEntry struct {
+	Title   string
+	Table   string
+	Anchor  string
+	Path    string
+	Created string
+	Tags    []htmlTag
+	HTML    template.HTML
+	Text    string
+
+	Attachments []htmlAttachment
+}
+
+// htmlAttachment links an attachment from an entry. Path is where a static
+// site keeps the file; URL is filled in per export, as a data URL for the
+// self-contained single page.
+type htmlAttachment struct {
+	Name     string
+	Size     string
+	MimeType string
+	Hash     string
+	Path     string
+	Image    bool
+	URL      template.URL
+}
+
+type htmlTable struct {
+	Name    string
+	Author  string
+	Created string
+	Anchor  string
+	Entries []htmlEntry
+}
+
+type htmlTagPage struct {
+	Name    string
+	Path    string
+	Entries []htmlEntry
+}
+
+type htmlPage struct {
+	Title      string
+	Root       string
+	ExportedBy string
+	ExportedAt string
+	Printable  bool
+	CSS        template.CSS
+	Tables     []htmlTable
+	Tags       []htmlTagPage
+	Entry      htmlEntry
+	Tag        htmlTagPage
+}
+
+// ExportToHTML renders tables into a single self-contained HTML file at the
+// specified location. With no tableIDs every table is exported. A printable
+// export puts every entry on its own page when printed or saved as PDF.
+func ExportToHTML(tableIDs []uint, exportedBy string, target ExportTarget, printable bool) (string, error) {
+	tables, err := tablesForExport(tableIDs)
+	if err != nil {
+		return "", err
+	}
+
+	page := newHTMLPage(tables, exportedBy)
+	page.Printable = printable
+	embedAttachments(&page)
+
+	var b strings.Builder
+	if err := htmlTemplates.ExecuteTemplate(&b, "single", page); err != nil {
+		return "", err
+	}
+
+	var lastExportedPath string
+	for _, path := range target.Dirs {
+		filename := target.filePath(path, page.Title, HTMLExtension)
+		if err := os.WriteFile(filename, []byte(b.String()), 0644); err != nil {
+			fmt.Printf("Warning: could not write to export file %s: %v\n", filename, err)
+			continue
+		}
+		lastExportedPath = filename
+	}
+
+	if lastExportedPath == "" {
+		return "", errors.New("failed to export to any location")
+	}
+
+	return lastExportedPath, nil
+}
+
+// ExportToSite renders tables into a static site folder with an index page,
+// one page per entry, tag pages and client-side search. With no tableIDs
+// every table is exported. The folder is refreshed on every export.
+func ExportToSite(tableIDs []uint, exportedBy string, target ExportTarget) (string, error) {
+	tables, err := tablesForExport(tableIDs)
+	if err != nil {
+		return "", err
+	}
+
+	page := newHTMLPage(tables, exportedBy)
+
+	var lastExportedPath string
+	for _, path := range target.Dirs {
+		dir := target.folderPath(path, page.Title+" "+siteFolderName)
+		if err := writeSite(dir, page); err != nil {
+			fmt.Printf("Warning: could not write site %s: %v\n", dir, err)
+			continue
+		}
+		lastExportedPath = dir
+	}
+
+	if lastExportedPath == "" {
+		return "", errors.New("failed to export to any location")
+	}
+
+	return lastExportedPath, nil
+}
+
+// tablesForExport loads the given tables with their entries, or all tables
+// when tableIDs is empty.
+func tablesForExport(tableIDs []uint) ([]models.Table, error) {
+	if len(tableIDs) == 0 {
+		all, err := database.GetTables()
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range all {
+			tableIDs = append(tableIDs, table.ID)
+		}
+	}
+
+	if len(tableIDs) == 0 {
+		return nil, errors.New("there are no tables to export")
+	}
+
+	tables := make([]models.Table, 0, len(tableIDs))
+	for _, id := range tableIDs {
+		table, err := database.GetTableWithEntries(id)
+		if err != nil {
+			return nil, err
+		}
+		if err := loadAttachments(table.Entries); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+
+	return tables, nil
+}
+
+func newHTMLPage(tables []models.Table, exportedBy string) htmlPage {
+	page := htmlPage{
+		Title:      AllTablesName,
+		ExportedBy: exportedBy,
+		ExportedAt: time.Now().Format("Jan 02, 2006"),
+		CSS:        template.CSS(htmlStyle),
+	}
+	if len(tables) == 1 {
+		page.Title = tables[0].Name
+	}
+
+	anchors := uniqueSlugs{}
+	tablePaths := uniqueSlugs{}
+	tagPaths := uniqueSlugs{}
+	tags := map[string]*htmlTagPage{}
+
+	for _, table := range tables {
+		tableSlug := tablePaths.next(slugify(table.Name))
+		entryPaths := uniqueSlugs{}
+
+		ht := htmlTable{
+			Name:    table.Name,
+			Author:  table.Author,
+			Created: table.CreatedAt.Format("Jan 02, 2006"),
+			Anchor:  anchors.next(slugify(table.Name)),
+		}
+
+		for _, entry := range table.Entries {
+			he := htmlEntry{
+				Title:   entry.Title,
+				Table:   table.Name,
+				Anchor:  anchors.next(slugify(table.Name + "-" + entry.Title)),
+				Path:    "entries/" + tableSlug + "/" + entryPaths.next(slugify(entry.Title)) + HTMLExtension,
+				Created: entry.CreatedAt.Format("Jan 02, 2006"),
+				HTML:    template.HTML(renderMarkdown(entry.Content)),
+				Text:    entry.Content,
+			}
+
+			files := uniqueNames{}
+			for _, attachment := range entry.Attachments {
+				name := files.next(sanitizeFilename(attachment.Filename))
+				he.Attachments = append(he.Attachments, htmlAttachment{
+					Name:     attachment.Filename,
+					Size:     FormatSize(attachment.Size),
+					MimeType: attachment.MimeType,
+					Hash:     attachment.Hash,
+					Path:     "attachments/" + strings.TrimSuffix(strings.TrimPrefix(he.Path, "entries/"), HTMLExtension) + "/" + name,
+					Image:    strings.HasPrefix(attachment.MimeType, "image/"),
+				})
+			}
+
+			for _, name := range splitTags(entry.Tags) {
+				key := strings.ToLower(name)
+				tag, ok := tags[key]
+				if !ok {
+					tag = &htmlTagPage{Name: name, Path: "tags/" + tagPaths.next(slugify(name)) + HTMLExtension}
+					tags[key] = tag
+				}
+				he.Tags = append(he.Tags, htmlTag{Name: tag.Name, Path: tag.Path})
+			}
+
+			for _, tag := range he.Tags {
+				tags[strings.ToLower(tag.Name)].Entries = append(tags[strings.ToLower(tag.Name)].Entries, he)
+			}
+
+			ht.Entries = append(ht.Entries, he)
+		}
+
+		page.Tables = append(page.Tables, ht)
+	}
+
+	for _, tag := range tags {
+		page.Tags = append(page.Tags, *tag)
+	}
+	sort.Slice(page.Tags, func(i, j int) bool {
+		return strings.ToLower(page.Tags[i].Name) < strings.ToLower(page.Tags[j].Name)
+	})
+
+	return page
+}
+
+// embedAttachments points every attachment link at a data URL holding the
+// content, so that the single page export stays self-contained
+func embedAttachments(page *htmlPage) {
+	for i := range page.Tables {
+		for j := range page.Tables[i].Entries {
+			attachments := page.Tables[i].Entries[j].Attachments
+			for k := range attachments {
+				content, err := database.ReadBlob(attachments[k].Hash)
+				if err != nil {
+					continue
+				}
+				attachments[k].URL = template.URL("data:" + attachments[k].MimeType + ";base64," + base64.StdEncoding.EncodeToString(content))
+			}
+		}
+	}
+}
+
+func writeSite(dir string, page htmlPage) error {
+	for _, sub := range []string{"entries", "tags", "attachments"} {
+		if err := os.RemoveAll(filepath.Join(dir, sub)); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "assets"), 0755); err != nil {
+		return err
+	}
+
+	write := func(path, name string, data htmlPage) error {
+		full := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return err
+		}
+
+		data.Root = strings.Repeat("../", strings.Count(path, "/"))
+
+		var b strings.Builder
+		if err := htmlTemplates.ExecuteTemplate(&b, name, data); err != nil {
+			return err
+		}
+		return os.WriteFile(full, []byte(b.String()), 0644)
+	}
+
+	if err := write("index.html", "site-index", page); err != nil {
+		return err
+	}
+	if err := write("tags/index.html", "site-tags", page); err != nil {
+		return err
+	}
+
+	type searchItem struct {
+		Title string   `json:"title"`
+		Table string   `json:"table"`
+		Tags  []string `json:"tags"`
+		URL   string   `json:"url"`
+		Text  string   `json:"text"`
+	}
+	var index []searchItem
+
+	for _, table := range page.Tables {
+		for _, entry := range table.Entries {
+			root := strings.Repeat("../", strings.Count(entry.Path, "/"))
+			for i, attachment := range entry.Attachments {
+				content, err := database.ReadBlob(attachment.Hash)
+				if err != nil {
+					continue
+				}
+				full := filepath.Join(dir, filepath.FromSlash(attachment.Path))
+				if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+					return err
+				}
+				if err := os.WriteFile(full, content, 0644); err != nil {
+					return err
+				}
+				entry.Attachments[i].URL = template.URL(root + (&url.URL{Path: attachment.Path}).EscapedPath())
+			}
+
+			entryPage := page
+			entryPage.Entry = entry
+			if err := write(entry.Path, "site-entry", entryPage); err != nil {
+				return err
+			}
+
+			item := searchItem{Title: entry.Title, Table: entry.Table, URL: entry.Path, Text: entry.Text}
+			for _, tag := range entry.Tags {
+				item.Tags = append(item.Tags, tag.Name)
+			}
+			index = append(index, item)
+		}
+	}
+
+	for _, tag := range page.Tags {
+		tagPage := page
+		tagPage.Tag = tag
+		if err := write(tag.Path, "site-tag", tagPage); err != nil {
+			return err
+		}
+	}
+
+	indexJSON, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	// The index is loaded as a script rather than fetched so that search also
+	// works when the site is opened straight from disk.
+	assets := map[string]string{
+		"style.css":       htmlStyle,
+		"search.js":       siteSearchScript,
+		"search-index.js": "window.THIGHPADS_INDEX = " + string(indexJSON) + ";\n",
+	}
+	for name, content := range assets {
+		if err := os.WriteFile(filepath.Join(dir, "assets", name), []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// slugify turns a name into something usable as a file name and URL fragment
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		slug = "untitled"
+	}
+	return slug
+}
+
+// uniqueSlugs is like uniqueNames but keeps the result URL friendly by
+// appending -2, -3, ... instead.
+type uniqueSlugs map[string]bool
+
+func (u uniqueSlugs) next(slug string) string {
+	candidate := slug
+	for i := 2; u[candidate]; i++ {
+		candidate = fmt.Sprintf("%s-%d", slug, i)
+	}
+	u[candidate] = true
+	return candidate
+}
@@ -0,0 +1,187 @@
+package data
+
+import "html/template"
+
+var htmlTemplates = template.Must(template.New("html").Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="generator" content="ThighPads">
+<title>{{.}}</title>
+{{end}}
+
+{{define "tags"}}{{if .}}<ul class="tags">{{range .}}<li>{{.Name}}</li>{{end}}</ul>{{end}}{{end}}
+
+{{define "site-tags-links"}}{{$root := .Root}}{{if .Entry.Tags}}<ul class="tags">{{range .Entry.Tags}}<li><a href="{{$root}}{{.Path}}">{{.Name}}</a></li>{{end}}</ul>{{end}}{{end}}
+
+{{define "single"}}{{template "head" .Title}}<style>{{.CSS}}{{if .Printable}}
+.entry { break-before: page; page-break-before: always; }
+.toc { break-after: page; page-break-after: always; }
+{{end}}</style>
+</head>
+<body{{if .Printable}} class="printable"{{end}}>
+<header>
+<h1>{{.Title}}</h1>
+<p class="meta">Exported by {{.ExportedBy}} on {{.ExportedAt}}</p>
+</header>
+<nav class="toc">
+<h2>Contents</h2>
+<ul>
+{{range .Tables}}<li><a href="#{{.Anchor}}">{{.Name}}</a>
+<ul>{{range .Entries}}<li><a href="#{{.Anchor}}">{{.Title}}</a></li>{{end}}</ul>
+</li>
+{{end}}</ul>
+</nav>
+<main>
+{{range .Tables}}<section class="table" id="{{.Anchor}}">
+<h2>{{.Name}}</h2>
+<p class="meta">Created by {{.Author}} on {{.Created}}</p>
+{{range .Entries}}<article class="entry" id="{{.Anchor}}">
+<h3>{{.Title}}</h3>
+<p class="meta">Created on {{.Created}}</p>
+{{template "tags" .Tags}}
+<div class="content">
+{{.HTML}}</div>
+{{template "attachments" .Attachments}}
+</article>
+{{end}}</section>
+{{end}}</main>
+</body>
+</html>
+{{end}}
+
+{{define "attachments"}}{{if .}}<div class="attachments">
+<h4>Attachments</h4>
+<ul>{{range .}}<li>{{if .URL}}<a href="{{.URL}}" download="{{.Name}}">{{.Name}}</a>{{else}}{{.Name}}{{end}} <span class="meta">{{.Size}}</span>{{if and .Image .URL}}<br><img src="{{.URL}}" alt="{{.Name}}">{{end}}</li>
+{{end}}</ul>
+</div>{{end}}{{end}}
+
+{{define "site-nav"}}<nav class="site-nav"><a href="{{.Root}}index.html">{{.Title}}</a> <a href="{{.Root}}tags/index.html">Tags</a></nav>{{end}}
+
+{{define "site-index"}}{{template "head" .Title}}<link rel="stylesheet" href="assets/style.css">
+</head>
+<body>
+{{template "site-nav" .}}
+<header>
+<h1>{{.Title}}</h1>
+<p class="meta">Exported by {{.ExportedBy}} on {{.ExportedAt}}</p>
+</header>
+<input id="search" type="search" placeholder="Search entries..." autocomplete="off">
+<ul id="results"></ul>
+<main id="listing">
+{{range .Tables}}<section class="table">
+<h2>{{.Name}}</h2>
+<p class="meta">Created by {{.Author}} on {{.Created}}</p>
+<ul>{{range .Entries}}<li><a href="{{.Path}}">{{.Title}}</a></li>{{end}}</ul>
+</section>
+{{end}}</main>
+<script src="assets/search-index.js"></script>
+<script src="assets/search.js"></script>
+</body>
+</html>
+{{end}}
+
+{{define "site-entry"}}{{template "head" .Entry.Title}}<link rel="stylesheet" href="{{.Root}}assets/style.css">
+</head>
+<body>
+{{template "site-nav" .}}
+<article class="entry">
+<p class="meta">{{.Entry.Table}}</p>
+<h1>{{.Entry.Title}}</h1>
+<p class="meta">Created on {{.Entry.Created}}</p>
+{{template "site-tags-links" .}}
+<div class="content">
+{{.Entry.HTML}}</div>
+{{template "attachments" .Entry.Attachments}}
+</article>
+</body>
+</html>
+{{end}}
+
+{{define "site-tags"}}{{template "head" "Tags"}}<link rel="stylesheet" href="{{.Root}}assets/style.css">
+</head>
+<body>
+{{template "site-nav" .}}
+<h1>Tags</h1>
+{{$root := .Root}}<ul>{{range .Tags}}<li><a href="{{$root}}{{.Path}}">{{.Name}}</a> <span class="meta">({{len .Entries}})</span></li>{{else}}<li class="meta">No tags yet.</li>{{end}}</ul>
+</body>
+</html>
+{{end}}
+
+{{define "site-tag"}}{{template "head" .Tag.Name}}<link rel="stylesheet" href="{{.Root}}assets/style.css">
+</head>
+<body>
+{{template "site-nav" .}}
+<h1>Tagged “{{.Tag.Name}}”</h1>
+{{$root := .Root}}<ul>{{range .Tag.Entries}}<li><a href="{{$root}}{{.Path}}">{{.Title}}</a> <span class="meta">{{.Table}}</span></li>{{end}}</ul>
+</body>
+</html>
+{{end}}
+`))
+
+const htmlStyle = `:root { --accent: #7D56F4; --text: #222; --subtle: #777; --code: #f4f2fb; }
+body { font-family: -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; line-height: 1.6; color: var(--text); max-width: 50rem; margin: 0 auto; padding: 2rem 1rem; }
+h1, h2, h3 { color: var(--accent); line-height: 1.25; }
+a { color: var(--accent); }
+.meta { color: var(--subtle); font-size: 0.9em; margin-top: 0; }
+.tags { list-style: none; padding: 0; margin: 0 0 1rem; }
+.tags li { display: inline-block; background: var(--code); border-radius: 1em; padding: 0 0.75em; margin: 0 0.25em 0.25em 0; font-size: 0.85em; }
+.entry { border-top: 1px solid #ddd; margin-top: 2rem; }
+.site-nav { margin-bottom: 1rem; }
+.site-nav a { margin-right: 1rem; font-weight: bold; }
+pre { background: var(--code); padding: 0.75rem 1rem; overflow-x: auto; border-radius: 4px; }
+code { background: var(--code); padding: 0.1em 0.3em; border-radius: 3px; font-size: 0.9em; }
+pre code { background: none; padding: 0; }
+blockquote { border-left: 4px solid var(--accent); margin-left: 0; padding-left: 1rem; color: #555; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.25rem 0.5rem; }
+img { max-width: 100%; }
+#search { width: 100%; font-size: 1rem; padding: 0.5rem; margin: 1rem 0; box-sizing: border-box; }
+#results:empty { display: none; }
+@media print {
+  body { max-width: none; padding: 0; }
+  a { color: inherit; text-decoration: none; }
+  .site-nav, #search, #results { display: none; }
+  pre { white-space: pre-wrap; }
+}
+@page { margin: 2cm; }
+`
+
+const siteSearchScript = `(function () {
+  var input = document.getElementById("search");
+  var results = document.getElementById("results");
+  var listing = document.getElementById("listing");
+  var index = window.THIGHPADS_INDEX || [];
+
+  input.addEventListener("input", function () {
+    var terms = input.value.toLowerCase().split(/\s+/).filter(Boolean);
+    results.innerHTML = "";
+    listing.hidden = terms.length > 0;
+    if (!terms.length) {
+      return;
+    }
+
+    index.filter(function (item) {
+      var haystack = [item.title, item.table, (item.tags || []).join(" "), item.text].join(" ").toLowerCase();
+      return terms.every(function (term) { return haystack.indexOf(term) !== -1; });
+    }).slice(0, 100).forEach(function (item) {
+      var li = document.createElement("li");
+      var a = document.createElement("a");
+      a.href = item.url;
+      a.textContent = item.title;
+      var meta = document.createElement("span");
+      meta.className = "meta";
+      meta.textContent = " " + item.table;
+      li.appendChild(a);
+      li.appendChild(meta);
+      results.appendChild(li);
+    });
+
+    if (!results.children.length) {
+      results.innerHTML = "<li class=\"meta\">No matching entries.</li>";
+    }
+  });
+})();
+`
@@ -0,0 +1,200 @@
+package data
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+type ImportSource int
+
+const (
+	ThighpadSource ImportSource = iota
+	FolderSource
+	ObsidianSource
+	JoplinSource
+	CSVSource
+	JSONLSource
+	BundleSource
+)
+
+func (s ImportSource) String() string {
+	switch s {
+	case ThighpadSource:
+		return "ThighPads file"
+	case FolderSource:
+		return "Folder of text and Markdown files"
+	case ObsidianSource:
+		return "Obsidian vault"
+	case JoplinSource:
+		return "Joplin export"
+	case CSVSource:
+		return "CSV file"
+	case JSONLSource:
+		return "JSON Lines file"
+	case BundleSource:
+		return "ThighPads bundle"
+	}
+	return "Unknown"
+}
+
+const (
+	TextExtension   = ".txt"
+	JoplinExtension = ".jex"
+)
+
+// ImportPreview holds the tables an import would create, with their entries,
+// so they can be shown to the user before anything is written.
+type ImportPreview struct {
+	Source ImportSource
+	Path   string
+	Tables []models.Table
+
+	// ExportedAt and ExportedBy are only known for ThighPads files and bundles
+	ExportedAt time.Time
+	ExportedBy string
+
+	// ConfigFiles holds the config files of a full-account bundle. They are
+	// only written back when RestoreConfig is set.
+	ConfigFiles   map[string][]byte
+	RestoreConfig bool
+}
+
+// EntryCount returns the total number of entries across all tables
+func (p *ImportPreview) EntryCount() int {
+	count := 0
+	for _, table := range p.Tables {
+		count += len(table.Entries)
+	}
+	return count
+}
+
+// Import creates the previewed tables owned by newAuthor and returns how many
+// tables were created. Config files are restored afterwards if requested.
+func (p *ImportPreview) Import(newAuthor string) (int, error) {
+	for i, table := range p.Tables {
+		if _, err := importTable(table, newAuthor, table.Entries); err != nil {
+			return i, err
+		}
+	}
+
+	if p.RestoreConfig && len(p.ConfigFiles) > 0 {
+		if err := restoreConfigFiles(p.ConfigFiles); err != nil {
+			return len(p.Tables), err
+		}
+	}
+
+	return len(p.Tables), nil
+}
+
+// PreviewImport detects what kind of data path points to and reads it without
+// touching the database. Supported are .thighpad files, Joplin JEX archives and
+// RAW export folders, Obsidian vaults, plain folders of .md/.txt files and
+// CSV/JSON Lines files, whose columns are mapped by name, and bundles holding
+// several tables.
+func PreviewImport(path string) (*ImportPreview, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var preview *ImportPreview
+	if info.IsDir() {
+		switch {
+		case isObsidianVault(path):
+			preview, err = previewObsidianVault(path)
+		case isJoplinRawExport(path):
+			preview, err = previewJoplinRaw(path)
+		default:
+			preview, err = previewFolder(path, isNoteFile)
+		}
+	} else {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case FileExtension:
+			preview, err = previewThighpadFile(path)
+		case BundleExtension:
+			preview, err = previewBundle(path)
+		case JoplinExtension:
+			preview, err = previewJoplinJEX(path)
+		case CSVExtension, JSONLExtension:
+			var tabular *TabularData
+			if tabular, err = ReadTabularFile(path); err == nil {
+				preview, err = tabular.Preview(tabular.GuessMapping())
+			}
+		default:
+			return nil, errors.New("unsupported file type, expected " + FileExtension + ", " + BundleExtension + ", " + JoplinExtension + ", " + CSVExtension + " or " + JSONLExtension)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(preview.Tables) == 0 {
+		return nil, errors.New("nothing to import in " + path)
+	}
+
+	preview.Path = path
+	return preview, nil
+}
+
+func previewThighpadFile(path string) (*ImportPreview, error) {
+	thighpadFile, err := ReadThighpadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	table, entries := thighpadFile.ToModels()
+	table.Entries = entries
+
+	return &ImportPreview{
+		Source:     ThighpadSource,
+		Tables:     []models.Table{table},
+		ExportedAt: thighpadFile.Meta.ExportedAt,
+		ExportedBy: thighpadFile.Meta.ExportedBy,
+	}, nil
+}
+
+func previewFolder(dir string, match func(name string) bool) (*ImportPreview, error) {
+	groups, err := collectMarkdownTables(dir, match)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(groups) == 0 {
+		return nil, errors.New("no notes found in " + dir)
+	}
+
+	preview := &ImportPreview{Source: FolderSource}
+	for _, group := range groups {
+		table := models.Table{Name: group.name}
+		for _, file := range group.files {
+			entry, err := markdownToEntry(file)
+			if err != nil {
+				return nil, err
+			}
+			table.Entries = append(table.Entries, entry)
+		}
+		preview.Tables = append(preview.Tables, table)
+	}
+
+	return preview, nil
+}
+
+// IsImportableFile reports whether a file can be imported on its own, judging
+// by its extension. Folders are importable as well, see PreviewImport.
+func IsImportableFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case FileExtension, BundleExtension, JoplinExtension, CSVExtension, JSONLExtension:
+		return true
+	}
+	return false
+}
+
+func isNoteFile(name string) bool {
+	return isMarkdownFile(name) || strings.ToLower(filepath.Ext(name)) == TextExtension
+}
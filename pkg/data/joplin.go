@@ -0,0 +1,227 @@
+package data
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+// Joplin item types as written in the "type_" metadata field
+const (
+	joplinNote    = "1"
+	joplinFolder  = "2"
+	joplinTag     = "5"
+	joplinNoteTag = "6"
+)
+
+const joplinDefaultTable = "Joplin"
+
+var joplinMetaPattern = regexp.MustCompile(`^([a-z_]+): ?(.*)$`)
+
+// joplinItem is one serialized Joplin object: a title line, an optional body
+// and a trailing block of "key: value" metadata lines.
+type joplinItem struct {
+	title string
+	body  string
+	meta  map[string]string
+}
+
+func parseJoplinItem(text string) (joplinItem, bool) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	metaStart := len(lines)
+	for metaStart > 0 && joplinMetaPattern.MatchString(lines[metaStart-1]) {
+		metaStart--
+	}
+
+	item := joplinItem{meta: map[string]string{}}
+	for _, line := range lines[metaStart:] {
+		match := joplinMetaPattern.FindStringSubmatch(line)
+		item.meta[match[1]] = match[2]
+	}
+
+	if item.meta["id"] == "" || item.meta["type_"] == "" {
+		return item, false
+	}
+
+	textLines := lines[:metaStart]
+	for len(textLines) > 0 && textLines[len(textLines)-1] == "" {
+		textLines = textLines[:len(textLines)-1]
+	}
+
+	if len(textLines) > 0 {
+		item.title = textLines[0]
+	}
+	if len(textLines) > 2 {
+		item.body = strings.Join(textLines[2:], "\n")
+	}
+
+	return item, true
+}
+
+func isJoplinRawExport(dir string) bool {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+MarkdownExtension))
+	if err != nil || len(files) == 0 {
+		return false
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		return false
+	}
+
+	_, ok := parseJoplinItem(string(data))
+	return ok
+}
+
+func previewJoplinRaw(dir string) (*ImportPreview, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+MarkdownExtension))
+	if err != nil {
+		return nil, err
+	}
+
+	var items []joplinItem
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if item, ok := parseJoplinItem(string(data)); ok {
+			items = append(items, item)
+		}
+	}
+
+	return buildJoplinPreview(items), nil
+}
+
+// previewJoplinJEX reads a JEX archive, which is a tar file of a RAW export
+func previewJoplinJEX(path string) (*ImportPreview, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var items []joplinItem
+	reader := tar.NewReader(file)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Typeflag != tar.TypeReg || strings.Contains(header.Name, "/") ||
+			filepath.Ext(header.Name) != MarkdownExtension {
+			continue
+		}
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		if item, ok := parseJoplinItem(string(data)); ok {
+			items = append(items, item)
+		}
+	}
+
+	return buildJoplinPreview(items), nil
+}
+
+// buildJoplinPreview maps notebooks to tables, named after their full path so
+// nested notebooks stay distinguishable, and notes to entries.
+func buildJoplinPreview(items []joplinItem) *ImportPreview {
+	folders := map[string]joplinItem{}
+	tagTitles := map[string]string{}
+	noteTags := map[string][]string{}
+	var notes []joplinItem
+
+	for _, item := range items {
+		switch item.meta["type_"] {
+		case joplinFolder:
+			folders[item.meta["id"]] = item
+		case joplinTag:
+			tagTitles[item.meta["id"]] = item.title
+		case joplinNote:
+			if item.meta["is_conflict"] == "1" || (item.meta["deleted_time"] != "" && item.meta["deleted_time"] != "0") {
+				continue
+			}
+			notes = append(notes, item)
+		}
+	}
+
+	for _, item := range items {
+		if item.meta["type_"] == joplinNoteTag {
+			noteTags[item.meta["note_id"]] = append(noteTags[item.meta["note_id"]], item.meta["tag_id"])
+		}
+	}
+
+	folderPath := func(id string) string {
+		var parts []string
+		seen := map[string]bool{}
+		for id != "" && !seen[id] {
+			folder, ok := folders[id]
+			if !ok {
+				break
+			}
+			seen[id] = true
+			parts = append([]string{folder.title}, parts...)
+			id = folder.meta["parent_id"]
+		}
+		if len(parts) == 0 {
+			return joplinDefaultTable
+		}
+		return strings.Join(parts, "/")
+	}
+
+	byTable := map[string][]models.Entry{}
+	for _, note := range notes {
+		var tags []string
+		for _, tagID := range noteTags[note.meta["id"]] {
+			if title, ok := tagTitles[tagID]; ok {
+				tags = append(tags, title)
+			}
+		}
+
+		entry := models.Entry{
+			Title:   note.title,
+			Tags:    joinTags(uniqueTags(tags)),
+			Content: note.body,
+		}
+		if created, err := time.Parse(time.RFC3339Nano, note.meta["created_time"]); err == nil {
+			entry.CreatedAt = created
+		}
+
+		name := folderPath(note.meta["parent_id"])
+		byTable[name] = append(byTable[name], entry)
+	}
+
+	names := make([]string, 0, len(byTable))
+	for name := range byTable {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	preview := &ImportPreview{Source: JoplinSource}
+	for _, name := range names {
+		entries := byTable[name]
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+		})
+		preview.Tables = append(preview.Tables, models.Table{Name: name, Entries: entries})
+	}
+
+	return preview
+}
@@ -0,0 +1,375 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/database"
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+const (
+	MarkdownExtension  = ".md"
+	MarkdownExportName = "ThighPads Markdown"
+
+	markdownAttachmentsDir = "attachments"
+	// markdownManifestName lists the files an export wrote to its folder, so
+	// that the next export into it removes those files and nothing else
+	markdownManifestName = ".thighpads-export"
+)
+
+var errForeignFolder = errors.New("folder is not empty and was not written by a ThighPads export")
+
+// ExportToMarkdown writes tables as folders of Markdown files, one per entry,
+// and returns the folder path. A single table gets a folder of its own; with
+// several tables, or none given to export all, every table becomes a
+// sub-folder of a single export folder.
+func ExportToMarkdown(tableIDs []uint, target ExportTarget) (string, error) {
+	tables, err := tablesForExport(tableIDs)
+	if err != nil {
+		return "", err
+	}
+
+	var lastExportedPath string
+	var lastErr error
+	for _, path := range target.Dirs {
+		if len(tables) == 1 {
+			dir := target.folderPath(path, tables[0].Name)
+			if err := writeMarkdownTable(tables[0], dir); err != nil {
+				fmt.Printf("Warning: could not write markdown export %s: %v\n", dir, err)
+				lastErr = fmt.Errorf("%s: %w", dir, err)
+				continue
+			}
+			lastExportedPath = dir
+			continue
+		}
+
+		root := target.folderPath(path, MarkdownExportName)
+		names := uniqueNames{}
+		failed := false
+
+		for _, table := range tables {
+			dir := filepath.Join(root, names.next(sanitizeFilename(table.Name)))
+			if err := writeMarkdownTable(table, dir); err != nil {
+				fmt.Printf("Warning: could not write markdown export %s: %v\n", dir, err)
+				lastErr = fmt.Errorf("%s: %w", dir, err)
+				failed = true
+				break
+			}
+		}
+
+		if !failed {
+			lastExportedPath = root
+		}
+	}
+
+	if lastExportedPath == "" {
+		if lastErr != nil {
+			return "", fmt.Errorf("failed to export to any location: %w", lastErr)
+		}
+		return "", errors.New("failed to export to any location")
+	}
+
+	return lastExportedPath, nil
+}
+
+// writeMarkdownTable replaces the Markdown files in dir with the entries of table.
+// Files left over from a previous export are removed so that the folder
+// always mirrors the table, which keeps diffs meaningful under git. Only files
+// listed in the manifest of that export are touched, and a folder that has
+// other files but no manifest is refused. Attachments go to
+// attachments/<note name>/ and are listed in the front matter.
+func writeMarkdownTable(table models.Table, dir string) error {
+	if err := removePreviousExport(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var written []string
+	names := uniqueNames{}
+	for _, entry := range table.Entries {
+		name := names.next(sanitizeFilename(entry.Title))
+
+		attachments, err := writeMarkdownAttachments(entry, dir, name)
+		if err != nil {
+			return err
+		}
+		written = append(written, attachments...)
+
+		filename := name + MarkdownExtension
+		if err := os.WriteFile(filepath.Join(dir, filename), []byte(entryToMarkdown(entry, attachments)), 0644); err != nil {
+			return err
+		}
+		written = append(written, filename)
+	}
+
+	return os.WriteFile(filepath.Join(dir, markdownManifestName), []byte(strings.Join(written, "\n")+"\n"), 0644)
+}
+
+// removePreviousExport deletes the files the last export into dir wrote, as
+// listed in its manifest, and the attachment folders left empty by that
+func removePreviousExport(dir string) error {
+	manifest, err := os.ReadFile(filepath.Join(dir, markdownManifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		items, err := os.ReadDir(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if len(items) > 0 {
+			return errForeignFolder
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	folders := map[string]bool{}
+	for _, rel := range strings.Split(string(manifest), "\n") {
+		rel = filepath.Clean(filepath.FromSlash(strings.TrimSpace(rel)))
+		if rel == "." || filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, rel)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		for parent := filepath.Dir(rel); parent != "."; parent = filepath.Dir(parent) {
+			folders[parent] = true
+		}
+	}
+
+	// Deepest folders first, so that their parents can be empty in turn.
+	// Folders that still hold other files stay.
+	var sorted []string
+	for folder := range folders {
+		sorted = append(sorted, folder)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, folder := range sorted {
+		os.Remove(filepath.Join(dir, folder))
+	}
+
+	return os.Remove(filepath.Join(dir, markdownManifestName))
+}
+
+// writeMarkdownAttachments writes the attachments of an entry and returns their
+// paths relative to dir, using forward slashes so that links work everywhere
+func writeMarkdownAttachments(entry models.Entry, dir, name string) ([]string, error) {
+	var paths []string
+	files := uniqueNames{}
+
+	for _, attachment := range entry.Attachments {
+		content, err := database.ReadBlob(attachment.Hash)
+		if err != nil {
+			continue
+		}
+
+		rel := markdownAttachmentsDir + "/" + name + "/" + files.next(sanitizeFilename(attachment.Filename))
+		full := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(full, content, 0644); err != nil {
+			return nil, err
+		}
+		paths = append(paths, rel)
+	}
+
+	return paths, nil
+}
+
+// readMarkdownAttachments loads the attachments listed in a note's front
+// matter. Paths are relative to the note and may not leave its folder.
+func readMarkdownAttachments(notePath string, paths []string) []models.Attachment {
+	var attachments []models.Attachment
+	base := filepath.Dir(notePath)
+
+	for _, rel := range paths {
+		rel = filepath.Clean(filepath.FromSlash(rel))
+		if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(base, rel))
+		if err != nil || len(content) > MaxAttachmentSize {
+			continue
+		}
+
+		attachments = append(attachments, models.Attachment{
+			Filename: filepath.Base(rel),
+			MimeType: detectMimeType(rel, content),
+			Size:     int64(len(content)),
+			Data:     content,
+		})
+	}
+
+	return attachments
+}
+
+func entryToMarkdown(entry models.Entry, attachments []string) string {
+	var b strings.Builder
+
+	fields := [][2]string{{"title", entry.Title}}
+	if entry.UUID != "" {
+		fields = append(fields, [2]string{"id", entry.UUID})
+	}
+	fields = append(fields, [2]string{"created", entry.CreatedAt.Format(time.RFC3339)})
+	if !entry.UpdatedAt.IsZero() {
+		fields = append(fields, [2]string{"updated", entry.UpdatedAt.Format(time.RFC3339)})
+	}
+	if entry.Language != "" {
+		fields = append(fields, [2]string{"language", entry.Language})
+	}
+
+	writeFrontMatter(&b, fields, splitTags(entry.Tags), attachments)
+
+	// The file always ends in a newline of its own, which the import strips
+	// again, so that content ending in one survives the round trip
+	b.WriteString(entry.Content)
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+// markdownToEntry parses a Markdown note. The title falls back to the file name
+// and the creation date to the file's modification time.
+func markdownToEntry(path string) (models.Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return models.Entry{}, err
+	}
+
+	fm, body := parseFrontMatter(string(data))
+
+	entry := models.Entry{
+		Title:    fm.get("title"),
+		Tags:     joinTags(fm.list("tags")),
+		Content:  strings.TrimSuffix(body, "\n"),
+		Language: NormalizeLanguage(fm.get("language")),
+	}
+
+	if entry.Title == "" {
+		entry.Title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	if id := fm.get("id"); uuidPattern.MatchString(id) {
+		entry.UUID = id
+	}
+
+	if created, err := time.Parse(time.RFC3339, fm.get("created")); err == nil {
+		entry.CreatedAt = created
+	} else if info, err := os.Stat(path); err == nil {
+		entry.CreatedAt = info.ModTime()
+	}
+
+	if updated, err := time.Parse(time.RFC3339, fm.get("updated")); err == nil {
+		entry.UpdatedAt = updated
+	}
+
+	entry.Attachments = readMarkdownAttachments(path, fm.list("attachments"))
+
+	return entry, nil
+}
+
+// ImportMarkdownDir imports a folder of Markdown files. Markdown files directly
+// inside dir become a table named after dir, and every sub-folder becomes a
+// table of its own containing all notes below it. It returns the number of
+// tables created.
+func ImportMarkdownDir(dir string, newAuthor string) (int, error) {
+	preview, err := previewFolder(dir, isMarkdownFile)
+	if err != nil {
+		return 0, err
+	}
+	return preview.Import(newAuthor)
+}
+
+type markdownTable struct {
+	name  string
+	files []string
+}
+
+func collectMarkdownTables(dir string, match func(name string) bool) ([]markdownTable, error) {
+	items, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	root := markdownTable{name: filepath.Base(filepath.Clean(dir))}
+	var tables []markdownTable
+
+	for _, item := range items {
+		if isHidden(item.Name()) || (item.IsDir() && item.Name() == markdownAttachmentsDir) {
+			continue
+		}
+
+		path := filepath.Join(dir, item.Name())
+		if !item.IsDir() {
+			if match(item.Name()) {
+				root.files = append(root.files, path)
+			}
+			continue
+		}
+
+		sub := markdownTable{name: item.Name()}
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && p != path && (isHidden(d.Name()) || d.Name() == markdownAttachmentsDir) {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && match(d.Name()) {
+				sub.files = append(sub.files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(sub.files) > 0 {
+			tables = append(tables, sub)
+		}
+	}
+
+	if len(root.files) > 0 {
+		tables = append([]markdownTable{root}, tables...)
+	}
+
+	for _, table := range tables {
+		sort.Strings(table.files)
+	}
+
+	return tables, nil
+}
+
+func isMarkdownFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == MarkdownExtension || ext == ".markdown"
+}
+
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// uniqueNames hands out file names that do not collide case-insensitively
+// with names returned earlier, appending " (2)", " (3)", ... when needed.
+type uniqueNames map[string]bool
+
+func (u uniqueNames) next(name string) string {
+	candidate := name
+	for i := 2; u[strings.ToLower(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s (%d)", name, i)
+	}
+	u[strings.ToLower(candidate)] = true
+	return candidate
+}
@@ -0,0 +1,293 @@
+package data
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// renderMarkdown converts the Markdown used in entries to HTML. It covers the
+// CommonMark basics plus GitHub style fenced code, tables, task lists and
+// strikethrough, which is what notes and runbooks typically use. Raw HTML in
+// the source is escaped rather than passed through.
+func renderMarkdown(src string) string {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	var b strings.Builder
+	renderBlocks(&b, lines)
+	return b.String()
+}
+
+var (
+	headingPattern     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	rulePattern        = regexp.MustCompile(`^\s{0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+	bulletPattern      = regexp.MustCompile(`^(\s*)([-*+])\s+(.*)$`)
+	orderedPattern     = regexp.MustCompile(`^(\s*)(\d{1,9})[.)]\s+(.*)$`)
+	tableDelimiter     = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	taskPattern        = regexp.MustCompile(`^\[([ xX])\]\s+(.*)$`)
+	imagePattern       = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)\)`)
+	linkPattern        = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	autolinkPattern    = regexp.MustCompile(`&lt;(https?://\S+?)&gt;`)
+	boldPattern        = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	italicPattern      = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+	strikePattern      = regexp.MustCompile(`~~([^~]+)~~`)
+	codeSpanPattern    = regexp.MustCompile("`([^`]+)`")
+	placeholderPattern = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+func renderBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence := trimmed[:3]
+			lang := strings.TrimSpace(trimmed[3:])
+			var code []string
+			i++
+			for i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+				code = append(code, lines[i])
+				i++
+			}
+			i++
+			class := ""
+			if lang != "" {
+				class = fmt.Sprintf(` class="language-%s"`, html.EscapeString(strings.Fields(lang)[0]))
+			}
+			fmt.Fprintf(b, "<pre><code%s>%s</code></pre>\n", class, html.EscapeString(strings.Join(code, "\n")))
+
+		case headingPattern.MatchString(trimmed):
+			match := headingPattern.FindStringSubmatch(trimmed)
+			level := len(match[1])
+			fmt.Fprintf(b, "<h%d>%s</h%d>\n", level, renderInline(match[2]), level)
+			i++
+
+		case rulePattern.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(trimmed, ">"):
+			var quote []string
+			for i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">") {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quote = append(quote, strings.TrimPrefix(q, " "))
+				i++
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quote)
+			b.WriteString("</blockquote>\n")
+
+		case bulletPattern.MatchString(line) || orderedPattern.MatchString(line):
+			i = renderList(b, lines, i)
+
+		case strings.Contains(line, "|") && i+1 < len(lines) && tableDelimiter.MatchString(lines[i+1]):
+			i = renderTable(b, lines, i)
+
+		default:
+			var para []string
+			for i < len(lines) && isParagraphLine(lines, i) {
+				para = append(para, strings.TrimSpace(lines[i]))
+				i++
+			}
+			if len(para) == 0 {
+				para = append(para, trimmed)
+				i++
+			}
+			fmt.Fprintf(b, "<p>%s</p>\n", renderInline(strings.Join(para, "\n")))
+		}
+	}
+}
+
+func isParagraphLine(lines []string, i int) bool {
+	line := lines[i]
+	trimmed := strings.TrimSpace(line)
+	return trimmed != "" &&
+		!strings.HasPrefix(trimmed, "```") && !strings.HasPrefix(trimmed, "~~~") &&
+		!headingPattern.MatchString(trimmed) &&
+		!rulePattern.MatchString(line) &&
+		!strings.HasPrefix(trimmed, ">") &&
+		!bulletPattern.MatchString(line) && !orderedPattern.MatchString(line)
+}
+
+// renderList renders the list starting at lines[start] and returns the index of
+// the first line after it. Lines indented deeper than the item marker belong to
+// that item and are rendered recursively, which is how nested lists work.
+func renderList(b *strings.Builder, lines []string, start int) int {
+	ordered := orderedPattern.MatchString(lines[start])
+	indent := listIndent(lines[start])
+
+	tag := "ul"
+	if ordered {
+		if n := orderedPattern.FindStringSubmatch(lines[start])[2]; n != "1" {
+			fmt.Fprintf(b, "<ol start=\"%s\">\n", n)
+		} else {
+			b.WriteString("<ol>\n")
+		}
+		tag = "ol"
+	} else {
+		b.WriteString("<ul>\n")
+	}
+
+	i := start
+	for i < len(lines) {
+		var text string
+		if match := bulletPattern.FindStringSubmatch(lines[i]); match != nil && !ordered && len(match[1]) == indent {
+			text = match[3]
+		} else if match := orderedPattern.FindStringSubmatch(lines[i]); match != nil && ordered && len(match[1]) == indent {
+			text = match[3]
+		} else {
+			break
+		}
+		i++
+
+		var nested []string
+		for i < len(lines) {
+			if strings.TrimSpace(lines[i]) == "" {
+				if i+1 < len(lines) && listIndent(lines[i+1]) > indent {
+					nested = append(nested, "")
+					i++
+					continue
+				}
+				break
+			}
+			if listIndent(lines[i]) <= indent {
+				break
+			}
+			nested = append(nested, dedent(lines[i], indent+2))
+			i++
+		}
+
+		b.WriteString("<li>")
+		if match := taskPattern.FindStringSubmatch(text); match != nil {
+			checked := ""
+			if match[1] != " " {
+				checked = " checked"
+			}
+			fmt.Fprintf(b, `<input type="checkbox" disabled%s> `, checked)
+			text = match[2]
+		}
+		b.WriteString(renderInline(text))
+		if len(nested) > 0 {
+			b.WriteString("\n")
+			renderBlocks(b, nested)
+		}
+		b.WriteString("</li>\n")
+	}
+
+	fmt.Fprintf(b, "</%s>\n", tag)
+	return i
+}
+
+func listIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+func dedent(line string, n int) string {
+	for n > 0 && len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+		line = line[1:]
+		n--
+	}
+	return line
+}
+
+func renderTable(b *strings.Builder, lines []string, start int) int {
+	header := splitTableRow(lines[start])
+	aligns := splitTableRow(lines[start+1])
+	for i, cell := range aligns {
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			aligns[i] = ` style="text-align:center"`
+		case strings.HasSuffix(cell, ":"):
+			aligns[i] = ` style="text-align:right"`
+		case strings.HasPrefix(cell, ":"):
+			aligns[i] = ` style="text-align:left"`
+		default:
+			aligns[i] = ""
+		}
+	}
+	align := func(col int) string {
+		if col < len(aligns) {
+			return aligns[col]
+		}
+		return ""
+	}
+
+	b.WriteString("<table>\n<thead><tr>")
+	for col, cell := range header {
+		fmt.Fprintf(b, "<th%s>%s</th>", align(col), renderInline(cell))
+	}
+	b.WriteString("</tr></thead>\n<tbody>\n")
+
+	i := start + 2
+	for i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != "" {
+		b.WriteString("<tr>")
+		for col, cell := range splitTableRow(lines[i]) {
+			fmt.Fprintf(b, "<td%s>%s</td>", align(col), renderInline(cell))
+		}
+		b.WriteString("</tr>\n")
+		i++
+	}
+
+	b.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+
+	cells := strings.Split(line, "|")
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(cell)
+	}
+	return cells
+}
+
+// renderInline escapes text and applies inline formatting. Code spans are
+// swapped out for placeholders first so their content is left untouched.
+func renderInline(text string) string {
+	var spans []string
+	text = codeSpanPattern.ReplaceAllStringFunc(text, func(m string) string {
+		spans = append(spans, "<code>"+html.EscapeString(m[1:len(m)-1])+"</code>")
+		return fmt.Sprintf("\x00%d\x00", len(spans)-1)
+	})
+
+	text = html.EscapeString(text)
+
+	text = imagePattern.ReplaceAllStringFunc(text, func(m string) string {
+		match := imagePattern.FindStringSubmatch(m)
+		return fmt.Sprintf(`<img src="%s" alt="%s">`, safeURL(match[2]), match[1])
+	})
+	text = linkPattern.ReplaceAllStringFunc(text, func(m string) string {
+		match := linkPattern.FindStringSubmatch(m)
+		return fmt.Sprintf(`<a href="%s">%s</a>`, safeURL(match[2]), match[1])
+	})
+	text = autolinkPattern.ReplaceAllString(text, `<a href="$1">$1</a>`)
+	text = boldPattern.ReplaceAllString(text, "<strong>$1$2</strong>")
+	text = italicPattern.ReplaceAllString(text, "<em>$1$2</em>")
+	text = strikePattern.ReplaceAllString(text, "<del>$1</del>")
+	text = strings.ReplaceAll(text, "\n", "<br>\n")
+
+	return placeholderPattern.ReplaceAllStringFunc(text, func(m string) string {
+		var index int
+		fmt.Sscanf(placeholderPattern.FindStringSubmatch(m)[1], "%d", &index)
+		return spans[index]
+	})
+}
+
+// safeURL drops URLs with schemes that could run script when clicked
+func safeURL(url string) string {
+	lower := strings.ToLower(strings.TrimSpace(html.UnescapeString(url)))
+	if i := strings.Index(lower, ":"); i != -1 && !strings.ContainsAny(lower[:i], "/?#") {
+		scheme := lower[:i]
+		if scheme != "http" && scheme != "https" && scheme != "mailto" {
+			return "#"
+		}
+	}
+	return url
+}
@@ -0,0 +1,226 @@
+package data
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/database"
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+type MergeResolution int
+
+const (
+	KeepMine MergeResolution = iota
+	TakeTheirs
+	KeepBoth
+)
+
+func (r MergeResolution) String() string {
+	switch r {
+	case KeepMine:
+		return "Keep mine"
+	case TakeTheirs:
+		return "Take theirs"
+	case KeepBoth:
+		return "Keep both"
+	}
+	return "Unknown"
+}
+
+// MergeConflict is an entry that exists in both the table and the import with
+// different title, tags or content.
+type MergeConflict struct {
+	Mine       models.Entry
+	Theirs     models.Entry
+	Resolution MergeResolution
+}
+
+// MergePlan describes how an import would be merged into an existing table.
+// Conflicts start out resolved in favour of the more recently updated side
+// and can be changed before calling Apply.
+type MergePlan struct {
+	Table     models.Table
+	New       []models.Entry
+	Unchanged int
+	Conflicts []MergeConflict
+}
+
+// MergeResult counts what Apply changed
+type MergeResult struct {
+	Added   int
+	Updated int
+	Kept    int
+}
+
+// SuggestMergeTarget suggests the existing table a single-table import should
+// be merged into: the table it was originally exported from if present,
+// otherwise one with the same name.
+func (p *ImportPreview) SuggestMergeTarget() (models.Table, bool) {
+	if len(p.Tables) != 1 {
+		return models.Table{}, false
+	}
+
+	incoming := p.Tables[0]
+	if incoming.UUID != "" {
+		if table, err := database.FindTableByUUID(incoming.UUID); err == nil {
+			return table, true
+		}
+	}
+
+	tables, err := database.GetTables()
+	if err != nil {
+		return models.Table{}, false
+	}
+	for _, table := range tables {
+		if strings.EqualFold(strings.TrimSpace(table.Name), strings.TrimSpace(incoming.Name)) {
+			return table, true
+		}
+	}
+
+	return models.Table{}, false
+}
+
+// PlanMerge matches the single table of an import against an existing table.
+// Entries are matched by stable ID first and by title for entries without a
+// match, e.g. those coming from version 1 files.
+func (p *ImportPreview) PlanMerge(tableID uint) (*MergePlan, error) {
+	if len(p.Tables) != 1 {
+		return nil, errors.New("only imports with a single table can be merged")
+	}
+
+	table, err := database.GetTableWithEntries(tableID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &MergePlan{Table: table}
+	matched := make([]bool, len(table.Entries))
+
+	byUUID := map[string]int{}
+	byTitle := map[string][]int{}
+	for i, entry := range table.Entries {
+		if entry.UUID != "" {
+			byUUID[entry.UUID] = i
+		}
+		key := titleKey(entry.Title)
+		byTitle[key] = append(byTitle[key], i)
+	}
+
+	for _, theirs := range p.Tables[0].Entries {
+		index := -1
+		if i, ok := byUUID[theirs.UUID]; ok && theirs.UUID != "" && !matched[i] {
+			index = i
+		} else {
+			for _, i := range byTitle[titleKey(theirs.Title)] {
+				if !matched[i] {
+					index = i
+					break
+				}
+			}
+		}
+
+		if index == -1 {
+			plan.New = append(plan.New, theirs)
+			continue
+		}
+
+		matched[index] = true
+		mine := table.Entries[index]
+
+		if sameEntryContent(mine, theirs) {
+			plan.Unchanged++
+			continue
+		}
+
+		resolution := KeepMine
+		if theirs.UpdatedAt.After(mine.UpdatedAt) {
+			resolution = TakeTheirs
+		}
+		plan.Conflicts = append(plan.Conflicts, MergeConflict{Mine: mine, Theirs: theirs, Resolution: resolution})
+	}
+
+	return plan, nil
+}
+
+// Apply writes the merge to the database
+func (plan *MergePlan) Apply() (MergeResult, error) {
+	var result MergeResult
+
+	existing := map[string]bool{}
+	for _, entry := range plan.Table.Entries {
+		existing[entry.UUID] = true
+	}
+
+	add := func(entry models.Entry, keepUUID bool) error {
+		entry.ID = 0
+		entry.TableID = plan.Table.ID
+		if !keepUUID || existing[entry.UUID] {
+			entry.UUID = ""
+		}
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = time.Now()
+		}
+		if entry.UpdatedAt.IsZero() {
+			entry.UpdatedAt = entry.CreatedAt
+		}
+		if err := database.CreateEntry(&entry); err != nil {
+			return err
+		}
+		if err := storeAttachments(entry.ID, entry.Attachments, nil); err != nil {
+			return err
+		}
+		existing[entry.UUID] = true
+		result.Added++
+		return nil
+	}
+
+	for _, entry := range plan.New {
+		if err := add(entry, true); err != nil {
+			return result, err
+		}
+	}
+
+	for _, conflict := range plan.Conflicts {
+		switch conflict.Resolution {
+		case KeepMine:
+			result.Kept++
+		case TakeTheirs:
+			updated := conflict.Mine
+			updated.Title = conflict.Theirs.Title
+			updated.Tags = conflict.Theirs.Tags
+			updated.Content = conflict.Theirs.Content
+			updated.Language = conflict.Theirs.Language
+			if err := database.UpdateEntry(&updated); err != nil {
+				return result, err
+			}
+			mine, err := database.GetAttachments(updated.ID)
+			if err != nil {
+				return result, err
+			}
+			if err := storeAttachments(updated.ID, conflict.Theirs.Attachments, mine); err != nil {
+				return result, err
+			}
+			result.Updated++
+		case KeepBoth:
+			result.Kept++
+			if err := add(conflict.Theirs, false); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func titleKey(title string) string {
+	return strings.ToLower(strings.TrimSpace(title))
+}
+
+func sameEntryContent(a, b models.Entry) bool {
+	return a.Title == b.Title &&
+		joinTags(splitTags(a.Tags)) == joinTags(splitTags(b.Tags)) &&
+		a.Language == b.Language &&
+		strings.TrimRight(a.Content, "\n") == strings.TrimRight(b.Content, "\n")
+}
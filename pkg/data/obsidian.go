@@ -0,0 +1,173 @@
+package data
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+const obsidianConfigDir = ".obsidian"
+
+// inlineTagPattern matches Obsidian #tags. A tag needs at least one character
+// that is not a digit, so "#123" is not treated as a tag.
+var inlineTagPattern = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_/-]*[\p{L}_/-][\p{L}\p{N}_/-]*)`)
+
+func isObsidianVault(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, obsidianConfigDir))
+	return err == nil && info.IsDir()
+}
+
+// previewObsidianVault turns every folder of the vault that contains notes into
+// a table named after its path inside the vault. Notes in the vault root go
+// into a table named after the vault itself.
+func previewObsidianVault(vault string) (*ImportPreview, error) {
+	vaultName := filepath.Base(filepath.Clean(vault))
+	byFolder := map[string][]string{}
+
+	err := filepath.WalkDir(vault, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != vault && isHidden(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isMarkdownFile(d.Name()) {
+			folder, _ := filepath.Rel(vault, filepath.Dir(path))
+			byFolder[folder] = append(byFolder[folder], path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	folders := make([]string, 0, len(byFolder))
+	for folder := range byFolder {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+
+	preview := &ImportPreview{Source: ObsidianSource}
+	for _, folder := range folders {
+		name := vaultName
+		if folder != "." {
+			name = filepath.ToSlash(folder)
+		}
+
+		files := byFolder[folder]
+		sort.Strings(files)
+
+		table := models.Table{Name: name}
+		for _, file := range files {
+			entry, err := obsidianNoteToEntry(file)
+			if err != nil {
+				return nil, err
+			}
+			table.Entries = append(table.Entries, entry)
+		}
+		preview.Tables = append(preview.Tables, table)
+	}
+
+	return preview, nil
+}
+
+// obsidianNoteToEntry reads a note, using the file name as title like Obsidian
+// does, and collects tags from both the front matter and inline #tags.
+func obsidianNoteToEntry(path string) (models.Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return models.Entry{}, err
+	}
+
+	fm, body := parseFrontMatter(string(data))
+
+	entry := models.Entry{
+		Title:   strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Content: strings.TrimSuffix(body, "\n"),
+	}
+
+	var tags []string
+	for _, key := range []string{"tags", "tag"} {
+		if values, ok := fm.lists[key]; ok {
+			tags = append(tags, values...)
+		} else {
+			tags = append(tags, strings.FieldsFunc(fm.get(key), func(r rune) bool {
+				return r == ',' || r == ' '
+			})...)
+		}
+	}
+	tags = append(tags, inlineTags(body)...)
+	entry.Tags = joinTags(uniqueTags(tags))
+
+	if info, err := os.Stat(path); err == nil {
+		entry.CreatedAt = info.ModTime()
+	}
+	for _, key := range []string{"created", "date"} {
+		if created, err := parseNoteDate(fm.get(key)); err == nil {
+			entry.CreatedAt = created
+			break
+		}
+	}
+
+	return entry, nil
+}
+
+// inlineTags returns the #tags found in text outside of fenced code blocks
+func inlineTags(text string) []string {
+	var tags []string
+	inFence := false
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		for _, match := range inlineTagPattern.FindAllStringSubmatch(line, -1) {
+			tags = append(tags, match[1])
+		}
+	}
+
+	return tags
+}
+
+// uniqueTags drops empty and duplicate tags, ignoring case and a leading '#'
+func uniqueTags(tags []string) []string {
+	seen := map[string]bool{}
+	var result []string
+
+	for _, tag := range tags {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		seen[strings.ToLower(tag)] = true
+		result = append(result, tag)
+	}
+
+	return result
+}
+
+func parseNoteDate(value string) (time.Time, error) {
+	var err error
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02"} {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/s42yt/thighpads/pkg/config"
@@ -13,6 +14,8 @@ import (
 	"github.com/s42yt/thighpads/pkg/models"
 )
 
+// ThighpadFile is the version 1 file format. It is no longer written but is
+// still read and upgraded on import, see ThighpadFileV2.
 type ThighpadFile struct {
 	Table   models.Table     `json:"table"`
 	Entries []models.Entry   `json:"entries"`
@@ -27,15 +30,54 @@ type ThighpadFileMeta struct {
 
 type ExportLocation int
 
+type ExportFormat int
+
 const (
 	DefaultLocation ExportLocation = iota
 	DesktopLocation
 	BothLocations
+	PathLocation
+)
+
+const (
+	ThighpadFormat ExportFormat = iota
+	MarkdownFormat
+	HTMLFormat
+	PrintHTMLFormat
+	SiteFormat
+	CSVFormat
+	JSONLFormat
+	BundleFormat
 )
 
+// ExportFormats lists the formats in the order the export screen cycles them
+var ExportFormats = []ExportFormat{ThighpadFormat, MarkdownFormat, HTMLFormat, PrintHTMLFormat, SiteFormat, CSVFormat, JSONLFormat, BundleFormat}
+
+func (f ExportFormat) String() string {
+	switch f {
+	case ThighpadFormat:
+		return "ThighPads (" + FileExtension + ")"
+	case MarkdownFormat:
+		return "Markdown folder"
+	case HTMLFormat:
+		return "HTML page"
+	case PrintHTMLFormat:
+		return "Print-ready HTML (for PDF)"
+	case SiteFormat:
+		return "Static website"
+	case CSVFormat:
+		return "CSV spreadsheet"
+	case JSONLFormat:
+		return "JSON Lines"
+	case BundleFormat:
+		return "Bundle (" + BundleExtension + ")"
+	}
+	return "Unknown"
+}
+
 const (
 	FileExtension = ".thighpad"
-	FileVersion   = "1.0"
+	FileVersion   = FileVersionV2
 )
 
 // ExportTable exports a table to the default location
@@ -50,86 +92,58 @@ func ExportTableToDesktop(tableID uint, exportedBy string) (string, error) {
 
 // ExportTableToLocation exports a table to the specified location
 func ExportTableToLocation(tableID uint, exportedBy string, location ExportLocation) (string, error) {
-	table, err := database.GetTableWithEntries(tableID)
+	target, err := location.Target()
 	if err != nil {
 		return "", err
 	}
+	target.User = exportedBy
+	return ExportThighpadFiles([]uint{tableID}, exportedBy, target)
+}
 
-	thighpadFile := ThighpadFile{
-		Table:   table,
-		Entries: table.Entries,
-		Meta: ThighpadFileMeta{
-			ExportedAt: time.Now(),
-			ExportedBy: exportedBy,
-			Version:    FileVersion,
-		},
-	}
-
-	data, err := json.MarshalIndent(thighpadFile, "", "  ")
+// ExportAllToLocation exports every table to its own file at the specified location
+func ExportAllToLocation(exportedBy string, location ExportLocation) (string, error) {
+	target, err := location.Target()
 	if err != nil {
 		return "", err
 	}
+	target.User = exportedBy
+	return ExportThighpadFiles(nil, exportedBy, target)
+}
 
-	// Create a safe filename without special characters
-	safeFilename := sanitizeFilename(table.Name)
-
-	// Get export paths based on location
-	paths := []string{}
-
-	if location == DefaultLocation || location == BothLocations {
-		defaultPath, err := config.GetExportPath()
-		if err != nil {
-			return "", err
-		}
-		paths = append(paths, defaultPath)
-	}
-
-	if location == DesktopLocation || location == BothLocations {
-		desktopPath, err := config.GetDesktopExportPath()
-		if err != nil {
-			// If desktop path fails, just log it but continue with default path
-			fmt.Printf("Warning: could not get desktop export path: %v\n", err)
-		} else {
-			paths = append(paths, desktopPath)
-		}
+// ExportThighpadFiles exports each of the given tables, or all tables when
+// tableIDs is empty, to its own file in the target directories
+func ExportThighpadFiles(tableIDs []uint, exportedBy string, target ExportTarget) (string, error) {
+	tables, err := tablesForExport(tableIDs)
+	if err != nil {
+		return "", err
 	}
 
-	if len(paths) == 0 {
-		return "", errors.New("no valid export paths available")
+	if len(tables) > 1 {
+		target = target.perTable()
 	}
 
 	// Keep track of the last path exported to
 	var lastExportedPath string
 
-	// Export to all paths
-	for _, path := range paths {
-		// Ensure directory exists
-		if _, err := os.Stat(path); os.IsNotExist(err) {
-			if err := os.MkdirAll(path, 0755); err != nil {
-				fmt.Printf("Warning: could not create export directory %s: %v\n", path, err)
-				continue
-			}
+	for _, table := range tables {
+		thighpadFile := NewThighpadFileV2(table, table.Entries, exportedBy)
+
+		data, err := json.MarshalIndent(thighpadFile, "", "  ")
+		if err != nil {
+			return "", err
 		}
 
-		filename := filepath.Join(path, safeFilename+FileExtension)
+		// Export to all paths
+		for _, path := range target.Dirs {
+			filename := target.filePath(path, table.Name, FileExtension)
 
-		// Check if file exists and append a number to make it unique
-		counter := 1
-		originalFilename := filename
-		for {
-			if _, err := os.Stat(filename); os.IsNotExist(err) {
-				break
+			if err := os.WriteFile(filename, data, 0644); err != nil {
+				fmt.Printf("Warning: could not write to export file %s: %v\n", filename, err)
+				continue
 			}
-			filename = fmt.Sprintf("%s_%d%s", originalFilename[:len(originalFilename)-len(FileExtension)], counter, FileExtension)
-			counter++
-		}
 
-		if err := os.WriteFile(filename, data, 0644); err != nil {
-			fmt.Printf("Warning: could not write to export file %s: %v\n", filename, err)
-			continue
+			lastExportedPath = filename
 		}
-
-		lastExportedPath = filename
 	}
 
 	if lastExportedPath == "" {
@@ -140,48 +154,119 @@ func ExportTableToLocation(tableID uint, exportedBy string, location ExportLocat
 }
 
 func ImportFile(filePath string, newAuthor string) error {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return err
-	}
-
-	var thighpadFile ThighpadFile
-	err = json.Unmarshal(data, &thighpadFile)
+	thighpadFile, err := ReadThighpadFile(filePath)
 	if err != nil {
 		return err
 	}
 
-	if thighpadFile.Meta.Version != FileVersion {
-		return errors.New("unsupported file version")
-	}
+	table, entries := thighpadFile.ToModels()
+	_, err = importTable(table, newAuthor, entries)
+	return err
+}
 
+// importTable creates a new table owned by author and adds entries to it.
+// Stable UUIDs are kept so later imports can be matched against this table,
+// unless a table or entry with the same UUID already exists. Entries without
+// a creation date are stamped with the current time, and their attachments
+// are stored.
+func importTable(table models.Table, author string, entries []models.Entry) (models.Table, error) {
 	newTable := models.Table{
-		Name:      thighpadFile.Table.Name,
-		Author:    newAuthor,
+		UUID:      table.UUID,
+		Name:      table.Name,
+		Author:    author,
 		CreatedAt: time.Now(),
 	}
 
-	err = database.CreateTable(&newTable)
+	if newTable.UUID != "" {
+		if _, err := database.FindTableByUUID(newTable.UUID); err == nil {
+			newTable.UUID = ""
+		}
+	}
+
+	err := database.CreateTable(&newTable)
 	if err != nil {
-		return err
+		return newTable, err
 	}
 
-	for _, entry := range thighpadFile.Entries {
-		newEntry := models.Entry{
-			TableID:   newTable.ID,
-			Title:     entry.Title,
-			Tags:      entry.Tags,
-			Content:   entry.Content,
-			CreatedAt: time.Now(),
+	for _, entry := range entries {
+		entry.ID = 0
+		entry.TableID = newTable.ID
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = time.Now()
+		}
+		if entry.UpdatedAt.IsZero() {
+			entry.UpdatedAt = entry.CreatedAt
+		}
+		if entry.UUID != "" {
+			if _, err := database.FindEntryByUUID(entry.UUID); err == nil {
+				entry.UUID = ""
+			}
+		}
+
+		err = database.CreateEntry(&entry)
+		if err != nil {
+			return newTable, err
+		}
+
+		if err := storeAttachments(entry.ID, entry.Attachments, nil); err != nil {
+			return newTable, err
+		}
+	}
+
+	return newTable, nil
+}
+
+// exportPaths resolves an export location to the directories it refers to,
+// creating them if needed. Directories that cannot be created are skipped.
+func exportPaths(location ExportLocation) ([]string, error) {
+	paths := []string{}
+
+	if location == DefaultLocation || location == BothLocations {
+		defaultPath, err := config.GetExportPath()
+		if err != nil {
+			return nil, err
 		}
+		paths = append(paths, defaultPath)
+	}
 
-		err = database.CreateEntry(&newEntry)
+	if location == DesktopLocation || location == BothLocations {
+		desktopPath, err := config.GetDesktopExportPath()
 		if err != nil {
-			return err
+			// If desktop path fails, just log it but continue with default path
+			fmt.Printf("Warning: could not get desktop export path: %v\n", err)
+		} else {
+			paths = append(paths, desktopPath)
 		}
 	}
 
-	return nil
+	var available []string
+	for _, path := range paths {
+		// Ensure directory exists
+		if err := os.MkdirAll(path, 0755); err != nil {
+			fmt.Printf("Warning: could not create export directory %s: %v\n", path, err)
+			continue
+		}
+		available = append(available, path)
+	}
+
+	if len(available) == 0 {
+		return nil, errors.New("no valid export paths available")
+	}
+
+	return available, nil
+}
+
+// uniqueFilename appends _1, _2, ... before ext until filename does not exist
+func uniqueFilename(filename, ext string) string {
+	base := strings.TrimSuffix(filename, ext)
+	counter := 1
+	for {
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			return filename
+		}
+		filename = fmt.Sprintf("%s_%d%s", base, counter, ext)
+		counter++
+	}
 }
 
 // sanitizeFilename removes or replaces characters that are not safe for filenames
@@ -0,0 +1,72 @@
+package data
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/config"
+	"github.com/s42yt/thighpads/pkg/database"
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+func TestImportFileTwiceKeepsEntryUUIDsUnique(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if _, err := config.EnsureConfigFolderExists(); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	created := time.Now().Add(-time.Hour)
+	table := models.Table{UUID: database.NewUUID(), Name: "Recipes", Author: "someone", CreatedAt: created}
+	entries := []models.Entry{
+		{UUID: database.NewUUID(), Title: "Pancakes", Content: "Flour, eggs, milk", CreatedAt: created, UpdatedAt: created},
+		{UUID: database.NewUUID(), Title: "Waffles", Content: "Flour, eggs, butter", CreatedAt: created, UpdatedAt: created},
+	}
+
+	content, err := json.MarshalIndent(NewThighpadFileV2(table, entries, "someone"), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "Recipes"+FileExtension)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := ImportFile(path, "me"); err != nil {
+			t.Fatalf("import %d: %v", i+1, err)
+		}
+	}
+
+	tables, err := database.GetTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 2 {
+		t.Fatalf("got %d tables, want 2", len(tables))
+	}
+
+	seen := map[string]bool{}
+	count := 0
+	for _, table := range tables {
+		imported, err := database.GetEntries(table.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range imported {
+			count++
+			if entry.UUID == "" || seen[entry.UUID] {
+				t.Errorf("entry %q in table %d has UUID %q, which is empty or taken", entry.Title, table.ID, entry.UUID)
+			}
+			seen[entry.UUID] = true
+		}
+	}
+	if count != 4 {
+		t.Errorf("got %d entries, want 4", count)
+	}
+}
@@ -0,0 +1,355 @@
+package data
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+const (
+	CSVExtension   = ".csv"
+	JSONLExtension = ".jsonl"
+)
+
+// EntryField is a field of an entry that an imported column can be mapped to
+type EntryField int
+
+const (
+	FieldTitle EntryField = iota
+	FieldTags
+	FieldContent
+	FieldCreated
+	FieldTable
+)
+
+// EntryFields lists the mappable fields in display order
+var EntryFields = []EntryField{FieldTitle, FieldTags, FieldContent, FieldCreated, FieldTable}
+
+func (f EntryField) String() string {
+	switch f {
+	case FieldTitle:
+		return "Title"
+	case FieldTags:
+		return "Tags"
+	case FieldContent:
+		return "Content"
+	case FieldCreated:
+		return "Created"
+	case FieldTable:
+		return "Table"
+	}
+	return "Unknown"
+}
+
+// fieldAliases are the column names GuessMapping recognises for each field
+var fieldAliases = map[EntryField][]string{
+	FieldTitle:   {"title", "name", "subject", "heading"},
+	FieldTags:    {"tags", "tag", "labels", "label", "keywords", "categories"},
+	FieldContent: {"content", "body", "text", "note", "notes", "description", "markdown"},
+	FieldCreated: {"created", "created_at", "createdat", "date", "timestamp", "time"},
+	FieldTable:   {"table", "notebook", "folder", "category", "group"},
+}
+
+// ColumnMapping maps entry fields to source column names. Fields that are not
+// mapped are left empty on import.
+type ColumnMapping map[EntryField]string
+
+// TabularData is a CSV or JSON Lines file read into rows of named columns
+type TabularData struct {
+	Path    string
+	Columns []string
+	Rows    []map[string]string
+}
+
+type tabularRecord struct {
+	Table   string   `json:"table,omitempty"`
+	Title   string   `json:"title"`
+	Tags    []string `json:"tags"`
+	Content string   `json:"content"`
+	Created string   `json:"created"`
+}
+
+// IsTabularFile reports whether path is a CSV or JSON Lines file, which need a
+// column mapping before they can be imported.
+func IsTabularFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == CSVExtension || ext == JSONLExtension
+}
+
+// exportTabular writes the entries of tables as CSV or JSON Lines. A table
+// column is only added when more than one table is exported.
+func exportTabular(tableIDs []uint, target ExportTarget, format ExportFormat) (string, error) {
+	tables, err := tablesForExport(tableIDs)
+	if err != nil {
+		return "", err
+	}
+
+	name := AllTablesName
+	if len(tables) == 1 {
+		name = tables[0].Name
+	}
+	withTable := len(tables) > 1
+
+	var records []tabularRecord
+	for _, table := range tables {
+		for _, entry := range table.Entries {
+			record := tabularRecord{
+				Title:   entry.Title,
+				Tags:    splitTags(entry.Tags),
+				Content: entry.Content,
+				Created: entry.CreatedAt.Format(time.RFC3339),
+			}
+			if withTable {
+				record.Table = table.Name
+			}
+			records = append(records, record)
+		}
+	}
+
+	var content strings.Builder
+	ext := CSVExtension
+	if format == JSONLFormat {
+		ext = JSONLExtension
+		for _, record := range records {
+			if record.Tags == nil {
+				record.Tags = []string{}
+			}
+			line, err := json.Marshal(record)
+			if err != nil {
+				return "", err
+			}
+			content.Write(line)
+			content.WriteString("\n")
+		}
+	} else {
+		writer := csv.NewWriter(&content)
+		header := []string{"title", "tags", "content", "created"}
+		if withTable {
+			header = append([]string{"table"}, header...)
+		}
+		if err := writer.Write(header); err != nil {
+			return "", err
+		}
+		for _, record := range records {
+			row := []string{record.Title, joinTags(record.Tags), record.Content, record.Created}
+			if withTable {
+				row = append([]string{record.Table}, row...)
+			}
+			if err := writer.Write(row); err != nil {
+				return "", err
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return "", err
+		}
+	}
+
+	var lastExportedPath string
+	for _, path := range target.Dirs {
+		filename := target.filePath(path, name, ext)
+		if err := os.WriteFile(filename, []byte(content.String()), 0644); err != nil {
+			fmt.Printf("Warning: could not write to export file %s: %v\n", filename, err)
+			continue
+		}
+		lastExportedPath = filename
+	}
+
+	if lastExportedPath == "" {
+		return "", errors.New("failed to export to any location")
+	}
+
+	return lastExportedPath, nil
+}
+
+// ReadTabularFile reads a CSV file with a header row or a JSON Lines file of
+// objects. JSON values that are not strings are flattened: arrays are joined
+// with commas and everything else is formatted as JSON.
+func ReadTabularFile(path string) (*TabularData, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data := &TabularData{Path: path}
+
+	if strings.ToLower(filepath.Ext(path)) == JSONLExtension {
+		seen := map[string]bool{}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+		for lineNo := 1; scanner.Scan(); lineNo++ {
+			line := strings.TrimSpace(scanner.Text())
+			if lineNo == 1 {
+				line = strings.TrimPrefix(line, "\ufeff")
+			}
+			if line == "" {
+				continue
+			}
+
+			var object map[string]any
+			if err := json.Unmarshal([]byte(line), &object); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+
+			row := map[string]string{}
+			keys := make([]string, 0, len(object))
+			for key := range object {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				row[key] = flattenJSONValue(object[key])
+				if !seen[key] {
+					seen[key] = true
+					data.Columns = append(data.Columns, key)
+				}
+			}
+			data.Rows = append(data.Rows, row)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, errors.New("csv file is empty")
+		}
+
+		// Spreadsheet programs start UTF-8 files with a byte order mark,
+		// which would otherwise stick to the name of the first column
+		data.Columns = records[0]
+		if len(data.Columns) > 0 {
+			data.Columns[0] = strings.TrimPrefix(data.Columns[0], "\ufeff")
+		}
+		for _, record := range records[1:] {
+			row := map[string]string{}
+			for i, column := range data.Columns {
+				if i < len(record) {
+					row[column] = record[i]
+				}
+			}
+			data.Rows = append(data.Rows, row)
+		}
+	}
+
+	if len(data.Rows) == 0 {
+		return nil, errors.New("no rows found in " + path)
+	}
+
+	return data, nil
+}
+
+func flattenJSONValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, flattenJSONValue(item))
+		}
+		return joinTags(parts)
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
+
+// GuessMapping maps fields to columns with a matching or commonly used name
+func (t *TabularData) GuessMapping() ColumnMapping {
+	mapping := ColumnMapping{}
+	for _, field := range EntryFields {
+		for _, alias := range fieldAliases[field] {
+			for _, column := range t.Columns {
+				if strings.EqualFold(strings.TrimSpace(column), alias) {
+					mapping[field] = column
+					break
+				}
+			}
+			if mapping[field] != "" {
+				break
+			}
+		}
+	}
+	return mapping
+}
+
+// Preview builds the tables the rows would create with the given mapping. Rows
+// go into a table named after the file unless a table column is mapped.
+func (t *TabularData) Preview(mapping ColumnMapping) (*ImportPreview, error) {
+	if mapping[FieldTitle] == "" && mapping[FieldContent] == "" {
+		return nil, errors.New("map at least a title or a content column")
+	}
+
+	defaultTable := strings.TrimSuffix(filepath.Base(t.Path), filepath.Ext(t.Path))
+	var order []string
+	byTable := map[string][]models.Entry{}
+
+	for i, row := range t.Rows {
+		entry := models.Entry{
+			Title:   strings.TrimSpace(row[mapping[FieldTitle]]),
+			Tags:    joinTags(splitTags(row[mapping[FieldTags]])),
+			Content: row[mapping[FieldContent]],
+		}
+		if entry.Title == "" {
+			entry.Title = fmt.Sprintf("Row %d", i+1)
+		}
+		if created, err := parseTabularDate(row[mapping[FieldCreated]]); err == nil {
+			entry.CreatedAt = created
+		}
+
+		table := strings.TrimSpace(row[mapping[FieldTable]])
+		if mapping[FieldTable] == "" || table == "" {
+			table = defaultTable
+		}
+		if _, ok := byTable[table]; !ok {
+			order = append(order, table)
+		}
+		byTable[table] = append(byTable[table], entry)
+	}
+
+	source := CSVSource
+	if strings.ToLower(filepath.Ext(t.Path)) == JSONLExtension {
+		source = JSONLSource
+	}
+
+	preview := &ImportPreview{Source: source, Path: t.Path}
+	for _, name := range order {
+		preview.Tables = append(preview.Tables, models.Table{Name: name, Entries: byTable[name]})
+	}
+	return preview, nil
+}
+
+// parseTabularDate accepts the date formats parseNoteDate does plus Unix
+// timestamps in seconds or milliseconds.
+func parseTabularDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if n > 1e12 {
+			return time.UnixMilli(n), nil
+		}
+		return time.Unix(n, 0), nil
+	}
+	return parseNoteDate(value)
+}
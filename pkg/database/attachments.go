@@ -0,0 +1,316 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/config"
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+var blobHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+var ErrBlobMismatch = errors.New("attachment content does not match its hash")
+
+// StoreBlob writes data to the content-addressed attachment store and returns
+// its hex SHA-256. Blobs are kept in sub-folders named after the first two
+// characters of the hash; storing the same content twice is a no-op.
+func StoreBlob(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	path, err := BlobPath(hash)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated blob
+	// under a valid hash
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".blob-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// ReceiveBlob stores the content read from r under hash, e.g. while it is
+// copied from another device. It goes to a temporary file that is only moved
+// into place once its SHA-256 matches; otherwise it is removed and
+// ErrBlobMismatch returned.
+func ReceiveBlob(hash string, r io.Reader) error {
+	path, err := BlobPath(hash)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".blob-*")
+	if err != nil {
+		return err
+	}
+	sum := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, sum), r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if hex.EncodeToString(sum.Sum(nil)) != hash {
+		os.Remove(tmp.Name())
+		return ErrBlobMismatch
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// BlobPath returns where the blob with the given hash is stored
+func BlobPath(hash string) (string, error) {
+	if !blobHashPattern.MatchString(hash) {
+		return "", errors.New("invalid attachment hash")
+	}
+
+	attachmentsPath, err := config.GetAttachmentsPath()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(attachmentsPath, hash[:2], hash[2:]), nil
+}
+
+// ReadBlob returns the content stored under hash
+func ReadBlob(hash string) ([]byte, error) {
+	path, err := BlobPath(hash)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// pruneBlobs removes the blobs of hashes that no attachment refers to anymore
+func pruneBlobs(hashes []string) {
+	for _, hash := range hashes {
+		count, err := countAttachmentsWithHash(hash)
+		if err != nil || count > 0 {
+			continue
+		}
+		if path, err := BlobPath(hash); err == nil {
+			os.Remove(path)
+			// Only succeeds once the fan-out folder is empty
+			os.Remove(filepath.Dir(path))
+		}
+	}
+}
+
+func attachmentHashes(attachments []models.Attachment) []string {
+	hashes := make([]string, len(attachments))
+	for i, attachment := range attachments {
+		hashes[i] = attachment.Hash
+	}
+	return hashes
+}
+
+func CreateAttachment(attachment *models.Attachment) error {
+	return CreateAttachmentWrapper(attachment)
+}
+
+func GetAttachments(entryID uint) ([]models.Attachment, error) {
+	return GetAttachmentsWrapper(entryID)
+}
+
+func DeleteAttachment(id uint) error {
+	return DeleteAttachmentWrapper(id)
+}
+
+func (db *FileDB) CreateAttachment(attachment *models.Attachment) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	entryExists := false
+	for _, entry := range db.Entries {
+		if entry.ID == attachment.EntryID {
+			entryExists = true
+			break
+		}
+	}
+
+	if !entryExists {
+		return errors.New("entry not found")
+	}
+
+	attachment.ID = db.nextID
+	db.nextID++
+	if attachment.CreatedAt.IsZero() {
+		attachment.CreatedAt = time.Now()
+	}
+
+	stored := *attachment
+	stored.Data = nil
+	db.Attachments = append(db.Attachments, stored)
+	return db.save()
+}
+
+func (db *FileDB) GetAttachments(entryID uint) ([]models.Attachment, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var attachments []models.Attachment
+	for _, attachment := range db.Attachments {
+		if attachment.EntryID == entryID {
+			attachments = append(attachments, attachment)
+		}
+	}
+
+	return attachments, nil
+}
+
+func (db *FileDB) GetAttachment(id uint) (models.Attachment, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, attachment := range db.Attachments {
+		if attachment.ID == id {
+			return attachment, nil
+		}
+	}
+
+	return models.Attachment{}, errors.New("attachment not found")
+}
+
+func (db *FileDB) DeleteAttachment(id uint) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for i, attachment := range db.Attachments {
+		if attachment.ID == id {
+			db.Attachments = append(db.Attachments[:i], db.Attachments[i+1:]...)
+			return db.save()
+		}
+	}
+
+	return errors.New("attachment not found")
+}
+
+func (db *FileDB) countAttachmentsWithHash(hash string) int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	count := 0
+	for _, attachment := range db.Attachments {
+		if attachment.Hash == hash {
+			count++
+		}
+	}
+	return count
+}
+
+// removeAttachments drops the attachments of the given entries. The caller
+// holds the lock and saves.
+func (db *FileDB) removeAttachments(entryIDs map[uint]bool) {
+	var remaining []models.Attachment
+	for _, attachment := range db.Attachments {
+		if !entryIDs[attachment.EntryID] {
+			remaining = append(remaining, attachment)
+		}
+	}
+	db.Attachments = remaining
+}
+
+func CreateAttachmentWrapper(attachment *models.Attachment) error {
+	if DB != nil {
+		return DB.Create(attachment).Error
+	}
+	if err := fileDB.CreateAttachment(attachment); err != nil {
+		return err
+	}
+	return gitDB.commitEntry(attachment.EntryID, func(title, table string) string {
+		return fmt.Sprintf("Attach %q to %q in %s", attachment.Filename, title, table)
+	})
+}
+
+func GetAttachmentsWrapper(entryID uint) ([]models.Attachment, error) {
+	if DB != nil {
+		var attachments []models.Attachment
+		err := DB.Where("entry_id = ?", entryID).Order("id").Find(&attachments).Error
+		return attachments, err
+	}
+	return fileDB.GetAttachments(entryID)
+}
+
+func DeleteAttachmentWrapper(id uint) error {
+	var attachment models.Attachment
+	if DB != nil {
+		if err := DB.First(&attachment, id).Error; err != nil {
+			return err
+		}
+		if err := DB.Delete(&models.Attachment{}, id).Error; err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if attachment, err = fileDB.GetAttachment(id); err != nil {
+			return err
+		}
+		if err := fileDB.DeleteAttachment(id); err != nil {
+			return err
+		}
+		err = gitDB.commitEntry(attachment.EntryID, func(title, table string) string {
+			return fmt.Sprintf("Remove %q from %q in %s", attachment.Filename, title, table)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	pruneBlobs([]string{attachment.Hash})
+	return nil
+}
+
+func countAttachmentsWithHash(hash string) (int, error) {
+	if DB != nil {
+		var count int64
+		err := DB.Model(&models.Attachment{}).Where("hash = ?", hash).Count(&count).Error
+		return int(count), err
+	}
+	return fileDB.countAttachmentsWithHash(hash), nil
+}
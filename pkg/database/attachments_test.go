@@ -0,0 +1,60 @@
+package database
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/s42yt/thighpads/pkg/config"
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+func TestGitCommitMessagesKeepPercentInFilenames(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("HOME", t.TempDir())
+	if err := config.SaveConfig(&models.Config{Username: "someone", Storage: StorageGit}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	table := models.Table{Name: "Images", Author: "someone"}
+	if err := CreateTable(&table); err != nil {
+		t.Fatal(err)
+	}
+	entry := models.Entry{TableID: table.ID, Title: "Progress"}
+	if err := CreateEntry(&entry); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := StoreBlob([]byte("image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachment := models.Attachment{EntryID: entry.ID, Filename: "100%.png", MimeType: "image/png", Size: 5, Hash: hash}
+	if err := CreateAttachmentWrapper(&attachment); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lastCommitMessage(t), `Attach "100%.png" to "Progress" in Images`; got != want {
+		t.Errorf("commit message is %q, want %q", got, want)
+	}
+
+	if err := DeleteAttachmentWrapper(attachment.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lastCommitMessage(t), `Remove "100%.png" from "Progress" in Images`; got != want {
+		t.Errorf("commit message is %q, want %q", got, want)
+	}
+}
+
+func lastCommitMessage(t *testing.T) string {
+	t.Helper()
+	out, err := gitDB.git("log", "-1", "--format=%s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(out)
+}
@@ -12,9 +12,25 @@ import (
 var DB *gorm.DB
 
 func Initialize() error {
+	_, err := initialize(false)
+	return err
+}
+
+// DryRunMigrations opens the database and reports which migrations Initialize
+// would apply without changing any data.
+func DryRunMigrations() (MigrationPlan, error) {
+	return initialize(true)
+}
+
+func initialize(dryRun bool) (MigrationPlan, error) {
+	gitDB = nil
+	if cfg, err := config.LoadConfig(); err == nil && cfg.Storage == StorageGit {
+		return initializeGitDB(dryRun)
+	}
+
 	dbPath, err := config.GetDBPath()
 	if err != nil {
-		return err
+		return MigrationPlan{}, err
 	}
 
 	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
@@ -22,16 +38,16 @@ func Initialize() error {
 	if err != nil {
 		fmt.Println("Warning: Could not initialize SQLite database, falling back to file-based storage.")
 		fmt.Println("Error was:", err.Error())
-		return InitializeFileDB()
+		return initializeFileDB(dryRun)
 	}
 
-	err = db.AutoMigrate(&models.Table{}, &models.Entry{})
+	plan, err := migrateGorm(db, dbPath, dryRun)
 	if err != nil {
-		return err
+		return plan, err
 	}
 
 	DB = db
-	return nil
+	return plan, nil
 }
 
 func CreateTable(table *models.Table) error {
@@ -46,6 +62,14 @@ func GetTable(id uint) (models.Table, error) {
 	return GetTableWrapper(id)
 }
 
+func FindTableByUUID(uuid string) (models.Table, error) {
+	return FindTableByUUIDWrapper(uuid)
+}
+
+func UpdateTable(table *models.Table) error {
+	return UpdateTableWrapper(table)
+}
+
 func GetTableWithEntries(id uint) (models.Table, error) {
 	return GetTableWithEntriesWrapper(id)
 }
@@ -66,6 +90,10 @@ func GetEntry(id uint) (models.Entry, error) {
 	return GetEntryWrapper(id)
 }
 
+func FindEntryByUUID(uuid string) (models.Entry, error) {
+	return FindEntryByUUIDWrapper(uuid)
+}
+
 func UpdateEntry(entry *models.Entry) error {
 	return UpdateEntryWrapper(entry)
 }
@@ -3,6 +3,7 @@ package database
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,46 +12,61 @@ import (
 
 	"github.com/s42yt/thighpads/pkg/config"
 	"github.com/s42yt/thighpads/pkg/models"
+	"gorm.io/gorm"
 )
 
 type FileDB struct {
-	Tables  []models.Table
-	Entries []models.Entry
-	mu      sync.RWMutex
-	dbPath  string
-	nextID  uint
+	SchemaVersion int
+	Tables        []models.Table
+	Entries       []models.Entry
+	Attachments   []models.Attachment
+	mu            sync.RWMutex
+	dbPath        string
+	nextID        uint
+	// persist replaces writing thighpads.json, see GitDB
+	persist func() error
 }
 
 var fileDB *FileDB
 
 func InitializeFileDB() error {
+	_, err := initializeFileDB(false)
+	return err
+}
+
+func initializeFileDB(dryRun bool) (MigrationPlan, error) {
 	configPath, err := config.GetConfigPath()
 	if err != nil {
-		return err
+		return MigrationPlan{}, err
 	}
 
 	dbPath := filepath.Join(configPath, "thighpads.json")
 
 	fileDB = &FileDB{
-		Tables:  []models.Table{},
-		Entries: []models.Entry{},
-		dbPath:  dbPath,
-		nextID:  1,
+		Tables:      []models.Table{},
+		Entries:     []models.Entry{},
+		Attachments: []models.Attachment{},
+		dbPath:      dbPath,
+		nextID:      1,
 	}
 
 	if _, err := os.Stat(dbPath); err == nil {
 		data, err := os.ReadFile(dbPath)
 		if err != nil {
-			return err
+			return MigrationPlan{}, err
 		}
 
 		var db FileDB
 		if err := json.Unmarshal(data, &db); err != nil {
-			return err
+			return MigrationPlan{}, err
 		}
 
+		fileDB.SchemaVersion = db.SchemaVersion
 		fileDB.Tables = db.Tables
 		fileDB.Entries = db.Entries
+		if db.Attachments != nil {
+			fileDB.Attachments = db.Attachments
+		}
 
 		for _, table := range fileDB.Tables {
 			if table.ID >= fileDB.nextID {
@@ -63,17 +79,32 @@ func InitializeFileDB() error {
 				fileDB.nextID = entry.ID + 1
 			}
 		}
+
+		for _, attachment := range fileDB.Attachments {
+			if attachment.ID >= fileDB.nextID {
+				fileDB.nextID = attachment.ID + 1
+			}
+		}
 	}
 
 	DB = nil
 
-	return nil
+	return migrateFile(fileDB, dryRun)
 }
 
 func (db *FileDB) Save() error {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
+	return db.save()
+}
+
+// save writes the database. Callers hold db.mu.
+func (db *FileDB) save() error {
+	if db.persist != nil {
+		return db.persist()
+	}
+
 	data, err := json.MarshalIndent(db, "", "  ")
 	if err != nil {
 		return err
@@ -88,10 +119,12 @@ func (db *FileDB) CreateTable(table *models.Table) error {
 
 	table.ID = db.nextID
 	db.nextID++
-	table.CreatedAt = time.Now()
+	if table.CreatedAt.IsZero() {
+		table.CreatedAt = time.Now()
+	}
 
 	db.Tables = append(db.Tables, *table)
-	return db.Save()
+	return db.save()
 }
 
 func (db *FileDB) GetTables() ([]models.Table, error) {
@@ -114,6 +147,33 @@ func (db *FileDB) GetTable(id uint) (models.Table, error) {
 	return models.Table{}, errors.New("table not found")
 }
 
+func (db *FileDB) FindTableByUUID(uuid string) (models.Table, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, table := range db.Tables {
+		if table.UUID == uuid {
+			return table, nil
+		}
+	}
+
+	return models.Table{}, errors.New("table not found")
+}
+
+func (db *FileDB) UpdateTable(table *models.Table) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for i, t := range db.Tables {
+		if t.ID == table.ID {
+			db.Tables[i] = *table
+			return db.save()
+		}
+	}
+
+	return errors.New("table not found")
+}
+
 func (db *FileDB) GetTableWithEntries(id uint) (models.Table, error) {
 	table, err := db.GetTable(id)
 	if err != nil {
@@ -148,14 +208,18 @@ func (db *FileDB) DeleteTable(id uint) error {
 	db.Tables = append(db.Tables[:tableIndex], db.Tables[tableIndex+1:]...)
 
 	var remainingEntries []models.Entry
+	removed := map[uint]bool{}
 	for _, entry := range db.Entries {
 		if entry.TableID != id {
 			remainingEntries = append(remainingEntries, entry)
+		} else {
+			removed[entry.ID] = true
 		}
 	}
 
 	db.Entries = remainingEntries
-	return db.Save()
+	db.removeAttachments(removed)
+	return db.save()
 }
 
 func (db *FileDB) CreateEntry(entry *models.Entry) error {
@@ -176,10 +240,15 @@ func (db *FileDB) CreateEntry(entry *models.Entry) error {
 
 	entry.ID = db.nextID
 	db.nextID++
-	entry.CreatedAt = time.Now()
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	if entry.UpdatedAt.IsZero() {
+		entry.UpdatedAt = entry.CreatedAt
+	}
 
 	db.Entries = append(db.Entries, *entry)
-	return db.Save()
+	return db.save()
 }
 
 func (db *FileDB) GetEntries(tableID uint) ([]models.Entry, error) {
@@ -209,6 +278,19 @@ func (db *FileDB) GetEntry(id uint) (models.Entry, error) {
 	return models.Entry{}, errors.New("entry not found")
 }
 
+func (db *FileDB) FindEntryByUUID(uuid string) (models.Entry, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, entry := range db.Entries {
+		if entry.UUID == uuid {
+			return entry, nil
+		}
+	}
+
+	return models.Entry{}, errors.New("entry not found")
+}
+
 func (db *FileDB) UpdateEntry(entry *models.Entry) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -225,8 +307,9 @@ func (db *FileDB) UpdateEntry(entry *models.Entry) error {
 		return errors.New("entry not found")
 	}
 
+	entry.UpdatedAt = time.Now()
 	db.Entries[entryIndex] = *entry
-	return db.Save()
+	return db.save()
 }
 
 func (db *FileDB) DeleteEntry(id uint) error {
@@ -246,7 +329,8 @@ func (db *FileDB) DeleteEntry(id uint) error {
 	}
 
 	db.Entries = append(db.Entries[:entryIndex], db.Entries[entryIndex+1:]...)
-	return db.Save()
+	db.removeAttachments(map[uint]bool{id: true})
+	return db.save()
 }
 
 func (db *FileDB) SearchEntries(tableID uint, query string) ([]models.Entry, error) {
@@ -272,10 +356,16 @@ func containsIgnoreCase(s, substr string) bool {
 }
 
 func CreateTableWrapper(table *models.Table) error {
+	if table.UUID == "" {
+		table.UUID = NewUUID()
+	}
 	if DB != nil {
 		return DB.Create(table).Error
 	}
-	return fileDB.CreateTable(table)
+	if err := fileDB.CreateTable(table); err != nil {
+		return err
+	}
+	return gitDB.commitTable(*table, fmt.Sprintf("Add table %q", table.Name))
 }
 
 func GetTablesWrapper() ([]models.Table, error) {
@@ -316,22 +406,77 @@ func GetTableWithEntriesWrapper(id uint) (models.Table, error) {
 	return fileDB.GetTableWithEntries(id)
 }
 
+func FindTableByUUIDWrapper(uuid string) (models.Table, error) {
+	if DB != nil {
+		// Find instead of First: a missing UUID is expected here and should
+		// not be logged as an error
+		var table models.Table
+		result := DB.Where("uuid = ?", uuid).Limit(1).Find(&table)
+		if result.Error == nil && result.RowsAffected == 0 {
+			return table, gorm.ErrRecordNotFound
+		}
+		return table, result.Error
+	}
+	return fileDB.FindTableByUUID(uuid)
+}
+
+func UpdateTableWrapper(table *models.Table) error {
+	if DB != nil {
+		return DB.Save(table).Error
+	}
+	if err := fileDB.UpdateTable(table); err != nil {
+		return err
+	}
+	return gitDB.commitTable(*table, fmt.Sprintf("Update table %q", table.Name))
+}
+
 func DeleteTableWrapper(id uint) error {
+	table, _ := GetTableWrapper(id)
+
+	var attachments []models.Attachment
+	if entries, err := GetEntriesWrapper(id); err == nil {
+		for _, entry := range entries {
+			entryAttachments, _ := GetAttachmentsWrapper(entry.ID)
+			attachments = append(attachments, entryAttachments...)
+		}
+	}
+
 	if DB != nil {
-		err := DB.Where("table_id = ?", id).Delete(&models.Entry{}).Error
+		err := DB.Where("entry_id IN (?)", DB.Model(&models.Entry{}).Select("id").Where("table_id = ?", id)).
+			Delete(&models.Attachment{}).Error
 		if err != nil {
 			return err
 		}
-		return DB.Delete(&models.Table{}, id).Error
+		err = DB.Where("table_id = ?", id).Delete(&models.Entry{}).Error
+		if err != nil {
+			return err
+		}
+		if err := DB.Delete(&models.Table{}, id).Error; err != nil {
+			return err
+		}
+	} else if err := fileDB.DeleteTable(id); err != nil {
+		return err
+	} else if err := gitDB.commitTableRemoval(table); err != nil {
+		return err
 	}
-	return fileDB.DeleteTable(id)
+
+	pruneBlobs(attachmentHashes(attachments))
+	return nil
 }
 
 func CreateEntryWrapper(entry *models.Entry) error {
+	if entry.UUID == "" {
+		entry.UUID = NewUUID()
+	}
 	if DB != nil {
 		return DB.Create(entry).Error
 	}
-	return fileDB.CreateEntry(entry)
+	if err := fileDB.CreateEntry(entry); err != nil {
+		return err
+	}
+	return gitDB.commitEntry(entry.ID, func(title, table string) string {
+		return fmt.Sprintf("Add %q to %s", title, table)
+	})
 }
 
 func GetEntriesWrapper(tableID uint) ([]models.Entry, error) {
@@ -352,18 +497,49 @@ func GetEntryWrapper(id uint) (models.Entry, error) {
 	return fileDB.GetEntry(id)
 }
 
+func FindEntryByUUIDWrapper(uuid string) (models.Entry, error) {
+	if DB != nil {
+		var entry models.Entry
+		result := DB.Where("uuid = ?", uuid).Limit(1).Find(&entry)
+		if result.Error == nil && result.RowsAffected == 0 {
+			return entry, gorm.ErrRecordNotFound
+		}
+		return entry, result.Error
+	}
+	return fileDB.FindEntryByUUID(uuid)
+}
+
 func UpdateEntryWrapper(entry *models.Entry) error {
 	if DB != nil {
 		return DB.Save(entry).Error
 	}
-	return fileDB.UpdateEntry(entry)
+	if err := fileDB.UpdateEntry(entry); err != nil {
+		return err
+	}
+	return gitDB.commitEntry(entry.ID, func(title, table string) string {
+		return fmt.Sprintf("Update %q in %s", title, table)
+	})
 }
 
 func DeleteEntryWrapper(id uint) error {
+	entry, _ := GetEntryWrapper(id)
+	attachments, _ := GetAttachmentsWrapper(id)
+
 	if DB != nil {
-		return DB.Delete(&models.Entry{}, id).Error
+		if err := DB.Where("entry_id = ?", id).Delete(&models.Attachment{}).Error; err != nil {
+			return err
+		}
+		if err := DB.Delete(&models.Entry{}, id).Error; err != nil {
+			return err
+		}
+	} else if err := fileDB.DeleteEntry(id); err != nil {
+		return err
+	} else if err := gitDB.commitEntryRemoval(entry); err != nil {
+		return err
 	}
-	return fileDB.DeleteEntry(id)
+
+	pruneBlobs(attachmentHashes(attachments))
+	return nil
 }
 
 func SearchEntriesWrapper(tableID uint, query string) ([]models.Entry, error) {
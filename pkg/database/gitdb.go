@@ -0,0 +1,1160 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/config"
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+const (
+	// tableFileName holds the table's metadata inside its directory
+	tableFileName = ".table.json"
+	// gitBlobsFolderName holds the attachment contents inside the repository,
+	// laid out like the local attachment store
+	gitBlobsFolderName = ".attachments"
+	// gitSchemaFileName records the schema version the repository is at
+	gitSchemaFileName = ".schema.json"
+	// maxGitFilenameLength keeps entry filenames manageable on every platform
+	maxGitFilenameLength = 80
+)
+
+var ErrNoGitRemote = errors.New("no git remote is set, add one with: thighpads sync --remote <url>")
+
+// GitDB stores each table as a directory and each entry as a Markdown file
+// in a git repository, and commits every change. The data is kept in memory
+// in a FileDB, which answers all queries; GitDB only writes the files.
+type GitDB struct {
+	*FileDB
+	repoPath string
+	// lock serializes commits and syncs, which run in the background
+	lock sync.Mutex
+	// paths maps the UUIDs of tables and entries to their path in the
+	// repository, relative to its root
+	paths map[string]string
+}
+
+var gitDB *GitDB
+
+type gitTable struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created"`
+}
+
+type gitAttachment struct {
+	Filename string `json:"filename"`
+	MimeType string `json:"mimeType"`
+	Size     int64  `json:"size"`
+	Hash     string `json:"hash"`
+}
+
+func initializeGitDB(dryRun bool) (MigrationPlan, error) {
+	plan := MigrationPlan{Backend: "git", TargetVersion: SchemaVersion}
+
+	repoPath, err := config.GetGitRepoPath()
+	if err != nil {
+		return plan, err
+	}
+
+	current, err := readGitSchemaVersion(repoPath)
+	if err != nil {
+		return plan, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	plan.CurrentVersion = current
+
+	plan.Pending, err = pendingMigrations(current)
+	if err != nil || dryRun {
+		return plan, err
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		return plan, errors.New("the git storage backend needs git to be installed")
+	}
+
+	db := &GitDB{
+		FileDB: &FileDB{
+			SchemaVersion: current,
+			Tables:        []models.Table{},
+			Entries:       []models.Entry{},
+			Attachments:   []models.Attachment{},
+			nextID:        1,
+			// Files are written per change by GitDB, see commitTable
+			persist: func() error { return nil },
+		},
+		repoPath: repoPath,
+	}
+
+	if err := db.init(); err != nil {
+		return plan, err
+	}
+	if err := db.load(); err != nil {
+		return plan, err
+	}
+	if err := db.migrate(plan.Pending); err != nil {
+		return plan, err
+	}
+
+	DB = nil
+	fileDB = db.FileDB
+	gitDB = db
+
+	return plan, nil
+}
+
+type gitSchema struct {
+	Version int `json:"version"`
+}
+
+// readGitSchemaVersion reads the version recorded in the repository. One
+// that does not exist yet is at version 0, and one without the record was
+// written before it was kept, at version 3 when the git backend was added.
+func readGitSchemaVersion(repoPath string) (int, error) {
+	content, err := os.ReadFile(filepath.Join(repoPath, gitSchemaFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(filepath.Join(repoPath, ".git")); err != nil {
+			return 0, nil
+		}
+		return 3, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return parseGitSchema(content)
+}
+
+func parseGitSchema(content []byte) (int, error) {
+	var schema gitSchema
+	if err := json.Unmarshal(content, &schema); err != nil {
+		return 0, fmt.Errorf("%s: %w", gitSchemaFileName, err)
+	}
+	return schema.Version, nil
+}
+
+// migrate applies the pending migrations to the loaded repository, writes
+// all of it back and commits it along with the new version. The history of
+// the repository serves as the backup.
+func (g *GitDB) migrate(pending []Migration) error {
+	if len(pending) == 0 {
+		return nil
+	}
+
+	for _, m := range pending {
+		if err := m.File(g.FileDB); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+		}
+		g.SchemaVersion = m.Version
+	}
+
+	tables, entries := append([]models.Table(nil), g.Tables...), append([]models.Entry(nil), g.Entries...)
+	for _, table := range tables {
+		if err := g.writeTable(table); err != nil {
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if _, _, err := g.writeEntry(entry.ID); err != nil {
+			return err
+		}
+	}
+
+	schema, err := json.MarshalIndent(gitSchema{Version: g.SchemaVersion}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(g.repoPath, gitSchemaFileName), append(schema, '\n'), 0644); err != nil {
+		return err
+	}
+	return g.commit(fmt.Sprintf("Upgrade data to schema version %d", g.SchemaVersion))
+}
+
+// init creates the repository on first use. Commits are made under the
+// ThighPads username unless git already knows who the user is.
+func (g *GitDB) init() error {
+	if _, err := os.Stat(filepath.Join(g.repoPath, ".git")); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(g.repoPath, 0755); err != nil {
+		return err
+	}
+	if _, err := g.git("init"); err != nil {
+		return err
+	}
+	if _, err := g.git("symbolic-ref", "HEAD", "refs/heads/main"); err != nil {
+		return err
+	}
+
+	if email, _ := g.git("config", "user.email"); email == "" {
+		name := "ThighPads"
+		if cfg, err := config.LoadConfig(); err == nil && cfg.Username != "" {
+			name = cfg.Username
+		}
+		if _, err := g.git("config", "user.name", name); err != nil {
+			return err
+		}
+		if _, err := g.git("config", "user.email", "thighpads@localhost"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *GitDB) git(args ...string) (string, error) {
+	out, err := g.gitRaw(args...)
+	return strings.TrimSpace(out), err
+}
+
+func (g *GitDB) gitRaw(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.repoPath
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return stdout.String(), fmt.Errorf("git %s: %s", args[0], message)
+	}
+
+	return stdout.String(), nil
+}
+
+// load reads the repository into memory. Tables and entries that were loaded
+// before keep their IDs, so that screens showing them stay valid after a
+// sync; the others are numbered in creation order. Tables and entries that
+// were written or copied by hand get a UUID of their own, which is written back
+// and committed so that it stays the same.
+func (g *GitDB) load() error {
+	db := g.FileDB
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	previous := map[string]uint{}
+	for _, table := range db.Tables {
+		previous[table.UUID] = table.ID
+		if table.ID >= db.nextID {
+			db.nextID = table.ID + 1
+		}
+	}
+	for _, entry := range db.Entries {
+		previous[entry.UUID] = entry.ID
+		if entry.ID >= db.nextID {
+			db.nextID = entry.ID + 1
+		}
+	}
+	for _, attachment := range db.Attachments {
+		if attachment.ID >= db.nextID {
+			db.nextID = attachment.ID + 1
+		}
+	}
+	id := func(uuid string) uint {
+		if id, ok := previous[uuid]; ok {
+			return id
+		}
+		db.nextID++
+		return db.nextID - 1
+	}
+
+	db.Tables = []models.Table{}
+	db.Entries = []models.Entry{}
+	db.Attachments = []models.Attachment{}
+	g.paths = map[string]string{}
+
+	dirs, err := os.ReadDir(g.repoPath)
+	if err != nil {
+		return err
+	}
+
+	// assigned marks the ones that got a new UUID
+	type loadedEntry struct {
+		entry       models.Entry
+		attachments []gitAttachment
+		path        string
+		assigned    bool
+	}
+	type loadedTable struct {
+		table    models.Table
+		entries  []loadedEntry
+		dir      string
+		assigned bool
+	}
+
+	var tables []loadedTable
+	for _, dir := range dirs {
+		if !dir.IsDir() || strings.HasPrefix(dir.Name(), ".") {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(g.repoPath, dir.Name(), tableFileName))
+		if err != nil {
+			continue
+		}
+
+		var meta gitTable
+		if err := json.Unmarshal(content, &meta); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Join(dir.Name(), tableFileName), err)
+		}
+
+		loaded := loadedTable{table: models.Table{UUID: meta.ID, Name: meta.Name, Author: meta.Author, CreatedAt: meta.CreatedAt}, dir: dir.Name()}
+
+		files, err := os.ReadDir(filepath.Join(g.repoPath, dir.Name()))
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if file.IsDir() || filepath.Ext(file.Name()) != ".md" {
+				continue
+			}
+
+			path := filepath.Join(dir.Name(), file.Name())
+			content, err := os.ReadFile(filepath.Join(g.repoPath, path))
+			if err != nil {
+				return err
+			}
+
+			entry, attachments, err := parseGitEntry(content)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			loaded.entries = append(loaded.entries, loadedEntry{entry: entry, attachments: attachments, path: path})
+		}
+
+		tables = append(tables, loaded)
+	}
+
+	// A copy made by hand carries the UUID of the original, so it is treated
+	// as a separate entry or table with a new one. Files named after their
+	// title claim their UUID first, which leaves the new one to the copy
+	// whatever the order of the names.
+	claim := func(uuid *string, path string) bool {
+		_, taken := g.paths[*uuid]
+		assigned := *uuid == "" || taken
+		if assigned {
+			*uuid = NewUUID()
+		}
+		g.paths[*uuid] = path
+		return assigned
+	}
+	for _, named := range []bool{true, false} {
+		for i := range tables {
+			t := &tables[i]
+			if (t.dir == gitFilename(t.table.Name)) == named {
+				t.assigned = claim(&t.table.UUID, t.dir)
+			}
+			for j := range t.entries {
+				e := &t.entries[j]
+				if (filepath.Base(e.path) == gitFilename(e.entry.Title)+".md") == named {
+					e.assigned = claim(&e.entry.UUID, e.path)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(tables, func(i, j int) bool {
+		return tables[i].table.CreatedAt.Before(tables[j].table.CreatedAt)
+	})
+
+	assigned := 0
+	for _, loaded := range tables {
+		table := loaded.table
+		table.ID = id(table.UUID)
+		db.Tables = append(db.Tables, table)
+		if loaded.assigned {
+			if err := g.writeTableMeta(loaded.dir, table); err != nil {
+				return err
+			}
+			assigned++
+		}
+
+		sort.SliceStable(loaded.entries, func(i, j int) bool {
+			return loaded.entries[i].entry.CreatedAt.Before(loaded.entries[j].entry.CreatedAt)
+		})
+
+		for _, le := range loaded.entries {
+			entry := le.entry
+			entry.ID = id(entry.UUID)
+			entry.TableID = table.ID
+			db.Entries = append(db.Entries, entry)
+			first := len(db.Attachments)
+
+			for _, attachment := range le.attachments {
+				if err := g.pullBlob(attachment.Hash); err != nil {
+					return fmt.Errorf("%s: %w", le.path, err)
+				}
+
+				db.Attachments = append(db.Attachments, models.Attachment{
+					ID:        db.nextID,
+					EntryID:   entry.ID,
+					Filename:  attachment.Filename,
+					MimeType:  attachment.MimeType,
+					Size:      attachment.Size,
+					Hash:      attachment.Hash,
+					CreatedAt: entry.UpdatedAt,
+				})
+				db.nextID++
+			}
+
+			if le.assigned {
+				content := formatGitEntry(entry, db.Attachments[first:])
+				if err := os.WriteFile(filepath.Join(g.repoPath, le.path), content, 0644); err != nil {
+					return err
+				}
+				assigned++
+			}
+		}
+	}
+
+	if assigned == 0 {
+		return nil
+	}
+	return g.commit(fmt.Sprintf("Assign IDs to %d tables and entries", assigned))
+}
+
+// formatGitEntry writes an entry as Markdown with front matter. Values are
+// JSON, which is also valid YAML, so that any string survives unchanged.
+func formatGitEntry(entry models.Entry, attachments []models.Attachment) []byte {
+	var b bytes.Buffer
+	field := func(name string, value interface{}) {
+		encoded, _ := json.Marshal(value)
+		fmt.Fprintf(&b, "%s: %s\n", name, encoded)
+	}
+
+	b.WriteString("---\n")
+	field("id", entry.UUID)
+	field("title", entry.Title)
+	field("tags", entry.Tags)
+	if entry.Language != "" {
+		field("language", entry.Language)
+	}
+	field("created", entry.CreatedAt)
+	field("updated", entry.UpdatedAt)
+	if len(attachments) > 0 {
+		list := make([]gitAttachment, len(attachments))
+		for i, attachment := range attachments {
+			list[i] = gitAttachment{Filename: attachment.Filename, MimeType: attachment.MimeType, Size: attachment.Size, Hash: attachment.Hash}
+		}
+		field("attachments", list)
+	}
+	b.WriteString("---\n")
+	b.WriteString(entry.Content)
+
+	return b.Bytes()
+}
+
+func parseGitEntry(content []byte) (models.Entry, []gitAttachment, error) {
+	var entry models.Entry
+	var attachments []gitAttachment
+
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	if !strings.HasPrefix(text, "---\n") {
+		return entry, nil, errors.New("missing front matter")
+	}
+
+	end := strings.Index(text[4:], "\n---\n")
+	if end < 0 {
+		return entry, nil, errors.New("front matter is not closed")
+	}
+
+	header := text[4 : 4+end]
+	entry.Content = text[4+end+len("\n---\n"):]
+
+	for _, line := range strings.Split(header, "\n") {
+		name, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+
+		var target interface{}
+		switch name {
+		case "id":
+			target = &entry.UUID
+		case "title":
+			target = &entry.Title
+		case "tags":
+			target = &entry.Tags
+		case "language":
+			target = &entry.Language
+		case "created":
+			target = &entry.CreatedAt
+		case "updated":
+			target = &entry.UpdatedAt
+		case "attachments":
+			target = &attachments
+		default:
+			continue
+		}
+
+		if err := json.Unmarshal([]byte(value), target); err != nil {
+			return entry, nil, fmt.Errorf("front matter %s: %w", name, err)
+		}
+	}
+
+	return entry, attachments, nil
+}
+
+// gitFilename turns a title into a file or directory name
+func gitFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < 32 {
+			return '_'
+		}
+		return r
+	}, name)
+
+	name = strings.Trim(name, " .")
+	if len([]rune(name)) > maxGitFilenameLength {
+		name = strings.TrimSpace(string([]rune(name)[:maxGitFilenameLength]))
+	}
+	if name == "" {
+		name = "Untitled"
+	}
+	return name
+}
+
+// freePath returns dir/name+ext, numbered if another table or entry already
+// uses that path. current is the object's present path, which it may keep.
+func (g *GitDB) freePath(dir, name, ext, current string) string {
+	taken := map[string]bool{}
+	for _, path := range g.paths {
+		taken[strings.ToLower(path)] = true
+	}
+
+	for n := 1; ; n++ {
+		candidate := name + ext
+		if n > 1 {
+			candidate = fmt.Sprintf("%s (%d)%s", name, n, ext)
+		}
+		path := filepath.Join(dir, candidate)
+
+		if path == current || !taken[strings.ToLower(path)] {
+			if _, err := os.Stat(filepath.Join(g.repoPath, path)); path == current || os.IsNotExist(err) {
+				return path
+			}
+		}
+	}
+}
+
+// commit stages everything and commits it, unless nothing changed
+func (g *GitDB) commit(message string) error {
+	if _, err := g.git("add", "-A"); err != nil {
+		return err
+	}
+	if _, err := g.git("diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+	_, err := g.git("commit", "-q", "-m", message)
+	return err
+}
+
+// commitTable writes a table's metadata, renaming its directory if the name
+// changed, and commits it. Like the other commit methods it does nothing when
+// the git backend is not in use.
+func (g *GitDB) commitTable(table models.Table, message string) error {
+	if g == nil {
+		return nil
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if err := g.writeTable(table); err != nil {
+		return err
+	}
+	return g.commit(message)
+}
+
+func (g *GitDB) writeTable(table models.Table) error {
+	current := g.paths[table.UUID]
+	dir := g.freePath("", gitFilename(table.Name), "", current)
+
+	if current != "" && current != dir {
+		if err := os.Rename(filepath.Join(g.repoPath, current), filepath.Join(g.repoPath, dir)); err != nil {
+			return err
+		}
+		// Entries move along with the directory
+		for uuid, path := range g.paths {
+			if strings.HasPrefix(path, current+string(filepath.Separator)) {
+				g.paths[uuid] = filepath.Join(dir, strings.TrimPrefix(path, current+string(filepath.Separator)))
+			}
+		}
+	}
+	g.paths[table.UUID] = dir
+
+	if err := os.MkdirAll(filepath.Join(g.repoPath, dir), 0755); err != nil {
+		return err
+	}
+
+	return g.writeTableMeta(dir, table)
+}
+
+func (g *GitDB) writeTableMeta(dir string, table models.Table) error {
+	meta, err := json.MarshalIndent(gitTable{ID: table.UUID, Name: table.Name, Author: table.Author, CreatedAt: table.CreatedAt}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(g.repoPath, dir, tableFileName), append(meta, '\n'), 0644)
+}
+
+// commitTableRemoval deletes a table's directory with all its entries
+func (g *GitDB) commitTableRemoval(table models.Table) error {
+	if g == nil {
+		return nil
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	dir, ok := g.paths[table.UUID]
+	if !ok {
+		return nil
+	}
+
+	if err := os.RemoveAll(filepath.Join(g.repoPath, dir)); err != nil {
+		return err
+	}
+	for uuid, path := range g.paths {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			delete(g.paths, uuid)
+		}
+	}
+
+	g.pruneBlobs()
+	return g.commit(fmt.Sprintf("Delete table %q", table.Name))
+}
+
+// commitEntry writes an entry's Markdown file, moving it if its title or
+// table changed, and commits it. message builds the commit message from the
+// entry's title and table name as they are once written.
+func (g *GitDB) commitEntry(entryID uint, message func(title, table string) string) error {
+	if g == nil {
+		return nil
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	entry, table, err := g.writeEntry(entryID)
+	if err != nil {
+		return err
+	}
+
+	g.pruneBlobs()
+	return g.commit(message(entry.Title, table.Name))
+}
+
+func (g *GitDB) writeEntry(entryID uint) (models.Entry, models.Table, error) {
+	entry, err := g.GetEntry(entryID)
+	if err != nil {
+		return entry, models.Table{}, err
+	}
+	table, err := g.GetTable(entry.TableID)
+	if err != nil {
+		return entry, table, err
+	}
+	attachments, err := g.GetAttachments(entry.ID)
+	if err != nil {
+		return entry, table, err
+	}
+
+	current := g.paths[entry.UUID]
+	path := g.freePath(g.paths[table.UUID], gitFilename(entry.Title), ".md", current)
+
+	if current != "" && current != path {
+		if err := os.Remove(filepath.Join(g.repoPath, current)); err != nil && !os.IsNotExist(err) {
+			return entry, table, err
+		}
+	}
+	g.paths[entry.UUID] = path
+
+	for _, attachment := range attachments {
+		if err := g.pushBlob(attachment.Hash); err != nil {
+			return entry, table, err
+		}
+	}
+
+	return entry, table, os.WriteFile(filepath.Join(g.repoPath, path), formatGitEntry(entry, attachments), 0644)
+}
+
+// commitEntryRemoval deletes the Markdown file of an entry that was removed
+// from the database
+func (g *GitDB) commitEntryRemoval(entry models.Entry) error {
+	if g == nil {
+		return nil
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	path, ok := g.paths[entry.UUID]
+	if !ok {
+		return nil
+	}
+
+	if err := os.Remove(filepath.Join(g.repoPath, path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(g.paths, entry.UUID)
+
+	table, err := g.GetTable(entry.TableID)
+	if err != nil {
+		return err
+	}
+
+	g.pruneBlobs()
+	return g.commit(fmt.Sprintf("Delete %q from %s", entry.Title, table.Name))
+}
+
+// blobPath returns where the blob with the given hash is kept in the
+// repository. Hashes come from front matter that may have been pulled from
+// anywhere, so anything but a SHA-256 is refused.
+func (g *GitDB) blobPath(hash string) (string, error) {
+	if !blobHashPattern.MatchString(hash) {
+		return "", fmt.Errorf("invalid attachment hash %q", hash)
+	}
+	return filepath.Join(g.repoPath, gitBlobsFolderName, hash[:2], hash[2:]), nil
+}
+
+// pushBlob copies an attachment from the local store into the repository
+func (g *GitDB) pushBlob(hash string) error {
+	path, err := g.blobPath(hash)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	content, err := ReadBlob(hash)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// pullBlob copies an attachment from the repository into the local store,
+// e.g. after it was pulled from another device
+func (g *GitDB) pullBlob(hash string) error {
+	path, err := g.blobPath(hash)
+	if err != nil {
+		return err
+	}
+
+	if local, err := BlobPath(hash); err == nil {
+		if _, err := os.Stat(local); err == nil {
+			return nil
+		}
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := ReceiveBlob(hash, file); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Join(gitBlobsFolderName, hash[:2], hash[2:]), err)
+	}
+	return nil
+}
+
+// pruneBlobs removes attachment contents no entry refers to anymore
+func (g *GitDB) pruneBlobs() {
+	used := map[string]bool{}
+	for _, attachment := range g.attachmentsSnapshot() {
+		used[attachment.Hash] = true
+	}
+
+	root := filepath.Join(g.repoPath, gitBlobsFolderName)
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		if !used[strings.Replace(rel, string(filepath.Separator), "", 1)] {
+			os.Remove(path)
+			os.Remove(filepath.Dir(path))
+		}
+		return nil
+	})
+}
+
+func (g *GitDB) attachmentsSnapshot() []models.Attachment {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return append([]models.Attachment(nil), g.Attachments...)
+}
+
+// GitSyncResult counts the commits exchanged with the remote
+type GitSyncResult struct {
+	Received  int
+	Sent      int
+	Conflicts int
+}
+
+// UsingGit reports whether the git storage backend is active
+func UsingGit() bool {
+	return gitDB != nil
+}
+
+// GitRemote returns the URL of the remote that SyncGit pulls from and pushes
+// to, or "" if none is set
+func GitRemote() string {
+	if gitDB == nil {
+		return ""
+	}
+	remote, _ := gitDB.git("remote", "get-url", "origin")
+	return remote
+}
+
+// SetGitRemote sets the remote SyncGit uses. Any URL git understands works,
+// including the path of a local bare repository. An empty URL removes it.
+func SetGitRemote(url string) error {
+	if gitDB == nil {
+		return errors.New("the git storage backend is not in use")
+	}
+
+	if url == "" {
+		if GitRemote() == "" {
+			return nil
+		}
+		_, err := gitDB.git("remote", "remove", "origin")
+		return err
+	}
+	if GitRemote() == "" {
+		_, err := gitDB.git("remote", "add", "origin", url)
+		return err
+	}
+	_, err := gitDB.git("remote", "set-url", "origin", url)
+	return err
+}
+
+// SyncGit pulls the remote's changes, merges them and pushes the result.
+// Entries edited on both sides are kept twice: the local version stays and
+// the remote one is added next to it as a copy marked "(conflict)". An edit
+// wins over a deletion of the same entry.
+func SyncGit() (GitSyncResult, error) {
+	var result GitSyncResult
+
+	g := gitDB
+	if g == nil {
+		return result, errors.New("the git storage backend is not in use")
+	}
+	if GitRemote() == "" {
+		return result, ErrNoGitRemote
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	branch, err := g.git("symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return result, err
+	}
+
+	if _, err := g.git("fetch", "-q", "origin"); err != nil {
+		return result, err
+	}
+
+	remoteRef := "origin/" + branch
+	_, err = g.git("rev-parse", "--verify", "-q", remoteRef)
+	remoteExists := err == nil
+	_, err = g.git("rev-parse", "--verify", "-q", "HEAD")
+	localExists := err == nil
+
+	if remoteExists {
+		result.Received = g.countCommits(remoteRef, "HEAD", localExists)
+
+		switch {
+		case result.Received == 0:
+		case !localExists:
+			// Nothing here yet, e.g. a new device: take the remote as is
+			if _, err := g.git("reset", "-q", "--hard", remoteRef); err != nil {
+				return result, err
+			}
+		default:
+			_, err := g.git("merge", "-q", "--no-edit", "--allow-unrelated-histories", remoteRef)
+			if err != nil {
+				result.Conflicts, err = g.resolveConflicts()
+				if err != nil {
+					g.git("merge", "--abort")
+					return result, err
+				}
+			}
+		}
+	}
+
+	if _, err := g.git("rev-parse", "--verify", "-q", "HEAD"); err == nil {
+		if remoteExists {
+			result.Sent = g.countCommits("HEAD", remoteRef, true)
+		} else {
+			result.Sent = g.countCommits("HEAD", "", false)
+		}
+
+		if result.Sent > 0 {
+			if _, err := g.git("push", "-q", "-u", "origin", branch); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, g.load()
+}
+
+// countCommits counts the commits in from that are not in exclude
+func (g *GitDB) countCommits(from, exclude string, useExclude bool) int {
+	args := []string{"rev-list", "--count", from}
+	if useExclude {
+		args = append(args, "^"+exclude)
+	}
+
+	out, err := g.git(args...)
+	if err != nil {
+		return 0
+	}
+
+	var n int
+	fmt.Sscanf(out, "%d", &n)
+	return n
+}
+
+// resolveConflicts finishes a merge that stopped on conflicting files and
+// returns how many entries were kept as copies
+func (g *GitDB) resolveConflicts() (int, error) {
+	out, err := g.git("diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return 0, err
+	}
+	if out == "" {
+		return 0, errors.New("merge failed without conflicts")
+	}
+
+	copies := 0
+	for _, path := range strings.Split(out, "\n") {
+		ours, oursErr := g.gitRaw("show", ":2:"+path)
+		theirs, theirsErr := g.gitRaw("show", ":3:"+path)
+		full := filepath.Join(g.repoPath, path)
+
+		var keep string
+		switch {
+		case oursErr != nil && theirsErr != nil:
+			continue
+		case oursErr != nil:
+			keep = theirs
+		case path == gitSchemaFileName:
+			// Keep the newer version so that older builds refuse the data
+			keep = ours
+			ourVersion, _ := parseGitSchema([]byte(ours))
+			if theirVersion, _ := parseGitSchema([]byte(theirs)); theirVersion > ourVersion {
+				keep = theirs
+			}
+		case theirsErr != nil || filepath.Base(path) == tableFileName || filepath.Ext(path) != ".md":
+			keep = ours
+		default:
+			keep = ours
+
+			entry, attachments, err := parseGitEntry([]byte(theirs))
+			if err != nil {
+				return copies, fmt.Errorf("%s: %w", path, err)
+			}
+			entry.UUID = NewUUID()
+			entry.Title += " (conflict)"
+
+			copied := make([]models.Attachment, len(attachments))
+			for i, attachment := range attachments {
+				copied[i].Filename, copied[i].MimeType, copied[i].Size, copied[i].Hash = attachment.Filename, attachment.MimeType, attachment.Size, attachment.Hash
+			}
+
+			copyPath := g.freePath(filepath.Dir(path), gitFilename(entry.Title), ".md", "")
+			if err := os.WriteFile(filepath.Join(g.repoPath, copyPath), formatGitEntry(entry, copied), 0644); err != nil {
+				return copies, err
+			}
+			copies++
+		}
+
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return copies, err
+		}
+		if err := os.WriteFile(full, []byte(keep), 0644); err != nil {
+			return copies, err
+		}
+	}
+
+	if _, err := g.git("add", "-A"); err != nil {
+		return copies, err
+	}
+
+	message := "Merge changes from remote"
+	if copies > 0 {
+		message = fmt.Sprintf("Merge changes from remote, keeping %d conflicting entries as copies", copies)
+	}
+	_, err = g.git("commit", "-q", "-m", message)
+	return copies, err
+}
+
+const (
+	StorageGit    = "git"
+	StorageSQLite = "sqlite"
+)
+
+// SwitchStorage copies all data into another backend and makes it the one
+// Initialize opens from now on. The other backend has to be empty so that
+// switching back and forth never mixes two sets of data; the old data is left
+// where it is. It returns the number of entries copied.
+func SwitchStorage(storage string) (int, error) {
+	if storage != StorageGit && storage != StorageSQLite {
+		return 0, fmt.Errorf("unknown storage %q, use %q or %q", storage, StorageGit, StorageSQLite)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return 0, errors.New("run thighpads once to set it up before switching storage")
+	}
+
+	previous := cfg.Storage
+	if previous == storage || (previous == "" && storage == StorageSQLite) {
+		return 0, fmt.Errorf("already using %s storage", storage)
+	}
+
+	if err := Initialize(); err != nil {
+		return 0, err
+	}
+
+	type storedEntry struct {
+		entry       models.Entry
+		attachments []models.Attachment
+	}
+	type storedTable struct {
+		table   models.Table
+		entries []storedEntry
+	}
+
+	var data []storedTable
+	tables, err := GetTables()
+	if err != nil {
+		return 0, err
+	}
+	for _, table := range tables {
+		entries, err := GetEntries(table.ID)
+		if err != nil {
+			return 0, err
+		}
+
+		stored := storedTable{table: table}
+		for _, entry := range entries {
+			attachments, err := GetAttachments(entry.ID)
+			if err != nil {
+				return 0, err
+			}
+			stored.entries = append(stored.entries, storedEntry{entry: entry, attachments: attachments})
+		}
+		data = append(data, stored)
+	}
+
+	cfg.Storage = storage
+	if storage == StorageSQLite {
+		cfg.Storage = ""
+	}
+	if err := config.SaveConfig(cfg); err != nil {
+		return 0, err
+	}
+
+	rollback := func(err error) (int, error) {
+		cfg.Storage = previous
+		config.SaveConfig(cfg)
+		Initialize()
+		return 0, err
+	}
+
+	if err := Initialize(); err != nil {
+		return rollback(err)
+	}
+	if existing, err := GetTables(); err != nil {
+		return rollback(err)
+	} else if len(existing) > 0 {
+		return rollback(fmt.Errorf("the %s storage already has data, remove it before switching", storage))
+	}
+
+	// Copy everything first and commit it once instead of once per entry
+	target := gitDB
+	gitDB = nil
+
+	count := 0
+	for _, stored := range data {
+		table := stored.table
+		table.ID = 0
+		table.Entries = nil
+		if err := CreateTable(&table); err != nil {
+			gitDB = target
+			return count, err
+		}
+
+		for _, se := range stored.entries {
+			entry := se.entry
+			entry.ID = 0
+			entry.TableID = table.ID
+			if err := CreateEntry(&entry); err != nil {
+				gitDB = target
+				return count, err
+			}
+
+			for _, attachment := range se.attachments {
+				attachment.ID = 0
+				attachment.EntryID = entry.ID
+				if err := CreateAttachment(&attachment); err != nil {
+					gitDB = target
+					return count, err
+				}
+			}
+			count++
+		}
+	}
+
+	gitDB = target
+	if target == nil {
+		return count, nil
+	}
+
+	target.lock.Lock()
+	defer target.lock.Unlock()
+
+	tables, err = target.GetTables()
+	if err != nil {
+		return count, err
+	}
+	for _, table := range tables {
+		if err := target.writeTable(table); err != nil {
+			return count, err
+		}
+		entries, err := target.GetEntries(table.ID)
+		if err != nil {
+			return count, err
+		}
+		for _, entry := range entries {
+			if _, _, err := target.writeEntry(entry.ID); err != nil {
+				return count, err
+			}
+		}
+	}
+
+	return count, target.commit(fmt.Sprintf("Import %d entries", count))
+}
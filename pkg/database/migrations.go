@@ -0,0 +1,317 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/models"
+	"gorm.io/gorm"
+)
+
+// SchemaVersion is the newest schema this build knows how to read and write.
+// Bump it together with a new entry at the end of migrations.
+const SchemaVersion = 4
+
+var ErrSchemaTooNew = errors.New("data was written by a newer version of ThighPads")
+
+// Migration upgrades stored data from Version-1 to Version. Every step has to
+// be implemented for both backends so that file-based installs stay in sync
+// with SQLite ones.
+type Migration struct {
+	Version     int
+	Description string
+	Gorm        func(tx *gorm.DB) error
+	File        func(db *FileDB) error
+}
+
+// MigrationPlan describes what Initialize did, or would do in dry-run mode.
+type MigrationPlan struct {
+	Backend        string
+	CurrentVersion int
+	TargetVersion  int
+	Pending        []Migration
+	BackupPath     string
+}
+
+type schemaVersion struct {
+	ID      uint `gorm:"primaryKey"`
+	Version int  `gorm:"not null"`
+}
+
+func (schemaVersion) TableName() string {
+	return "schema_version"
+}
+
+// The migrations work on the schema as it was at their version rather than on
+// the current models, which keep changing after them.
+
+type tableV1 struct {
+	ID        uint      `gorm:"primaryKey"`
+	Name      string    `gorm:"not null"`
+	Author    string    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+type entryV1 struct {
+	ID        uint      `gorm:"primaryKey"`
+	TableID   uint      `gorm:"not null"`
+	Title     string    `gorm:"not null"`
+	Tags      string    `gorm:"not null"`
+	Content   string    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+type tableV2 struct {
+	ID        uint      `gorm:"primaryKey"`
+	UUID      string    `gorm:"index"`
+	Name      string    `gorm:"not null"`
+	Author    string    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+type entryV2 struct {
+	ID        uint      `gorm:"primaryKey"`
+	UUID      string    `gorm:"index"`
+	TableID   uint      `gorm:"not null"`
+	Title     string    `gorm:"not null"`
+	Tags      string    `gorm:"not null"`
+	Content   string    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+type attachmentV3 struct {
+	ID        uint      `gorm:"primaryKey"`
+	EntryID   uint      `gorm:"not null;index"`
+	Filename  string    `gorm:"not null"`
+	MimeType  string    `gorm:"not null"`
+	Size      int64     `gorm:"not null"`
+	Hash      string    `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+type entryV4 struct {
+	ID        uint      `gorm:"primaryKey"`
+	UUID      string    `gorm:"index"`
+	TableID   uint      `gorm:"not null"`
+	Title     string    `gorm:"not null"`
+	Tags      string    `gorm:"not null"`
+	Content   string    `gorm:"not null"`
+	Language  string    `gorm:"not null;default:''"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func (tableV1) TableName() string      { return "tables" }
+func (entryV1) TableName() string      { return "entries" }
+func (tableV2) TableName() string      { return "tables" }
+func (entryV2) TableName() string      { return "entries" }
+func (attachmentV3) TableName() string { return "attachments" }
+func (entryV4) TableName() string      { return "entries" }
+
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Create tables and entries",
+		Gorm: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&tableV1{}, &entryV1{})
+		},
+		File: func(db *FileDB) error {
+			return nil
+		},
+	},
+	{
+		Version:     2,
+		Description: "Add stable UUIDs and update timestamps",
+		Gorm: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&tableV2{}, &entryV2{}); err != nil {
+				return err
+			}
+
+			var tables []tableV2
+			if err := tx.Where("uuid IS NULL OR uuid = ''").Find(&tables).Error; err != nil {
+				return err
+			}
+			for _, table := range tables {
+				if err := tx.Model(&table).UpdateColumn("uuid", NewUUID()).Error; err != nil {
+					return err
+				}
+			}
+
+			var entries []entryV2
+			if err := tx.Where("uuid IS NULL OR uuid = ''").Find(&entries).Error; err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				if err := tx.Model(&entry).UpdateColumn("uuid", NewUUID()).Error; err != nil {
+					return err
+				}
+			}
+
+			return tx.Model(&entryV2{}).Where("updated_at IS NULL").
+				UpdateColumn("updated_at", gorm.Expr("created_at")).Error
+		},
+		File: func(db *FileDB) error {
+			for i := range db.Tables {
+				if db.Tables[i].UUID == "" {
+					db.Tables[i].UUID = NewUUID()
+				}
+			}
+			for i := range db.Entries {
+				if db.Entries[i].UUID == "" {
+					db.Entries[i].UUID = NewUUID()
+				}
+				if db.Entries[i].UpdatedAt.IsZero() {
+					db.Entries[i].UpdatedAt = db.Entries[i].CreatedAt
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     3,
+		Description: "Add attachments",
+		Gorm: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&attachmentV3{})
+		},
+		File: func(db *FileDB) error {
+			if db.Attachments == nil {
+				db.Attachments = []models.Attachment{}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     4,
+		Description: "Add entry languages",
+		Gorm: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&entryV4{})
+		},
+		// Entries without a language read as "" from the file
+		File: func(db *FileDB) error { return nil },
+	},
+}
+
+func pendingMigrations(current int) ([]Migration, error) {
+	if current > SchemaVersion {
+		return nil, fmt.Errorf("%w: data is at schema version %d but this build only supports up to %d, run 'thighpads --update'",
+			ErrSchemaTooNew, current, SchemaVersion)
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+func readGormSchemaVersion(db *gorm.DB) (int, error) {
+	if !db.Migrator().HasTable(&schemaVersion{}) {
+		return 0, nil
+	}
+
+	var row schemaVersion
+	err := db.Order("id desc").Limit(1).Find(&row).Error
+	if err != nil {
+		return 0, err
+	}
+	return row.Version, nil
+}
+
+func migrateGorm(db *gorm.DB, dbPath string, dryRun bool) (MigrationPlan, error) {
+	plan := MigrationPlan{Backend: "sqlite", TargetVersion: SchemaVersion}
+
+	current, err := readGormSchemaVersion(db)
+	if err != nil {
+		return plan, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	plan.CurrentVersion = current
+
+	plan.Pending, err = pendingMigrations(current)
+	if err != nil || dryRun || len(plan.Pending) == 0 {
+		return plan, err
+	}
+
+	if current > 0 || db.Migrator().HasTable(&tableV1{}) {
+		plan.BackupPath, err = backupFile(dbPath, current)
+		if err != nil {
+			return plan, fmt.Errorf("failed to back up database before migrating: %w", err)
+		}
+	}
+
+	for _, m := range plan.Pending {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Gorm(tx); err != nil {
+				return err
+			}
+			if err := tx.AutoMigrate(&schemaVersion{}); err != nil {
+				return err
+			}
+			return tx.Save(&schemaVersion{ID: 1, Version: m.Version}).Error
+		})
+		if err != nil {
+			return plan, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+		}
+	}
+
+	return plan, nil
+}
+
+func migrateFile(db *FileDB, dryRun bool) (MigrationPlan, error) {
+	plan := MigrationPlan{
+		Backend:        "file",
+		CurrentVersion: db.SchemaVersion,
+		TargetVersion:  SchemaVersion,
+	}
+
+	var err error
+	plan.Pending, err = pendingMigrations(db.SchemaVersion)
+	if err != nil || dryRun || len(plan.Pending) == 0 {
+		return plan, err
+	}
+
+	if _, statErr := os.Stat(db.dbPath); statErr == nil {
+		plan.BackupPath, err = backupFile(db.dbPath, db.SchemaVersion)
+		if err != nil {
+			return plan, fmt.Errorf("failed to back up database before migrating: %w", err)
+		}
+	}
+
+	for _, m := range plan.Pending {
+		if err := m.File(db); err != nil {
+			return plan, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+		}
+		db.SchemaVersion = m.Version
+	}
+
+	return plan, db.Save()
+}
+
+// backupFile copies path next to itself so a failed or unwanted migration can
+// be rolled back by hand.
+func backupFile(path string, version int) (string, error) {
+	backupPath := fmt.Sprintf("%s.v%d.bak", path, version)
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(backupPath)
+	if err != nil {
+		return "", err
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return "", err
+	}
+
+	return backupPath, nil
+}
@@ -6,6 +6,7 @@ import (
 
 type Table struct {
 	ID        uint      `gorm:"primaryKey"`
+	UUID      string    `gorm:"index"`
 	Name      string    `gorm:"not null"`
 	Author    string    `gorm:"not null"`
 	CreatedAt time.Time `gorm:"autoCreateTime"`
@@ -14,13 +15,41 @@ type Table struct {
 
 type Entry struct {
 	ID        uint      `gorm:"primaryKey"`
+	UUID      string    `gorm:"index"`
 	TableID   uint      `gorm:"not null"`
 	Title     string    `gorm:"not null"`
 	Tags      string    `gorm:"not null"`
 	Content   string    `gorm:"not null"`
+	Language  string    `gorm:"not null;default:''"`
 	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+
+	Attachments []Attachment `gorm:"-" json:"-"`
+}
+
+// Attachment is a file attached to an entry. The content is stored once per
+// Hash (hex SHA-256) in the attachments folder, see database.StoreBlob.
+type Attachment struct {
+	ID        uint      `gorm:"primaryKey"`
+	EntryID   uint      `gorm:"not null;index"`
+	Filename  string    `gorm:"not null"`
+	MimeType  string    `gorm:"not null"`
+	Size      int64     `gorm:"not null"`
+	Hash      string    `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+
+	// Data carries the content of attachments that are being imported and
+	// are not in the blob store yet
+	Data []byte `gorm:"-" json:"-"`
 }
 
 type Config struct {
 	Username string `json:"username"`
+	// Storage selects the backend: "git" for a git repository of Markdown
+	// files, otherwise SQLite with the JSON file as fallback
+	Storage string `json:"storage,omitempty"`
+	// Theme is the name of a built-in theme or of a file in themes/
+	Theme string `json:"theme,omitempty"`
+	// DisableMouse leaves the mouse to the terminal, e.g. for selecting text
+	DisableMouse bool `json:"disableMouse,omitempty"`
 }
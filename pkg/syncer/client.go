@@ -0,0 +1,261 @@
+package syncer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/config"
+	"github.com/s42yt/thighpads/pkg/database"
+)
+
+// serverClient talks to a sync server on behalf of this device
+type serverClient struct {
+	base       string
+	token      string
+	deviceID   string
+	deviceName string
+	http       *http.Client
+}
+
+func newServerClient(syncConfig *config.SyncConfig, state *State) *serverClient {
+	return &serverClient{
+		base:       strings.TrimRight(syncConfig.Server, "/"),
+		token:      syncConfig.Token,
+		deviceID:   state.DeviceID,
+		deviceName: deviceName(syncConfig),
+		http:       &http.Client{Timeout: time.Minute},
+	}
+}
+
+func (c *serverClient) do(method, path string, body io.Reader) (*http.Response, error) {
+	request, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+c.token)
+	request.Header.Set(deviceHeader, c.deviceID)
+	request.Header.Set(deviceNameHeader, c.deviceName)
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode >= 300 && response.StatusCode != http.StatusNotFound {
+		defer response.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		if response.StatusCode == http.StatusUnauthorized {
+			return nil, errors.New("the sync server rejected the token")
+		}
+		return nil, fmt.Errorf("sync server: %s: %s", response.Status, strings.TrimSpace(string(message)))
+	}
+
+	return response, nil
+}
+
+func (c *serverClient) doJSON(method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	response, err := c.do(method, path, reader)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return errors.New("sync server: " + path + " not found, is this a ThighPads server?")
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+// pushBlobs uploads the attachments of changes the server does not have yet
+func (c *serverClient) pushBlobs(changes []Change) error {
+	for _, change := range changes {
+		if change.Entry == nil {
+			continue
+		}
+
+		for _, attachment := range change.Entry.Attachments {
+			if !validHash(attachment.Hash) {
+				continue
+			}
+
+			response, err := c.do(http.MethodHead, "/api/v1/blobs/"+attachment.Hash, nil)
+			if err != nil {
+				return err
+			}
+			response.Body.Close()
+			if response.StatusCode != http.StatusNotFound {
+				continue
+			}
+
+			content, err := database.ReadBlob(attachment.Hash)
+			if err != nil {
+				return err
+			}
+			response, err = c.do(http.MethodPut, "/api/v1/blobs/"+attachment.Hash, bytes.NewReader(content))
+			if err != nil {
+				return err
+			}
+			response.Body.Close()
+		}
+	}
+
+	return nil
+}
+
+// pullBlobs downloads the attachments of items that are missing locally.
+// Failures are left to applyRemote, which reports the change as pending.
+func (c *serverClient) pullBlobs(items []FeedItem) {
+	for _, item := range items {
+		if item.Entry == nil {
+			continue
+		}
+
+		for _, attachment := range item.Entry.Attachments {
+			if pullBlob("", attachment.Hash) == nil || !validHash(attachment.Hash) {
+				continue
+			}
+
+			response, err := c.do(http.MethodGet, "/api/v1/blobs/"+attachment.Hash, nil)
+			if err != nil {
+				continue
+			}
+			if response.StatusCode == http.StatusOK {
+				database.ReceiveBlob(attachment.Hash, response.Body)
+			}
+			response.Body.Close()
+		}
+	}
+}
+
+// syncServer is Sync through a sync server instead of a shared folder
+func syncServer(syncConfig *config.SyncConfig) (Result, error) {
+	var result Result
+
+	state, err := LoadState()
+	if err != nil {
+		return result, err
+	}
+
+	local, err := scan()
+	if err != nil {
+		return result, err
+	}
+
+	// A server this device has not synced with before gets the full history
+	// and sends its whole feed
+	rewrite := state.Folder != syncConfig.Server
+	if rewrite {
+		state.Revision = 0
+	}
+
+	client := newServerClient(syncConfig, state)
+
+	changes := state.localChanges(local, rewrite)
+	if len(changes) > 0 {
+		if err := client.pushBlobs(changes); err != nil {
+			return result, err
+		}
+
+		var pushed pushResponse
+		if err := client.doJSON(http.MethodPost, "/api/v1/changes", pushRequest{Changes: changes}, &pushed); err != nil {
+			return result, err
+		}
+	}
+	result.Sent = len(changes)
+
+	// The state is saved from here on, so that the changes just pushed are
+	// not sent again even if pulling fails
+	state.Folder = syncConfig.Server
+	if err := state.Save(); err != nil {
+		return result, err
+	}
+
+	for {
+		var feed feedResponse
+		path := fmt.Sprintf("/api/v1/changes?since=%d", state.Revision)
+		if err := client.doJSON(http.MethodGet, path, nil, &feed); err != nil {
+			return result, err
+		}
+
+		client.pullBlobs(feed.Changes)
+
+		revision := state.Revision
+		if err := state.applyFeed("", feed.Changes, &result); err != nil {
+			return result, err
+		}
+
+		state.Devices = nil
+		for _, device := range feed.Devices {
+			if device.ID != state.DeviceID {
+				state.Devices = append(state.Devices, device)
+			}
+		}
+
+		// Stop at a pending change instead of asking for the same page again
+		if !feed.More || state.Revision == revision {
+			break
+		}
+	}
+
+	state.LastSync = time.Now()
+	result.Devices = state.Devices
+	return result, state.Save()
+}
+
+// SetServer chooses a sync server instead of a shared folder. The token may
+// also be given as the user part of the URL, as in http://TOKEN@host:8420.
+func SetServer(server, token string) error {
+	parsed, err := url.Parse(strings.TrimSpace(server))
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return fmt.Errorf("%s is not an http:// or https:// URL", server)
+	}
+
+	if parsed.User != nil {
+		if token == "" {
+			token = parsed.User.Username()
+		}
+		parsed.User = nil
+	}
+	server = strings.TrimRight(parsed.String(), "/")
+
+	syncConfig, err := config.LoadSyncConfig()
+	if err != nil {
+		return err
+	}
+
+	// Keep the token when only the URL is entered again
+	if token == "" && syncConfig.Server == server {
+		token = syncConfig.Token
+	}
+	if token == "" {
+		return errors.New("the sync server needs a token, shown when it starts")
+	}
+
+	syncConfig.Folder = ""
+	syncConfig.Server = server
+	syncConfig.Token = token
+	return config.SaveSyncConfig(syncConfig)
+}
+
+func isServerURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
@@ -0,0 +1,70 @@
+package syncer
+
+// Clock is a vector clock: for every device, how many changes it has made to
+// an object. Comparing the clocks of two versions tells whether one of them
+// already includes the other or whether they were edited concurrently.
+type Clock map[string]uint64
+
+type Ordering int
+
+const (
+	Equal Ordering = iota
+	Before
+	After
+	Concurrent
+)
+
+// Compare reports how c relates to other, e.g. Before if other already
+// includes every change in c
+func (c Clock) Compare(other Clock) Ordering {
+	less, greater := false, false
+
+	for device, n := range c {
+		if n > other[device] {
+			greater = true
+		} else if n < other[device] {
+			less = true
+		}
+	}
+	for device, n := range other {
+		if _, ok := c[device]; !ok && n > 0 {
+			less = true
+		}
+	}
+
+	switch {
+	case less && greater:
+		return Concurrent
+	case less:
+		return Before
+	case greater:
+		return After
+	}
+	return Equal
+}
+
+// Merge returns a clock that includes the changes of both c and other
+func (c Clock) Merge(other Clock) Clock {
+	merged := c.Copy()
+	for device, n := range other {
+		if n > merged[device] {
+			merged[device] = n
+		}
+	}
+	return merged
+}
+
+// Tick returns a copy of c with one more change by device
+func (c Clock) Tick(device string) Clock {
+	ticked := c.Copy()
+	ticked[device]++
+	return ticked
+}
+
+func (c Clock) Copy() Clock {
+	copied := make(Clock, len(c))
+	for device, n := range c {
+		copied[device] = n
+	}
+	return copied
+}
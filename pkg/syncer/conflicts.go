@@ -0,0 +1,52 @@
+package syncer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/s42yt/thighpads/pkg/config"
+)
+
+// DismissConflict accepts the version sync kept
+func DismissConflict(index int) error {
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(state.Conflicts) {
+		return errors.New("conflict not found")
+	}
+
+	state.Conflicts = append(state.Conflicts[:index], state.Conflicts[index+1:]...)
+	return state.Save()
+}
+
+// UseOtherVersion replaces the kept version of a conflict with the other one.
+// This is a local edit like any other, so the next sync sends it to the other
+// devices, where it supersedes both versions.
+func UseOtherVersion(index int) error {
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(state.Conflicts) {
+		return errors.New("conflict not found")
+	}
+
+	conflict := state.Conflicts[index]
+
+	// The other version's attachments may only be in the shared folder
+	root := ""
+	if syncConfig, err := config.LoadSyncConfig(); err == nil {
+		root, _ = syncRoot(syncConfig)
+	}
+
+	if err := apply(root, conflict.Other); errors.Is(err, errPending) {
+		return fmt.Errorf("%q cannot be restored: its table or attachments are missing", conflict.Other.Title())
+	} else if err != nil {
+		return err
+	}
+
+	state.Conflicts = append(state.Conflicts[:index], state.Conflicts[index+1:]...)
+	return state.Save()
+}
@@ -0,0 +1,29 @@
+package syncer
+
+import (
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/database"
+)
+
+// syncGit pulls and pushes the git storage backend's repository instead of
+// going through a shared folder. Git merges the changes itself, so there are
+// no conflicts to review: entries edited on both sides are kept as copies.
+func syncGit() (Result, error) {
+	var result Result
+
+	gitResult, err := database.SyncGit()
+	result.Sent = gitResult.Sent
+	result.Received = gitResult.Received
+	result.Conflicts = gitResult.Conflicts
+	if err != nil {
+		return result, err
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		return result, err
+	}
+	state.LastSync = time.Now()
+	return result, state.Save()
+}
@@ -0,0 +1,191 @@
+package syncer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	TableKind = "table"
+	EntryKind = "entry"
+)
+
+// Change is one line of a device's change log: the full new state of a table
+// or entry, or its deletion, together with the clocks that order it
+type Change struct {
+	Device  string    `json:"device"`
+	Lamport uint64    `json:"lamport"`
+	Clock   Clock     `json:"clock"`
+	Kind    string    `json:"kind"`
+	UUID    string    `json:"uuid"`
+	At      time.Time `json:"at"`
+	Deleted bool      `json:"deleted,omitempty"`
+
+	Table *TableRecord `json:"table,omitempty"`
+	Entry *EntryRecord `json:"entry,omitempty"`
+}
+
+type TableRecord struct {
+	Name      string    `json:"name"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type EntryRecord struct {
+	TableUUID   string             `json:"tableUuid"`
+	Title       string             `json:"title"`
+	Tags        string             `json:"tags"`
+	Content     string             `json:"content"`
+	Language    string             `json:"language,omitempty"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+	Attachments []AttachmentRecord `json:"attachments,omitempty"`
+}
+
+// AttachmentRecord refers to a blob in the shared folder by its hash
+type AttachmentRecord struct {
+	Filename string `json:"filename"`
+	MimeType string `json:"mimeType"`
+	Size     int64  `json:"size"`
+	Hash     string `json:"hash"`
+}
+
+// Title is what the TUI calls the changed object
+func (c Change) Title() string {
+	switch {
+	case c.Entry != nil:
+		return c.Entry.Title
+	case c.Table != nil:
+		return c.Table.Name
+	}
+	return c.UUID
+}
+
+// hash identifies the content of a change. Timestamps are left out so that
+// applying a change and scanning it back yields the same hash.
+func (c Change) hash() string {
+	if c.Deleted {
+		return "deleted"
+	}
+
+	var parts []string
+	switch {
+	case c.Table != nil:
+		parts = []string{c.Table.Name, c.Table.Author}
+	case c.Entry != nil:
+		parts = []string{c.Entry.TableUUID, c.Entry.Title, c.Entry.Tags, c.Entry.Content}
+		// Left out when empty so that entries keep the hash they had before
+		// entries had languages
+		if c.Entry.Language != "" {
+			parts = append(parts, "language:"+c.Entry.Language)
+		}
+
+		var attachments []string
+		for _, attachment := range c.Entry.Attachments {
+			attachments = append(attachments, attachment.Hash+" "+attachment.Filename)
+		}
+		sort.Strings(attachments)
+		parts = append(parts, attachments...)
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Device describes a device that syncs through the folder
+type Device struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	LastSync time.Time `json:"lastSync"`
+}
+
+func logPath(root, device string) string {
+	return filepath.Join(root, devicesFolderName, device+".jsonl")
+}
+
+// readLog returns the changes in a device's log. Lines that do not parse,
+// such as a last line that is still being copied by the sync tool, are
+// skipped; they are read again on the next sync.
+func readLog(path string) ([]Change, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var changes []Change
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	for scanner.Scan() {
+		var change Change
+		if err := json.Unmarshal(scanner.Bytes(), &change); err != nil || change.UUID == "" {
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, scanner.Err()
+}
+
+// appendLog adds changes to the end of a device's log. Only the device itself
+// ever writes its log, so sync tools never see conflicting edits of a file.
+func appendLog(path string, changes []Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, change := range changes {
+		if err := encoder.Encode(change); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// readDevices lists the devices that have synced through the folder
+func readDevices(root string) []Device {
+	paths, _ := filepath.Glob(filepath.Join(root, devicesFolderName, "*.json"))
+
+	var devices []Device
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var device Device
+		if err := json.Unmarshal(content, &device); err == nil && device.ID != "" {
+			devices = append(devices, device)
+		}
+	}
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Name < devices[j].Name })
+	return devices
+}
+
+func writeDevice(root string, device Device) error {
+	content, err := json.MarshalIndent(device, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(root, devicesFolderName, device.ID+".json"), content, 0644)
+}
@@ -0,0 +1,367 @@
+package syncer
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/config"
+)
+
+const (
+	feedFileName    = "feed.jsonl"
+	devicesFileName = "devices.json"
+	serverStateName = "state.json"
+	tokenFileName   = "token"
+
+	// feedPageSize limits how many changes one request returns
+	feedPageSize = 500
+
+	maxPushSize = 64 << 20
+	maxBlobSize = 512 << 20
+
+	deviceHeader     = "X-ThighPads-Device"
+	deviceNameHeader = "X-ThighPads-Device-Name"
+)
+
+// FeedItem is a change in a sync server's feed. Revisions count up from 1 in
+// the order the server received the changes.
+type FeedItem struct {
+	Revision uint64 `json:"revision"`
+	Change
+}
+
+type feedResponse struct {
+	// Revision is the newest revision in the feed
+	Revision uint64     `json:"revision"`
+	Changes  []FeedItem `json:"changes"`
+	More     bool       `json:"more,omitempty"`
+	Devices  []Device   `json:"devices,omitempty"`
+}
+
+type pushRequest struct {
+	Changes []Change `json:"changes"`
+}
+
+type pushResponse struct {
+	Revision uint64 `json:"revision"`
+}
+
+// Server shares the local database with other devices over HTTP. Devices
+// push their changes, which the server numbers, applies to its own database
+// and hands out to everyone else through a feed. Edits made on the server's
+// own machine are published the same way, so it takes part in sync like any
+// other device.
+type Server struct {
+	mu      sync.Mutex
+	root    string
+	token   string
+	state   *State
+	feed    []FeedItem
+	devices map[string]Device
+}
+
+// ServerToken returns the token thighpads serve uses when none is given,
+// creating one on first use
+func ServerToken() (string, error) {
+	root, err := config.GetServerPath()
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(root, tokenFileName)
+	if content, err := os.ReadFile(path); err == nil {
+		if token := strings.TrimSpace(string(content)); token != "" {
+			return token, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(random)
+
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return "", err
+	}
+	return token, os.WriteFile(path, []byte(token+"\n"), 0600)
+}
+
+// NewServer opens the server's feed. The database has to be initialized.
+func NewServer(token string) (*Server, error) {
+	if token == "" {
+		return nil, errors.New("the sync server needs a token")
+	}
+
+	root, err := config.GetServerPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(root, blobsFolderName), 0700); err != nil {
+		return nil, err
+	}
+
+	state, err := loadState(filepath.Join(root, serverStateName))
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{root: root, token: token, state: state, devices: map[string]Device{}}
+
+	changes, err := readLog(filepath.Join(root, feedFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for i, change := range changes {
+		server.feed = append(server.feed, FeedItem{Revision: uint64(i + 1), Change: change})
+	}
+
+	if content, err := os.ReadFile(filepath.Join(root, devicesFileName)); err == nil {
+		var devices []Device
+		if err := json.Unmarshal(content, &devices); err == nil {
+			for _, device := range devices {
+				server.devices[device.ID] = device
+			}
+		}
+	}
+
+	// Publish the database as it is now, and catch up on pushes that were
+	// not applied before the server last stopped
+	if err := server.refresh(); err != nil {
+		return nil, err
+	}
+
+	return server, nil
+}
+
+// Handler returns the HTTP API:
+//
+//	GET  /api/v1/changes?since=N  changes after revision N
+//	POST /api/v1/changes          push changes
+//	GET  /api/v1/blobs/{hash}     download an attachment
+//	PUT  /api/v1/blobs/{hash}     upload an attachment
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/changes", s.handleFeed)
+	mux.HandleFunc("POST /api/v1/changes", s.handlePush)
+	mux.HandleFunc("GET /api/v1/blobs/{hash}", s.handleGetBlob)
+	mux.HandleFunc("PUT /api/v1/blobs/{hash}", s.handlePutBlob)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleFeed(w http.ResponseWriter, r *http.Request) {
+	since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+	if err != nil && r.URL.Query().Get("since") != "" {
+		http.Error(w, "invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.seen(r)
+
+	response := feedResponse{Revision: uint64(len(s.feed)), Changes: []FeedItem{}}
+	if since < uint64(len(s.feed)) {
+		end := len(s.feed)
+		if end-int(since) > feedPageSize {
+			end = int(since) + feedPageSize
+			response.More = true
+		}
+		response.Changes = s.feed[since:end]
+	}
+
+	for _, device := range s.devices {
+		response.Devices = append(response.Devices, device)
+	}
+
+	writeJSON(w, response)
+}
+
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	var request pushRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPushSize)).Decode(&request); err != nil {
+		http.Error(w, "invalid changes: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var changes []Change
+	for _, change := range request.Changes {
+		if change.UUID == "" || (change.Kind != TableKind && change.Kind != EntryKind) {
+			http.Error(w, "invalid change", http.StatusBadRequest)
+			return
+		}
+		changes = append(changes, change)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(changes); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.refresh(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.seen(r)
+
+	writeJSON(w, pushResponse{Revision: uint64(len(s.feed))})
+}
+
+func (s *Server) handleGetBlob(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	if !validHash(hash) {
+		http.Error(w, "invalid hash", http.StatusBadRequest)
+		return
+	}
+
+	http.ServeFile(w, r, sharedBlobPath(s.root, hash))
+}
+
+func (s *Server) handlePutBlob(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	if !validHash(hash) {
+		http.Error(w, "invalid hash", http.StatusBadRequest)
+		return
+	}
+
+	content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBlobSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != hash {
+		http.Error(w, "content does not match its hash", http.StatusBadRequest)
+		return
+	}
+
+	if err := writeFileAtomic(sharedBlobPath(s.root, hash), content); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// append adds changes to the end of the feed
+func (s *Server) append(changes []Change) error {
+	if err := appendLog(filepath.Join(s.root, feedFileName), changes); err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		s.feed = append(s.feed, FeedItem{Revision: uint64(len(s.feed) + 1), Change: change})
+	}
+	return nil
+}
+
+// refresh publishes edits made in the server's own database and applies the
+// pushed changes it has not applied yet
+func (s *Server) refresh() error {
+	local, err := scan()
+	if err != nil {
+		return err
+	}
+
+	changes := s.state.localChanges(local, s.state.Folder != s.root)
+	if err := pushBlobs(s.root, changes); err != nil {
+		return err
+	}
+	if err := s.append(changes); err != nil {
+		return err
+	}
+	s.state.Folder = s.root
+
+	var result Result
+	if err := s.state.applyFeed(s.root, s.feed[s.state.Revision:], &result); err != nil {
+		return err
+	}
+
+	s.state.LastSync = time.Now()
+	return s.state.Save()
+}
+
+// seen records the device that made a request
+func (s *Server) seen(r *http.Request) {
+	id := r.Header.Get(deviceHeader)
+	if id == "" {
+		return
+	}
+
+	s.devices[id] = Device{ID: id, Name: r.Header.Get(deviceNameHeader), LastSync: time.Now()}
+
+	var devices []Device
+	for _, device := range s.devices {
+		devices = append(devices, device)
+	}
+	if content, err := json.MarshalIndent(devices, "", "  "); err == nil {
+		writeFileAtomic(filepath.Join(s.root, devicesFileName), content)
+	}
+}
+
+// applyFeed applies the changes of other devices in items. Revision moves
+// past every change that was applied or skipped, but stops at the first one
+// that is still pending so that it is tried again next time.
+func (s *State) applyFeed(root string, items []FeedItem, result *Result) error {
+	pending := false
+
+	for _, item := range items {
+		if item.Device != s.DeviceID {
+			before := result.Pending
+			if err := s.applyRemote(root, item.Change, result); err != nil {
+				return err
+			}
+			if result.Pending > before {
+				pending = true
+			}
+		}
+
+		if !pending {
+			s.Revision = item.Revision
+		}
+	}
+
+	return nil
+}
+
+func validHash(hash string) bool {
+	if len(hash) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil && strings.ToLower(hash) == hash
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
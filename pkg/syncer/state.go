@@ -0,0 +1,151 @@
+package syncer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/config"
+	"github.com/s42yt/thighpads/pkg/database"
+)
+
+// State is what this device remembers between syncs. It lives in the config
+// folder, not in the shared folder, because it describes the local database.
+type State struct {
+	DeviceID string `json:"deviceId"`
+	// Folder is the shared folder or sync server the objects were last
+	// synced through
+	Folder  string             `json:"folder"`
+	Lamport uint64             `json:"lamport"`
+	Objects map[string]*Object `json:"objects"`
+	// Revision is the last change read from a sync server's feed
+	Revision uint64 `json:"revision,omitempty"`
+	// Devices are the other devices a sync server reported at the last sync
+	Devices []Device `json:"devices,omitempty"`
+	// Conflicts are concurrent edits that were resolved automatically and
+	// wait for the user to review them
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+	LastSync  time.Time  `json:"lastSync"`
+
+	// path is where the state is saved, see loadState
+	path string
+}
+
+// Object is the last synced version of a table or entry
+type Object struct {
+	Kind    string `json:"kind"`
+	Clock   Clock  `json:"clock"`
+	Hash    string `json:"hash"`
+	Deleted bool   `json:"deleted,omitempty"`
+	// Lamport and Device of the change that produced this version break ties
+	// between concurrent edits the same way on every device
+	Lamport uint64 `json:"lamport"`
+	Device  string `json:"device"`
+}
+
+// Conflict is a table or entry that was edited on two devices at once. Kept
+// is the version every device settled on, Other the one that lost.
+type Conflict struct {
+	UUID       string    `json:"uuid"`
+	Kind       string    `json:"kind"`
+	Kept       Change    `json:"kept"`
+	Other      Change    `json:"other"`
+	DetectedAt time.Time `json:"detectedAt"`
+}
+
+func statePath() (string, error) {
+	configPath, err := config.GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configPath, config.SyncStateFileName), nil
+}
+
+// LoadState reads the sync state, creating a device ID on first use
+func LoadState() (*State, error) {
+	path, err := statePath()
+	if err != nil {
+		return nil, err
+	}
+	return loadState(path)
+}
+
+// loadState reads the sync state saved at path. The sync server keeps its own
+// next to its feed.
+func loadState(path string) (*State, error) {
+	state := &State{path: path}
+	content, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(content, state); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if state.DeviceID == "" {
+		state.DeviceID = database.NewUUID()
+	}
+	if state.Objects == nil {
+		state.Objects = map[string]*Object{}
+	}
+
+	return state, nil
+}
+
+func (s *State) Save() error {
+	path := s.path
+	if path == "" {
+		var err error
+		if path, err = statePath(); err != nil {
+			return err
+		}
+	}
+
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that a crash never leaves a
+	// truncated state behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// object returns the synced version of uuid, or an empty one for objects this
+// device has never seen
+func (s *State) object(uuid, kind string) *Object {
+	if object, ok := s.Objects[uuid]; ok {
+		return object
+	}
+	return &Object{Kind: kind, Clock: Clock{}}
+}
+
+// tick advances the Lamport clock past any change seen so far
+func (s *State) tick() uint64 {
+	s.Lamport++
+	return s.Lamport
+}
+
+func (s *State) observe(lamport uint64) {
+	if lamport > s.Lamport {
+		s.Lamport = lamport
+	}
+}
+
+// dropConflicts forgets the conflicts of uuid, e.g. once a newer version has
+// superseded both sides
+func (s *State) dropConflicts(uuid string) {
+	var remaining []Conflict
+	for _, conflict := range s.Conflicts {
+		if conflict.UUID != uuid {
+			remaining = append(remaining, conflict)
+		}
+	}
+	s.Conflicts = remaining
+}
@@ -0,0 +1,733 @@
+// Package syncer replicates the database between devices through a shared
+// folder kept in sync by another tool, such as Syncthing, Dropbox or an NFS
+// mount. Every device appends its changes to its own log in the folder and
+// replays the logs of the others; vector clocks tell apart changes that
+// supersede each other from concurrent edits, which become conflicts. A sync
+// server, see Server, can take the place of the shared folder.
+package syncer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/s42yt/thighpads/pkg/config"
+	"github.com/s42yt/thighpads/pkg/data"
+	"github.com/s42yt/thighpads/pkg/database"
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+const (
+	// SyncFolderName is created inside the shared folder so that it can be
+	// the root of a folder that is also used for other things
+	SyncFolderName    = "ThighPads Sync"
+	devicesFolderName = "devices"
+	blobsFolderName   = "blobs"
+)
+
+var ErrNotConfigured = errors.New("sync is not set up, choose a shared folder first")
+
+// errPending means a change cannot be applied yet, e.g. because the sync tool
+// has not copied its table or attachments over. It is retried on the next sync.
+var errPending = errors.New("change is not complete yet")
+
+type Result struct {
+	Sent      int
+	Received  int
+	Pending   int
+	Conflicts int
+	Devices   []Device
+}
+
+// Sync sends the local changes made since the last sync to the shared folder
+// and applies the changes of the other devices
+func Sync() (Result, error) {
+	if database.UsingGit() {
+		return syncGit()
+	}
+
+	var result Result
+
+	syncConfig, err := config.LoadSyncConfig()
+	if err != nil {
+		return result, err
+	}
+	if syncConfig.Server != "" {
+		return syncServer(syncConfig)
+	}
+	root, err := syncRoot(syncConfig)
+	if err != nil {
+		return result, err
+	}
+
+	for _, dir := range []string{devicesFolderName, blobsFolderName} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			return result, err
+		}
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		return result, err
+	}
+
+	local, err := scan()
+	if err != nil {
+		return result, err
+	}
+
+	// A folder this device has not written to before gets the full history,
+	// not only what changed since the last sync
+	ownLog := logPath(root, state.DeviceID)
+	_, err = os.Stat(ownLog)
+	rewrite := os.IsNotExist(err) || state.Folder != root
+
+	changes := state.localChanges(local, rewrite)
+	if err := pushBlobs(root, changes); err != nil {
+		return result, err
+	}
+	if err := appendLog(ownLog, changes); err != nil {
+		return result, err
+	}
+	result.Sent = len(changes)
+
+	paths, err := filepath.Glob(filepath.Join(root, devicesFolderName, "*.jsonl"))
+	if err != nil {
+		return result, err
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if path == ownLog {
+			continue
+		}
+
+		changes, err := readLog(path)
+		if err != nil {
+			return result, err
+		}
+
+		for _, change := range changes {
+			if err := state.applyRemote(root, change, &result); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	state.Folder = root
+	state.LastSync = time.Now()
+
+	if err := writeDevice(root, Device{ID: state.DeviceID, Name: deviceName(syncConfig), LastSync: state.LastSync}); err != nil {
+		return result, err
+	}
+	if err := state.Save(); err != nil {
+		return result, err
+	}
+
+	for _, device := range readDevices(root) {
+		if device.ID != state.DeviceID {
+			result.Devices = append(result.Devices, device)
+		}
+	}
+
+	return result, nil
+}
+
+func syncRoot(syncConfig *config.SyncConfig) (string, error) {
+	if syncConfig.Folder == "" {
+		return "", ErrNotConfigured
+	}
+
+	folder, err := data.ExpandHome(syncConfig.Folder)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(folder, SyncFolderName), nil
+}
+
+func deviceName(syncConfig *config.SyncConfig) string {
+	if syncConfig.DeviceName != "" {
+		return syncConfig.DeviceName
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		return hostname
+	}
+	return "Unknown device"
+}
+
+// scan returns the current content of every table and entry, keyed by UUID.
+// Entries that share a UUID, e.g. because the same file was imported twice,
+// are given a new one first.
+func scan() (map[string]Change, error) {
+	tables, err := database.GetTables()
+	if err != nil {
+		return nil, err
+	}
+
+	objects := map[string]Change{}
+	for _, table := range tables {
+		if table.UUID == "" {
+			continue
+		}
+
+		objects[table.UUID] = Change{Kind: TableKind, UUID: table.UUID, Table: tableRecord(table)}
+
+		entries, err := database.GetEntries(table.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if _, taken := objects[entry.UUID]; taken || entry.UUID == "" {
+				entry.UUID = database.NewUUID()
+				if err := database.UpdateEntry(&entry); err != nil {
+					return nil, err
+				}
+			}
+
+			attachments, err := database.GetAttachments(entry.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			objects[entry.UUID] = Change{Kind: EntryKind, UUID: entry.UUID, Entry: entryRecord(table.UUID, entry, attachments)}
+		}
+	}
+
+	return objects, nil
+}
+
+func tableRecord(table models.Table) *TableRecord {
+	return &TableRecord{Name: table.Name, Author: table.Author, CreatedAt: table.CreatedAt}
+}
+
+func entryRecord(tableUUID string, entry models.Entry, attachments []models.Attachment) *EntryRecord {
+	record := &EntryRecord{
+		TableUUID: tableUUID,
+		Title:     entry.Title,
+		Tags:      entry.Tags,
+		Content:   entry.Content,
+		Language:  entry.Language,
+		CreatedAt: entry.CreatedAt,
+		UpdatedAt: entry.UpdatedAt,
+	}
+
+	for _, attachment := range attachments {
+		record.Attachments = append(record.Attachments, AttachmentRecord{
+			Filename: attachment.Filename,
+			MimeType: attachment.MimeType,
+			Size:     attachment.Size,
+			Hash:     attachment.Hash,
+		})
+	}
+
+	return record
+}
+
+// localChanges compares the local database with the last synced versions and
+// returns a change for everything that was created, edited or deleted since.
+// With rewrite, unchanged objects are included too, under their existing
+// clocks, so that a new shared folder receives everything.
+func (s *State) localChanges(local map[string]Change, rewrite bool) []Change {
+	now := time.Now()
+	var changes []Change
+
+	// Tables go first so that their entries find them when the log is replayed
+	for _, kind := range []string{TableKind, EntryKind} {
+		for _, uuid := range sortedKeys(local) {
+			current := local[uuid]
+			if current.Kind != kind {
+				continue
+			}
+
+			object := s.object(uuid, kind)
+			hash := current.hash()
+
+			if len(object.Clock) > 0 && !object.Deleted && object.Hash == hash {
+				if rewrite {
+					current.Device, current.Lamport, current.Clock, current.At = object.Device, object.Lamport, object.Clock, now
+					changes = append(changes, current)
+				}
+				continue
+			}
+
+			current.Device = s.DeviceID
+			current.Lamport = s.tick()
+			current.Clock = object.Clock.Tick(s.DeviceID)
+			current.At = now
+			s.Objects[uuid] = &Object{Kind: kind, Clock: current.Clock, Hash: hash, Lamport: current.Lamport, Device: s.DeviceID}
+			changes = append(changes, current)
+		}
+	}
+
+	for _, uuid := range sortedKeys(s.Objects) {
+		object := s.Objects[uuid]
+		if _, exists := local[uuid]; exists {
+			continue
+		}
+
+		deletion := Change{Kind: object.Kind, UUID: uuid, At: now, Deleted: true}
+		if object.Deleted {
+			if rewrite {
+				deletion.Device, deletion.Lamport, deletion.Clock = object.Device, object.Lamport, object.Clock
+				changes = append(changes, deletion)
+			}
+			continue
+		}
+
+		deletion.Device = s.DeviceID
+		deletion.Lamport = s.tick()
+		deletion.Clock = object.Clock.Tick(s.DeviceID)
+		s.Objects[uuid] = &Object{Kind: object.Kind, Clock: deletion.Clock, Hash: deletion.hash(), Deleted: true, Lamport: deletion.Lamport, Device: s.DeviceID}
+		changes = append(changes, deletion)
+	}
+
+	return changes
+}
+
+// applyRemote applies a change from another device's log unless this device
+// already has it. Concurrent edits are resolved the same way on every device
+// and recorded as conflicts.
+func (s *State) applyRemote(root string, change Change, result *Result) error {
+	s.observe(change.Lamport)
+	object := s.object(change.UUID, change.Kind)
+
+	switch change.Clock.Compare(object.Clock) {
+	case Equal, Before:
+		return nil
+
+	case After:
+		if err := apply(root, change); errors.Is(err, errPending) {
+			result.Pending++
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		s.Objects[change.UUID] = objectOf(change, change.Clock)
+		s.dropConflicts(change.UUID)
+		result.Received++
+		return nil
+	}
+
+	merged := object.Clock.Merge(change.Clock)
+	if change.hash() == object.Hash {
+		// Both devices made the same edit
+		object.Clock = merged
+		s.Objects[change.UUID] = object
+		return nil
+	}
+
+	mine := s.localVersion(change.UUID, object)
+	kept, other := mine, change
+	if wins(change, object) {
+		if err := apply(root, change); errors.Is(err, errPending) {
+			result.Pending++
+			return nil
+		} else if err != nil {
+			return err
+		}
+		kept, other = change, mine
+		result.Received++
+	}
+
+	s.Objects[change.UUID] = objectOf(kept, merged)
+	s.dropConflicts(change.UUID)
+
+	// Conflicts between two other devices, e.g. replayed from their logs
+	// when this device joins, are reviewed on those devices
+	if mine.Device != s.DeviceID {
+		return nil
+	}
+
+	s.Conflicts = append(s.Conflicts, Conflict{
+		UUID:       change.UUID,
+		Kind:       change.Kind,
+		Kept:       kept,
+		Other:      other,
+		DetectedAt: time.Now(),
+	})
+	result.Conflicts++
+	return nil
+}
+
+func objectOf(change Change, clock Clock) *Object {
+	return &Object{
+		Kind:    change.Kind,
+		Clock:   clock,
+		Hash:    change.hash(),
+		Deleted: change.Deleted,
+		Lamport: change.Lamport,
+		Device:  change.Device,
+	}
+}
+
+// wins decides which of two concurrent versions is kept: edits beat deletions
+// so that no work is lost, otherwise the later change by Lamport time wins
+func wins(change Change, object *Object) bool {
+	if change.Deleted != object.Deleted {
+		return !change.Deleted
+	}
+	if change.Lamport != object.Lamport {
+		return change.Lamport > object.Lamport
+	}
+	return change.Device > object.Device
+}
+
+// localVersion describes the local copy of an object as a change, for showing
+// it in a conflict
+func (s *State) localVersion(uuid string, object *Object) Change {
+	version := Change{
+		Device:  object.Device,
+		Lamport: object.Lamport,
+		Clock:   object.Clock,
+		Kind:    object.Kind,
+		UUID:    uuid,
+		At:      time.Now(),
+		Deleted: true,
+	}
+
+	switch object.Kind {
+	case TableKind:
+		if table, err := database.FindTableByUUID(uuid); err == nil {
+			version.Deleted = false
+			version.Table = tableRecord(table)
+		}
+	case EntryKind:
+		if entry, err := database.FindEntryByUUID(uuid); err == nil {
+			table, _ := database.GetTable(entry.TableID)
+			attachments, _ := database.GetAttachments(entry.ID)
+			version.Deleted = false
+			version.Entry = entryRecord(table.UUID, entry, attachments)
+		}
+	}
+
+	return version
+}
+
+// apply writes a change to the local database
+func apply(root string, change Change) error {
+	switch change.Kind {
+	case TableKind:
+		table, err := database.FindTableByUUID(change.UUID)
+		if change.Deleted {
+			if err != nil {
+				return nil
+			}
+			return database.DeleteTable(table.ID)
+		}
+		if change.Table == nil {
+			return nil
+		}
+
+		if err != nil {
+			table = models.Table{
+				UUID:      change.UUID,
+				Name:      change.Table.Name,
+				Author:    change.Table.Author,
+				CreatedAt: change.Table.CreatedAt,
+			}
+			return database.CreateTable(&table)
+		}
+
+		if table.Name == change.Table.Name && table.Author == change.Table.Author {
+			return nil
+		}
+		table.Name = change.Table.Name
+		table.Author = change.Table.Author
+		return database.UpdateTable(&table)
+
+	case EntryKind:
+		entry, err := database.FindEntryByUUID(change.UUID)
+		if change.Deleted {
+			if err != nil {
+				return nil
+			}
+			return database.DeleteEntry(entry.ID)
+		}
+		if change.Entry == nil {
+			return nil
+		}
+		return applyEntry(root, change.UUID, *change.Entry)
+	}
+
+	return nil
+}
+
+func applyEntry(root, uuid string, record EntryRecord) error {
+	table, err := database.FindTableByUUID(record.TableUUID)
+	if err != nil {
+		return errPending
+	}
+
+	// Make sure every attachment is available before touching the entry
+	for _, attachment := range record.Attachments {
+		if err := pullBlob(root, attachment.Hash); err != nil {
+			return errPending
+		}
+	}
+
+	entry, err := database.FindEntryByUUID(uuid)
+	if err != nil {
+		entry = models.Entry{
+			UUID:      uuid,
+			TableID:   table.ID,
+			Title:     record.Title,
+			Tags:      record.Tags,
+			Content:   record.Content,
+			Language:  record.Language,
+			CreatedAt: record.CreatedAt,
+			UpdatedAt: record.UpdatedAt,
+		}
+		if err := database.CreateEntry(&entry); err != nil {
+			return err
+		}
+	} else {
+		entry.TableID = table.ID
+		entry.Title = record.Title
+		entry.Tags = record.Tags
+		entry.Content = record.Content
+		entry.Language = record.Language
+		if err := database.UpdateEntry(&entry); err != nil {
+			return err
+		}
+	}
+
+	return applyAttachments(entry.ID, record.Attachments)
+}
+
+// applyAttachments makes the attachments of an entry match records
+func applyAttachments(entryID uint, records []AttachmentRecord) error {
+	key := func(hash, filename string) string { return hash + "\x00" + filename }
+
+	wanted := map[string]bool{}
+	for _, record := range records {
+		wanted[key(record.Hash, record.Filename)] = true
+	}
+
+	existing, err := database.GetAttachments(entryID)
+	if err != nil {
+		return err
+	}
+
+	for _, attachment := range existing {
+		k := key(attachment.Hash, attachment.Filename)
+		if wanted[k] {
+			delete(wanted, k)
+			continue
+		}
+		if err := database.DeleteAttachment(attachment.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, record := range records {
+		if !wanted[key(record.Hash, record.Filename)] {
+			continue
+		}
+		delete(wanted, key(record.Hash, record.Filename))
+
+		attachment := models.Attachment{
+			EntryID:  entryID,
+			Filename: record.Filename,
+			MimeType: record.MimeType,
+			Size:     record.Size,
+			Hash:     record.Hash,
+		}
+		if err := database.CreateAttachment(&attachment); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sharedBlobPath(root, hash string) string {
+	return filepath.Join(root, blobsFolderName, hash[:2], hash[2:])
+}
+
+// pushBlobs copies the attachments of changes to the shared folder
+func pushBlobs(root string, changes []Change) error {
+	for _, change := range changes {
+		if change.Entry == nil {
+			continue
+		}
+
+		for _, attachment := range change.Entry.Attachments {
+			if len(attachment.Hash) < 3 {
+				continue
+			}
+
+			path := sharedBlobPath(root, attachment.Hash)
+			if _, err := os.Stat(path); err == nil {
+				continue
+			}
+
+			content, err := database.ReadBlob(attachment.Hash)
+			if err != nil {
+				return err
+			}
+			if err := writeFileAtomic(path, content); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// pullBlob copies an attachment from the shared folder into the local store
+// unless it is there already
+func pullBlob(root, hash string) error {
+	if len(hash) < 3 {
+		return errors.New("invalid attachment hash")
+	}
+
+	if path, err := database.BlobPath(hash); err == nil {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+	}
+
+	if root == "" {
+		return errPending
+	}
+
+	file, err := os.Open(sharedBlobPath(root, hash))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = database.ReceiveBlob(hash, file)
+	if errors.Is(err, database.ErrBlobMismatch) {
+		// Still being copied by the sync tool
+		return errPending
+	}
+	return err
+}
+
+func writeFileAtomic(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Status summarizes sync for the TUI
+type Status struct {
+	Configured bool
+	// Git is set when the git storage backend is in use, Server when syncing
+	// through a sync server. Folder is then the URL of the remote or server.
+	Git        bool
+	Server     bool
+	Folder     string
+	DeviceID   string
+	DeviceName string
+	LastSync   time.Time
+	Conflicts  []Conflict
+	Devices    []Device
+}
+
+func GetStatus() (Status, error) {
+	var status Status
+
+	syncConfig, err := config.LoadSyncConfig()
+	if err != nil {
+		return status, err
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		return status, err
+	}
+
+	status.Configured = syncConfig.Folder != "" || syncConfig.Server != ""
+	status.Folder = syncConfig.Folder
+	if syncConfig.Server != "" {
+		status.Server = true
+		status.Folder = syncConfig.Server
+		status.Devices = state.Devices
+	}
+	if database.UsingGit() {
+		status.Git = true
+		status.Folder = database.GitRemote()
+		status.Configured = status.Folder != ""
+	}
+	status.DeviceID = state.DeviceID
+	status.DeviceName = deviceName(syncConfig)
+	status.LastSync = state.LastSync
+	status.Conflicts = state.Conflicts
+
+	if root, err := syncRoot(syncConfig); err == nil && !status.Git && !status.Server {
+		for _, device := range readDevices(root) {
+			if device.ID != state.DeviceID {
+				status.Devices = append(status.Devices, device)
+			}
+		}
+	}
+
+	return status, nil
+}
+
+// SetFolder chooses the shared folder. The next sync sends everything to it.
+// An http:// or https:// URL chooses a sync server instead, see SetServer, and
+// with the git storage backend it sets the remote.
+func SetFolder(folder string) error {
+	folder = strings.TrimSpace(folder)
+	if database.UsingGit() {
+		return database.SetGitRemote(folder)
+	}
+	if isServerURL(folder) {
+		return SetServer(folder, "")
+	}
+
+	syncConfig, err := config.LoadSyncConfig()
+	if err != nil {
+		return err
+	}
+
+	if folder != "" {
+		expanded, err := data.ExpandHome(folder)
+		if err != nil {
+			return err
+		}
+		if info, err := os.Stat(expanded); err != nil {
+			return err
+		} else if !info.IsDir() {
+			return errors.New(folder + " is not a folder")
+		}
+	}
+
+	syncConfig.Folder = folder
+	syncConfig.Server = ""
+	syncConfig.Token = ""
+	return config.SaveSyncConfig(syncConfig)
+}
+
+// Interval is how long the app waits between syncs
+func Interval() time.Duration {
+	syncConfig, err := config.LoadSyncConfig()
+	if err != nil {
+		return config.DefaultSyncInterval * time.Minute
+	}
+	return time.Duration(syncConfig.IntervalMinutes) * time.Minute
+}
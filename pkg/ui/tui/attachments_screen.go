@@ -0,0 +1,244 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/s42yt/thighpads/pkg/data"
+	"github.com/s42yt/thighpads/pkg/database"
+)
+
+// What the path input on the attachments screen is used for
+const (
+	attachmentBrowse = iota
+	attachmentAddPath
+	attachmentSaveAs
+)
+
+func (a *App) loadAttachments() {
+	attachments, err := database.GetAttachments(a.currentEntry.ID)
+	if err != nil {
+		a.errorMsg = err.Error()
+		return
+	}
+	a.currentEntry.Attachments = attachments
+	if a.attachmentIndex >= len(attachments) {
+		a.attachmentIndex = len(attachments) - 1
+	}
+	if a.attachmentIndex < 0 {
+		a.attachmentIndex = 0
+	}
+}
+
+func (a *App) openAttachmentsScreen() {
+	a.navigate(AttachmentsScreen)
+	a.attachmentIndex = 0
+	a.attachmentMode = attachmentBrowse
+	a.loadAttachments()
+}
+
+func (a *App) updateAttachmentsScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	if a.attachmentMode != attachmentBrowse {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch {
+			case typed(msg):
+				// Typed into the focused field
+			case key.Matches(msg, Keys.Confirm):
+				a.submitAttachmentPath()
+				return a, nil
+			case key.Matches(msg, Keys.Cancel):
+				a.attachmentMode = attachmentBrowse
+				return a, nil
+			case key.Matches(msg, Keys.ForceQuit):
+				return a, tea.Quit
+			}
+		}
+
+		a.attachmentInput, cmd = a.attachmentInput.Update(msg)
+		return a, cmd
+	}
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		attachments := a.currentEntry.Attachments
+
+		switch {
+		case key.Matches(msg, Keys.Up):
+			if a.attachmentIndex > 0 {
+				a.attachmentIndex--
+			}
+		case key.Matches(msg, Keys.Down):
+			if a.attachmentIndex < len(attachments)-1 {
+				a.attachmentIndex++
+			}
+		case key.Matches(msg, Keys.Attach):
+			a.attachmentMode = attachmentAddPath
+			a.attachmentInput = TextInputField("Path to the file to attach")
+		case key.Matches(msg, Keys.Paste):
+			a.attachFromClipboard()
+		case key.Matches(msg, Keys.Open, Keys.Select):
+			if len(attachments) > 0 {
+				if err := data.OpenAttachment(attachments[a.attachmentIndex]); err != nil {
+					a.errorMsg = err.Error()
+				}
+			}
+		case key.Matches(msg, Keys.SaveCopy):
+			if len(attachments) > 0 {
+				a.attachmentMode = attachmentSaveAs
+				a.attachmentInput = TextInputField("File or folder to save to")
+				if cwd, err := os.Getwd(); err == nil {
+					a.attachmentInput.SetValue(cwd + string(os.PathSeparator) + attachments[a.attachmentIndex].Filename)
+				}
+			}
+		case key.Matches(msg, Keys.Delete):
+			if len(attachments) == 0 {
+				break
+			}
+			if a.errorMsg != "confirm_delete" {
+				a.errorMsg = "confirm_delete"
+				a.successMsg = fmt.Sprintf("Press '%s' again to confirm deletion", KeyName(Keys.Delete))
+				return a, nil
+			}
+			a.errorMsg = ""
+			if err := database.DeleteAttachment(attachments[a.attachmentIndex].ID); err != nil {
+				a.errorMsg = err.Error()
+				return a, nil
+			}
+			a.loadAttachments()
+			a.successMsg = "Attachment deleted."
+		case key.Matches(msg, Keys.Back):
+			a.goBack()
+		case key.Matches(msg, Keys.Forward):
+			a.goForward()
+		case key.Matches(msg, Keys.Quit):
+			return a, tea.Quit
+		}
+	}
+
+	return a, nil
+}
+
+func (a *App) submitAttachmentPath() {
+	path := a.attachmentInput.Value()
+	if strings.TrimSpace(path) == "" {
+		return
+	}
+
+	switch a.attachmentMode {
+	case attachmentAddPath:
+		attachment, err := data.AttachFile(a.currentEntry.ID, path)
+		if err != nil {
+			a.errorMsg = err.Error()
+			return
+		}
+		a.loadAttachments()
+		a.attachmentIndex = len(a.currentEntry.Attachments) - 1
+		a.successMsg = "Attached " + attachment.Filename
+	case attachmentSaveAs:
+		saved, err := data.SaveAttachmentAs(a.currentEntry.Attachments[a.attachmentIndex], path)
+		if err != nil {
+			a.errorMsg = err.Error()
+			return
+		}
+		a.successMsg = "Saved to " + saved
+	}
+
+	a.attachmentMode = attachmentBrowse
+}
+
+// attachFromClipboard attaches the file whose path is on the clipboard, or
+// else the clipboard text itself, e.g. a copied log excerpt
+func (a *App) attachFromClipboard() {
+	text, err := clipboard.ReadAll()
+	if err != nil {
+		a.errorMsg = "Failed to read clipboard: " + err.Error()
+		return
+	}
+	if text == "" {
+		a.errorMsg = "The clipboard is empty"
+		return
+	}
+
+	path, _ := data.ExpandHome(strings.TrimSpace(text))
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		_, err = data.AttachFile(a.currentEntry.ID, path)
+	} else {
+		name := "clipboard-" + time.Now().Format("2006-01-02-150405") + ".txt"
+		_, err = data.AttachData(a.currentEntry.ID, name, []byte(text))
+	}
+	if err != nil {
+		a.errorMsg = err.Error()
+		return
+	}
+
+	a.loadAttachments()
+	a.attachmentIndex = len(a.currentEntry.Attachments) - 1
+	a.successMsg = "Attached clipboard contents."
+}
+
+func (a *App) viewAttachmentsScreen() string {
+	title := Title.Copy().Width(a.width - 4).Render(a.currentEntry.Title)
+	subtitle := Subtitle.Copy().Width(a.width - 4).Render("Attachments")
+
+	attachments := a.currentEntry.Attachments
+
+	var lines []string
+	if len(attachments) == 0 {
+		lines = append(lines, Normal.Render("No attachments yet. Press 'a' to attach a file or 'p' to paste from the clipboard."))
+	}
+	for i, attachment := range attachments {
+		name := truncateString(attachment.Filename, a.width-40)
+		info := fmt.Sprintf("%s  %s  %s", attachment.MimeType, data.FormatSize(attachment.Size), attachment.CreatedAt.Format("Jan 02, 2006"))
+		if i == a.attachmentIndex {
+			lines = append(lines, Selected.Render(name)+"  "+Subtle.Render(info))
+		} else {
+			lines = append(lines, Unselected.Render(name)+"  "+Subtle.Render(info))
+		}
+	}
+
+	if a.errorMsg == "confirm_delete" {
+		lines = append([]string{Warning.Render(fmt.Sprintf("Press '%s' again to confirm deletion", KeyName(Keys.Delete))), ""}, lines...)
+	}
+
+	switch a.attachmentMode {
+	case attachmentAddPath:
+		lines = append(lines, "", Normal.Render("Attach file:"), a.attachmentInput.View())
+	case attachmentSaveAs:
+		lines = append(lines, "", Normal.Render("Save as:"), a.attachmentInput.View())
+	}
+
+	content := BoxStyle.Copy().Width(a.width - 4).Render(strings.Join(lines, "\n"))
+
+	help := HelpView(
+		Help("Navigate", Keys.Up, Keys.Down),
+		Help("Open", Keys.Select),
+		Help("Save as", Keys.SaveCopy),
+		Help("Attach", Keys.Attach),
+		Help("Paste", Keys.Paste),
+		Help("Delete", Keys.Delete),
+		Help("Back", Keys.Back),
+		a.forwardHelp(),
+	)
+	if a.attachmentMode != attachmentBrowse {
+		help = HelpView(
+			Help("Confirm", Keys.Confirm),
+			Help("Cancel", Keys.Cancel),
+		)
+	}
+
+	return fmt.Sprintf(
+		"%s\n%s\n\n%s\n\n%s",
+		title,
+		subtitle,
+		content,
+		help,
+	)
+}
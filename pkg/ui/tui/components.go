@@ -5,6 +5,7 @@ import (
 	"io"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -15,6 +16,7 @@ type Selectable struct {
 	Title       string
 	Description string
 	ID          uint
+	Marked      bool
 }
 
 func (i Selectable) FilterValue() string { return i.Title }
@@ -38,16 +40,21 @@ func (d ItemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 		width = 10
 	}
 
+	name := i.Title
+	if i.Marked {
+		name = "✓ " + name
+	}
+
 	var title, desc string
 	if index == m.Index() {
-		title = Selected.Copy().Width(width).Render(truncateString(i.Title, width-4))
+		title = Selected.Copy().Width(width).Render(truncateString(name, width-4))
 		desc = Selected.Copy().Width(width).Render(truncateString(i.Description, width-4))
 	} else {
-		title = Unselected.Copy().Width(width).Render(truncateString(i.Title, width-4))
+		title = Unselected.Copy().Width(width).Render(truncateString(name, width-4))
 		desc = Subtle.Copy().Width(width).Render(truncateString(i.Description, width-4))
 	}
 
-	fmt.Fprintf(w, "%s\n%s", title, desc)
+	fmt.Fprint(w, markZone(fmt.Sprintf("item:%d", index), title+"\n"+desc))
 }
 
 func truncateString(s string, max int) string {
@@ -80,6 +87,7 @@ func SelectableList(title string, items []list.Item, width, height int) list.Mod
 	l.Styles.FilterPrompt = Subtitle
 	l.Styles.FilterCursor = Subtitle
 
+	l.KeyMap = Keys.ListKeyMap()
 	l.SetShowHelp(false)
 	l.SetShowPagination(true)
 	l.Styles.PaginationStyle = Subtle
@@ -89,14 +97,42 @@ func SelectableList(title string, items []list.Item, width, height int) list.Mod
 	return l
 }
 
-func HelpView(keys map[string]string) string {
+// HelpKey is one entry of a help bar
+type HelpKey struct {
+	Keys        string
+	Description string
+	// Key is pressed when the entry is clicked
+	Key string
+}
+
+// Help describes what bindings do, showing the first key of each, e.g. "↑/↓"
+// for Keys.Up and Keys.Down. Bindings that are turned off are left out.
+func Help(description string, bindings ...key.Binding) HelpKey {
+	var keys []string
+	help := HelpKey{Description: description}
+	for _, binding := range bindings {
+		if binding.Enabled() {
+			keys = append(keys, binding.Help().Key)
+			if help.Key == "" {
+				help.Key = binding.Keys()[0]
+			}
+		}
+	}
+	help.Keys = strings.Join(keys, "/")
+	return help
+}
+
+func HelpView(keys ...HelpKey) string {
 	var helpEntries []string
 
-	for key, description := range keys {
+	for _, help := range keys {
+		if help.Keys == "" {
+			continue
+		}
 		entry := fmt.Sprintf("%s: %s",
-			Subtle.Render(key),
-			Normal.Render(description))
-		helpEntries = append(helpEntries, entry)
+			Subtle.Render(help.Keys),
+			Normal.Render(help.Description))
+		helpEntries = append(helpEntries, markZone("key:"+help.Key, entry))
 	}
 
 	helpText := strings.Join(helpEntries, " • ")
@@ -117,3 +153,15 @@ func CenterView(content string, width int) string {
 	}
 	return lipgloss.Place(width, 1, lipgloss.Center, lipgloss.Center, content)
 }
+
+// contentHeight is how many lines the main box of a screen can show when parts
+// are drawn above and below it. Besides the parts, the box's border and
+// padding, a blank line after each part, the breadcrumb, a status message and
+// the app's padding take up room.
+func (a *App) contentHeight(parts ...string) int {
+	used := 4 + len(parts) + 1 + 1 + 2
+	for _, part := range parts {
+		used += lipgloss.Height(part)
+	}
+	return max(a.height-used, 3)
+}
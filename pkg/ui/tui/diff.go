@@ -0,0 +1,135 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+type diffLine struct {
+	op   diffOp
+	text string
+}
+
+// maxDiffCells bounds the LCS table; larger inputs fall back to showing the
+// whole of both sides as changed.
+const maxDiffCells = 4_000_000
+
+// diffLines computes a line based diff from a to b using the longest common
+// subsequence, which is plenty for entry sized texts.
+func diffLines(a, b []string) []diffLine {
+	if len(a)*len(b) > maxDiffCells {
+		var result []diffLine
+		for _, line := range a {
+			result = append(result, diffLine{diffDelete, line})
+		}
+		for _, line := range b {
+			result = append(result, diffLine{diffInsert, line})
+		}
+		return result
+	}
+
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var result []diffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, diffLine{diffEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, diffLine{diffDelete, a[i]})
+			i++
+		default:
+			result = append(result, diffLine{diffInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		result = append(result, diffLine{diffDelete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		result = append(result, diffLine{diffInsert, b[j]})
+	}
+
+	return result
+}
+
+// SideBySideDiff renders mine and theirs in two columns of the given width.
+// Removed lines are shown on the left in the error color, added lines on the
+// right in the success color, and changed lines are paired up on one row.
+func SideBySideDiff(mine, theirs string, width int) string {
+	return LabeledDiff(mine, theirs, "Mine", "Theirs", width)
+}
+
+// LabeledDiff is SideBySideDiff with custom column headers
+func LabeledDiff(mine, theirs, mineLabel, theirsLabel string, width int) string {
+	column := (width - 3) / 2
+	if column < 10 {
+		column = 10
+	}
+
+	cell := lipgloss.NewStyle().Width(column).MaxWidth(column)
+	render := func(style lipgloss.Style, text string) string {
+		return cell.Inherit(style).Render(strings.ReplaceAll(text, "\t", "    "))
+	}
+	blank := cell.Render("")
+	separator := Subtle.Render(" │ ")
+
+	ops := diffLines(strings.Split(mine, "\n"), strings.Split(theirs, "\n"))
+
+	var rows []string
+	for k := 0; k < len(ops); {
+		if ops[k].op == diffEqual {
+			rows = append(rows, render(Normal, ops[k].text)+separator+render(Normal, ops[k].text))
+			k++
+			continue
+		}
+
+		var deleted, inserted []string
+		for k < len(ops) && ops[k].op != diffEqual {
+			if ops[k].op == diffDelete {
+				deleted = append(deleted, ops[k].text)
+			} else {
+				inserted = append(inserted, ops[k].text)
+			}
+			k++
+		}
+
+		for n := 0; n < max(len(deleted), len(inserted)); n++ {
+			left, right := blank, blank
+			if n < len(deleted) {
+				left = render(Error, deleted[n])
+			}
+			if n < len(inserted) {
+				right = render(Success, inserted[n])
+			}
+			rows = append(rows, left+separator+right)
+		}
+	}
+
+	header := cell.Inherit(Subtitle).Render(mineLabel) + separator + cell.Inherit(Subtitle).Render(theirsLabel)
+	return header + "\n" + strings.Join(rows, "\n")
+}
@@ -0,0 +1,132 @@
+package tui
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/s42yt/thighpads/pkg/config"
+)
+
+// draftInterval is how often the entry forms save unsaved changes as a draft
+const draftInterval = 5 * time.Second
+
+// entryDraft is what an entry form held when it was last saved as a draft.
+// Drafts live in the drafts folder until the form is saved or its changes
+// discarded, so that changes survive a crash or a killed terminal.
+//
+// Tables and entries are known by their UUIDs, as the git backend numbers
+// them anew on every start.
+type entryDraft struct {
+	TableUUID string `json:"tableUuid"`
+	// EntryUUID is the entry being edited, empty for a new entry
+	EntryUUID string    `json:"entryUuid,omitempty"`
+	Title     string    `json:"title"`
+	Tags      string    `json:"tags"`
+	Content   string    `json:"content"`
+	Language  string    `json:"language,omitempty"`
+	SavedAt   time.Time `json:"savedAt"`
+
+	// path is the file the draft was read from
+	path string
+}
+
+// draftTickMsg asks to save a draft of the open entry form
+type draftTickMsg struct{}
+
+func draftTick() tea.Cmd {
+	return tea.Tick(draftInterval, func(time.Time) tea.Msg { return draftTickMsg{} })
+}
+
+func draftPath(tableUUID, entryUUID string) (string, error) {
+	draftsPath, err := config.GetDraftsPath()
+	if err != nil {
+		return "", err
+	}
+	if entryUUID != "" {
+		return filepath.Join(draftsPath, "entry-"+entryUUID+".json"), nil
+	}
+	if tableUUID == "" {
+		return "", errors.New("the table has no UUID")
+	}
+	return filepath.Join(draftsPath, "new-"+tableUUID+".json"), nil
+}
+
+func loadDraft(tableUUID, entryUUID string) (entryDraft, bool) {
+	path, err := draftPath(tableUUID, entryUUID)
+	if err != nil {
+		return entryDraft{}, false
+	}
+	return readDraft(path)
+}
+
+func readDraft(path string) (entryDraft, bool) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return entryDraft{}, false
+	}
+	draft := entryDraft{path: path}
+	if err := json.Unmarshal(content, &draft); err != nil {
+		return entryDraft{path: path}, false
+	}
+	return draft, true
+}
+
+// listDrafts returns every saved draft, the newest first
+func listDrafts() []entryDraft {
+	draftsPath, err := config.GetDraftsPath()
+	if err != nil {
+		return nil
+	}
+	files, err := os.ReadDir(draftsPath)
+	if err != nil {
+		return nil
+	}
+
+	var drafts []entryDraft
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		// Drafts that cannot be read, or were saved by IDs before drafts
+		// had UUIDs, are still listed so that they get removed
+		draft, _ := readDraft(filepath.Join(draftsPath, file.Name()))
+		drafts = append(drafts, draft)
+	}
+	sort.Slice(drafts, func(i, j int) bool { return drafts[i].SavedAt.After(drafts[j].SavedAt) })
+	return drafts
+}
+
+// writeDraft saves draft, replacing the previous draft of the same form. The
+// file is written next to its final name first so that a crash while writing
+// cannot leave half a draft behind.
+func writeDraft(draft entryDraft) error {
+	path, err := draftPath(draft.TableUUID, draft.EntryUUID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(draft, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func removeDraft(tableUUID, entryUUID string) {
+	if path, err := draftPath(tableUUID, entryUUID); err == nil {
+		os.Remove(path)
+	}
+}
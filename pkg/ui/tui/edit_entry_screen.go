@@ -3,7 +3,9 @@ package tui
 import (
 	"fmt"
 
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/s42yt/thighpads/pkg/data"
 	"github.com/s42yt/thighpads/pkg/database"
 )
 
@@ -12,102 +14,100 @@ func (a *App) updateEditEntryScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyTab:
-			if a.entryTitleInput.Focused() {
-				a.entryTitleInput.Blur()
-				a.entryTagsInput.Focus()
-				a.entryContent.Blur()
-			} else if a.entryTagsInput.Focused() {
-				a.entryTitleInput.Blur()
-				a.entryTagsInput.Blur()
-				a.entryContent.Focus()
-			} else {
-				a.entryTitleInput.Focus()
-				a.entryTagsInput.Blur()
-				a.entryContent.Blur()
-			}
+		if a.pendingLeave != nil {
+			return a.updateLeavePrompt(msg)
+		}
+		if a.entryContent.finding() && !key.Matches(msg, Keys.Save, Keys.ForceQuit) {
+			a.entryContent, cmd = a.entryContent.Update(msg)
+			return a, cmd
+		}
+
+		switch {
+		case typed(msg):
+			// Typed into the focused field
+		case key.Matches(msg, Keys.NextField):
+			a.nextEntryField()
 			return a, nil
-		case tea.KeyCtrlS:
-			if a.entryTitleInput.Value() != "" {
-				updatedEntry := a.currentEntry
-				updatedEntry.Title = a.entryTitleInput.Value()
-				updatedEntry.Tags = a.entryTagsInput.Value()
-				updatedEntry.Content = a.entryContent.Value()
-
-				err := database.UpdateEntry(&updatedEntry)
-				if err != nil {
-					a.errorMsg = err.Error()
-					return a, nil
-				}
-
-				a.currentEntry = updatedEntry
-				a.screen = TableScreen
-				a.loadEntries()
-				a.successMsg = "Entry updated successfully."
-				return a, nil
-			} else {
-				a.errorMsg = "Title cannot be empty"
-				return a, nil
+		case key.Matches(msg, Keys.Save):
+			if a.saveEditedEntry() {
+				return a, a.closeEntryForm()
 			}
-		case tea.KeyEsc:
-			a.screen = ViewEntryScreen
-
-			a.entryViewport.SetContent(a.currentEntry.Content)
-			a.entryViewport.GotoTop()
 			return a, nil
-		case tea.KeyCtrlC:
-			return a, tea.Quit
+		case key.Matches(msg, Keys.Cancel):
+			return a, a.leaveEntryForm((*App).closeEntryForm)
+		case key.Matches(msg, Keys.ForceQuit):
+			return a, a.leaveEntryForm(func(*App) tea.Cmd { return tea.Quit })
 		}
 	}
 
-	if a.entryTitleInput.Focused() {
-		a.entryTitleInput, cmd = a.entryTitleInput.Update(msg)
-	} else if a.entryTagsInput.Focused() {
-		a.entryTagsInput, cmd = a.entryTagsInput.Update(msg)
-	} else {
-		a.entryContent, cmd = a.entryContent.Update(msg)
+	return a, a.updateEntryField(msg)
+}
+
+// saveEditedEntry saves the changes of the form, reporting whether that worked
+func (a *App) saveEditedEntry() bool {
+	if a.entryTitleInput.Value() == "" {
+		a.errorMsg = "Title cannot be empty"
+		return false
+	}
+
+	updatedEntry := a.currentEntry
+	updatedEntry.Title = a.entryTitleInput.Value()
+	updatedEntry.Tags = a.entryTagsInput.Value()
+	updatedEntry.Content = a.entryContent.Value()
+	updatedEntry.Language = data.NormalizeLanguage(a.entryLangInput.Value())
+
+	err := database.UpdateEntry(&updatedEntry)
+	if err != nil {
+		a.errorMsg = err.Error()
+		return false
 	}
 
-	return a, cmd
+	a.discardDraft()
+	a.currentEntry = updatedEntry
+	a.successMsg = "Entry updated successfully."
+	return true
 }
 
 func (a *App) viewEditEntryScreen() string {
 	title := Title.Copy().Width(a.width - 4).Render("Edit Entry")
-	subtitle := Subtitle.Copy().Width(a.width - 4).Render(a.currentTable.Name)
+	subtitle := a.currentTable.Name
+	if status := a.entryFormStatus(); status != "" {
+		subtitle = Subtitle.Render(subtitle) + "  " + status
+	} else {
+		subtitle = Subtitle.Render(subtitle)
+	}
 
 	availWidth := a.width - 6
 
-	titleInput := Subtitle.Render("Title:") + "\n" + a.entryTitleInput.View()
-	tagsInput := Subtitle.Render("Tags:") + "\n" + a.entryTagsInput.View()
+	titleInput := markZone("field:title", Subtitle.Render("Title:")+"\n"+a.entryTitleInput.View())
+	tagsInput := markZone("field:tags", Subtitle.Render("Tags:")+"\n"+a.entryTagsInput.View())
+	languageInput := a.entryLanguageField()
 
 	focusIndicator := ""
 	if a.entryTitleInput.Focused() {
 		focusIndicator = Subtitle.Foreground(accentColor).Render("Editing title...")
 	} else if a.entryTagsInput.Focused() {
 		focusIndicator = Subtitle.Foreground(accentColor).Render("Editing tags...")
+	} else if a.entryLangInput.Focused() {
+		focusIndicator = Subtitle.Foreground(accentColor).Render("Editing language...")
 	} else if a.entryContent.Focused() {
 		focusIndicator = Subtitle.Foreground(accentColor).Render("Editing content... (Use arrow keys to navigate)")
 	}
 
-	content := Subtitle.Render("Content:") + "\n" + a.entryContent.View()
+	help := a.entryFormHelp("Save changes")
+	a.sizeEntryEditor(title+"\n"+subtitle, help, 1)
+	content := markZone("field:content", Subtitle.Render("Content:")+"\n"+markZone("editor", a.entryContent.View()))
 
 	form := BoxStyle.Copy().Width(availWidth).Render(
-		fmt.Sprintf("%s\n\n%s\n\n%s\n%s",
+		fmt.Sprintf("%s\n\n%s\n\n%s\n\n%s\n%s",
 			titleInput,
 			tagsInput,
+			languageInput,
 			content,
 			focusIndicator,
 		),
 	)
 
-	help := HelpView(map[string]string{
-		"Tab":    "Next field",
-		"Ctrl+S": "Save changes",
-		"Esc":    "Cancel",
-		"Ctrl+C": "Quit",
-	})
-
 	return fmt.Sprintf(
 		"%s\n%s\n\n%s\n\n%s",
 		title,
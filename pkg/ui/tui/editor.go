@@ -0,0 +1,942 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/atotto/clipboard"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// maxUndo is how many edits the editor can undo
+const maxUndo = 500
+
+// editKind decides which edits are undone together: typing a word and
+// deleting a run of characters are one step each
+type editKind int
+
+const (
+	editNone editKind = iota
+	editTyping
+	editDeleting
+	editOther
+)
+
+// editorKeys move the cursor and delete text. Like the keys of text fields
+// they are fixed; the other actions of the editor are part of the key map.
+var editorKeys = struct {
+	Left, Right, Up, Down, WordLeft, WordRight  key.Binding
+	LineStart, LineEnd, PageUp, PageDown        key.Binding
+	Start, End                                  key.Binding
+	Backspace, Delete, DeleteWord               key.Binding
+	DeleteToLineEnd, DeleteToLineStart, Newline key.Binding
+	Paste                                       key.Binding
+}{
+	Left:              key.NewBinding(key.WithKeys("left")),
+	Right:             key.NewBinding(key.WithKeys("right")),
+	Up:                key.NewBinding(key.WithKeys("up")),
+	Down:              key.NewBinding(key.WithKeys("down")),
+	WordLeft:          key.NewBinding(key.WithKeys("alt+left", "ctrl+left", "alt+b")),
+	WordRight:         key.NewBinding(key.WithKeys("alt+right", "ctrl+right", "alt+f")),
+	LineStart:         key.NewBinding(key.WithKeys("home", "ctrl+a")),
+	LineEnd:           key.NewBinding(key.WithKeys("end", "ctrl+e")),
+	PageUp:            key.NewBinding(key.WithKeys("pgup")),
+	PageDown:          key.NewBinding(key.WithKeys("pgdown")),
+	Start:             key.NewBinding(key.WithKeys("ctrl+home")),
+	End:               key.NewBinding(key.WithKeys("ctrl+end")),
+	Backspace:         key.NewBinding(key.WithKeys("backspace", "ctrl+h")),
+	Delete:            key.NewBinding(key.WithKeys("delete", "ctrl+d")),
+	DeleteWord:        key.NewBinding(key.WithKeys("alt+backspace", "ctrl+w")),
+	DeleteToLineEnd:   key.NewBinding(key.WithKeys("ctrl+k")),
+	DeleteToLineStart: key.NewBinding(key.WithKeys("ctrl+u")),
+	Newline:           key.NewBinding(key.WithKeys("enter")),
+	Paste:             key.NewBinding(key.WithKeys("ctrl+v")),
+}
+
+// listItem matches the start of a Markdown list item: its indentation, the
+// bullet or number, and a checkbox
+var listItem = regexp.MustCompile(`^(\s*)(?:([-*+])|(\d+)([.)]))(\s+)(\[[ xX]\]\s+)?`)
+
+var cursorStyle = lipgloss.NewStyle().Reverse(true)
+
+// editor is the text editor of the entry forms. It numbers its lines, keeps
+// an undo history, finds and replaces text and wraps long lines or scrolls
+// sideways. Enter keeps the indentation of the line and continues Markdown
+// lists.
+type editor struct {
+	Placeholder string
+
+	lines    [][]rune
+	row, col int
+	// goal is the column moving up and down tries to keep, -1 for the
+	// column of the cursor
+	goal int
+
+	// height includes the status line and the find bar
+	width, height int
+	// top is the first row shown, left the first column while lines are not
+	// wrapped
+	top, left int
+	softWrap  bool
+	focused   bool
+
+	undo, redo []editorState
+	lastEdit   editKind
+
+	find editorFind
+}
+
+// editorState is what undo and redo go back to
+type editorState struct {
+	text     string
+	row, col int
+}
+
+// editorFind is the find and replace bar of the editor
+type editorFind struct {
+	open bool
+	// replacing is set while the replacement is typed rather than the query
+	replacing          bool
+	query, replacement textinput.Model
+	matchCase, regex   bool
+	re                 *regexp.Regexp
+	matches            []editorMatch
+	current            int
+	err                string
+	// replaced is how many matches the last "replace all" replaced
+	replaced int
+}
+
+// editorMatch is a match of the find bar, by line and runes. groups are the
+// byte offsets of the match and its submatches in the line, to expand $1 and
+// the like in the replacement.
+type editorMatch struct {
+	row, start, end int
+	groups          []int
+}
+
+// visualRow is a row of the editor as drawn: a whole line, or the part of it
+// that fits the editor when long lines are wrapped
+type visualRow struct {
+	line, start, end int
+}
+
+func newEditor(placeholder string) editor {
+	query := textinput.New()
+	query.Prompt = ""
+	query.Placeholder = "Find"
+	replacement := textinput.New()
+	replacement.Prompt = ""
+	replacement.Placeholder = "Replace with"
+
+	return editor{
+		Placeholder: placeholder,
+		lines:       [][]rune{{}},
+		goal:        -1,
+		softWrap:    true,
+		find:        editorFind{query: query, replacement: replacement},
+	}
+}
+
+func (e *editor) Value() string {
+	lines := make([]string, len(e.lines))
+	for i, line := range e.lines {
+		lines[i] = string(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SetValue replaces the text, forgetting the undo history, and moves the
+// cursor to its end
+func (e *editor) SetValue(s string) {
+	e.setText(s)
+	e.row = len(e.lines) - 1
+	e.col = len(e.lines[e.row])
+	e.undo, e.redo = nil, nil
+	e.lastEdit = editNone
+	e.refreshFind()
+	e.scroll()
+}
+
+func (e *editor) setText(s string) {
+	parts := strings.Split(normalizeNewlines(s), "\n")
+	e.lines = make([][]rune, len(parts))
+	for i, part := range parts {
+		e.lines[i] = []rune(part)
+	}
+}
+
+func normalizeNewlines(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\r", "\n")
+}
+
+func (e *editor) Focus() {
+	e.focused = true
+}
+
+func (e *editor) Blur() {
+	e.focused = false
+}
+
+func (e *editor) Focused() bool {
+	return e.focused
+}
+
+// finding reports whether the find bar is open and gets the keys
+func (e *editor) finding() bool {
+	return e.focused && e.find.open
+}
+
+func (e *editor) SetWidth(width int) {
+	e.width = max(width, 20)
+	e.scroll()
+}
+
+// SetHeight sets the lines the editor takes, including its status line and
+// the find bar
+func (e *editor) SetHeight(height int) {
+	e.height = height
+	e.scroll()
+}
+
+// textHeight is how many rows of text are shown
+func (e *editor) textHeight() int {
+	chrome := 1
+	if e.find.open {
+		chrome += 2
+	}
+	return max(e.height-chrome, 1)
+}
+
+// digits is the width of the line numbers
+func (e *editor) digits() int {
+	return max(len(strconv.Itoa(len(e.lines))), 3)
+}
+
+func (e *editor) textWidth() int {
+	return max(e.width-e.digits()-1, 1)
+}
+
+// rows splits the lines into the rows they are drawn as
+func (e *editor) rows() []visualRow {
+	var rows []visualRow
+	for i, line := range e.lines {
+		if !e.softWrap {
+			rows = append(rows, visualRow{line: i, start: 0, end: len(line)})
+			continue
+		}
+		// One column is kept free for the cursor at the end of a row
+		starts := wrapRunes(line, e.textWidth()-1)
+		for j, start := range starts {
+			end := len(line)
+			if j+1 < len(starts) {
+				end = starts[j+1]
+			}
+			rows = append(rows, visualRow{line: i, start: start, end: end})
+		}
+	}
+	return rows
+}
+
+// wrapRunes is wrapLine for runes, returning the rune each row starts at
+func wrapRunes(line []rune, width int) []int {
+	s := string(line)
+	starts := wrapLine(s, width)
+	for i, offset := range starts {
+		starts[i] = utf8.RuneCountInString(s[:offset])
+	}
+	return starts
+}
+
+// cursorRow is the index of the row the cursor is on
+func (e *editor) cursorRow(rows []visualRow) int {
+	for i, r := range rows {
+		lastOfLine := i+1 == len(rows) || rows[i+1].line != r.line
+		if r.line == e.row && e.col >= r.start && (e.col < r.end || lastOfLine) {
+			return i
+		}
+	}
+	return 0
+}
+
+// scroll keeps the cursor in view
+func (e *editor) scroll() {
+	rows := e.rows()
+	i := e.cursorRow(rows)
+	height := e.textHeight()
+	if i < e.top {
+		e.top = i
+	} else if i >= e.top+height {
+		e.top = i - height + 1
+	}
+	e.top = max(min(e.top, len(rows)-height), 0)
+
+	if e.softWrap {
+		e.left = 0
+		return
+	}
+	width := e.textWidth() - 1
+	if e.col < e.left {
+		e.left = e.col
+	} else if e.col >= e.left+width {
+		e.left = e.col - width + 1
+	}
+}
+
+// record saves the text for undo before an edit, unless the edit continues
+// the previous one
+func (e *editor) record(kind editKind) {
+	if kind != editOther && kind == e.lastEdit {
+		return
+	}
+	e.undo = append(e.undo, e.state())
+	if len(e.undo) > maxUndo {
+		e.undo = e.undo[1:]
+	}
+	e.redo = nil
+	e.lastEdit = kind
+}
+
+func (e *editor) state() editorState {
+	return editorState{text: e.Value(), row: e.row, col: e.col}
+}
+
+func (e *editor) restore(state editorState) {
+	e.setText(state.text)
+	e.row, e.col = state.row, state.col
+	e.lastEdit = editNone
+	e.refreshFind()
+}
+
+func (e *editor) undoEdit() {
+	if len(e.undo) == 0 {
+		return
+	}
+	e.redo = append(e.redo, e.state())
+	state := e.undo[len(e.undo)-1]
+	e.undo = e.undo[:len(e.undo)-1]
+	e.restore(state)
+}
+
+func (e *editor) redoEdit() {
+	if len(e.redo) == 0 {
+		return
+	}
+	e.undo = append(e.undo, e.state())
+	state := e.redo[len(e.redo)-1]
+	e.redo = e.redo[:len(e.redo)-1]
+	e.restore(state)
+}
+
+// insert puts s, which may span several lines, at the cursor and moves the
+// cursor behind it
+func (e *editor) insert(s string) {
+	line := e.lines[e.row]
+	after := append([]rune{}, line[e.col:]...)
+
+	parts := strings.Split(s, "\n")
+	inserted := make([][]rune, len(parts))
+	for i, part := range parts {
+		inserted[i] = []rune(part)
+	}
+	inserted[0] = append(append([]rune{}, line[:e.col]...), inserted[0]...)
+	last := len(inserted) - 1
+	e.col = len(inserted[last])
+	inserted[last] = append(inserted[last], after...)
+
+	e.lines = append(e.lines[:e.row], append(inserted, e.lines[e.row+1:]...)...)
+	e.row += last
+}
+
+// deleteRange removes the runes from, to of the current line
+func (e *editor) deleteRange(from, to int) {
+	line := e.lines[e.row]
+	e.lines[e.row] = append(append([]rune{}, line[:from]...), line[to:]...)
+}
+
+// joinLine appends the next line to line row
+func (e *editor) joinLine(row int) {
+	e.lines[row] = append(append([]rune{}, e.lines[row]...), e.lines[row+1]...)
+	e.lines = append(e.lines[:row+1], e.lines[row+2:]...)
+}
+
+// newline breaks the line at the cursor. The new line keeps the indentation
+// of the old one and continues a Markdown list, counting numbered items up
+// and adding an empty checkbox after a checkbox. Enter on an item without
+// text ends the list instead.
+func (e *editor) newline() {
+	e.record(editOther)
+	line := string(e.lines[e.row][:e.col])
+
+	m := listItem.FindStringSubmatch(line)
+	if m == nil {
+		indent := line[:len(line)-len(strings.TrimLeftFunc(line, unicode.IsSpace))]
+		e.insert("\n" + indent)
+		return
+	}
+
+	if strings.TrimSpace(line[len(m[0]):]) == "" && e.col == len(e.lines[e.row]) {
+		e.lines[e.row] = nil
+		e.col = 0
+		return
+	}
+
+	marker := m[2]
+	if m[3] != "" {
+		n, _ := strconv.Atoi(m[3])
+		marker = strconv.Itoa(n+1) + m[4]
+	}
+	if m[6] != "" {
+		marker += m[5] + "[ ]"
+	}
+	e.insert("\n" + m[1] + marker + m[5])
+}
+
+func wordStart(line []rune, col int) int {
+	for col > 0 && unicode.IsSpace(line[col-1]) {
+		col--
+	}
+	for col > 0 && !unicode.IsSpace(line[col-1]) {
+		col--
+	}
+	return col
+}
+
+func wordEnd(line []rune, col int) int {
+	for col < len(line) && unicode.IsSpace(line[col]) {
+		col++
+	}
+	for col < len(line) && !unicode.IsSpace(line[col]) {
+		col++
+	}
+	return col
+}
+
+func (e editor) Update(msg tea.Msg) (editor, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok || !e.focused {
+		return e, nil
+	}
+	if e.find.open {
+		return e.updateFind(keyMsg)
+	}
+
+	switch {
+	case key.Matches(keyMsg, Keys.Undo):
+		e.undoEdit()
+	case key.Matches(keyMsg, Keys.Redo):
+		e.redoEdit()
+	case key.Matches(keyMsg, Keys.FindReplace):
+		return e, e.openFind()
+	case key.Matches(keyMsg, Keys.SoftWrap):
+		e.softWrap = !e.softWrap
+	case keyMsg.Paste:
+		e.record(editOther)
+		e.insert(normalizeNewlines(string(keyMsg.Runes)))
+	case (keyMsg.Type == tea.KeyRunes || keyMsg.Type == tea.KeySpace) && !keyMsg.Alt:
+		e.record(editTyping)
+		e.insert(string(keyMsg.Runes))
+		// The next word is undone on its own
+		if unicode.IsSpace(keyMsg.Runes[len(keyMsg.Runes)-1]) {
+			e.lastEdit = editNone
+		}
+	case key.Matches(keyMsg, editorKeys.Newline):
+		e.newline()
+	case key.Matches(keyMsg, editorKeys.Paste):
+		if text, err := clipboard.ReadAll(); err == nil && text != "" {
+			e.record(editOther)
+			e.insert(normalizeNewlines(text))
+		}
+	case key.Matches(keyMsg, editorKeys.Backspace):
+		if e.col > 0 {
+			e.record(editDeleting)
+			e.deleteRange(e.col-1, e.col)
+			e.col--
+		} else if e.row > 0 {
+			e.record(editDeleting)
+			e.col = len(e.lines[e.row-1])
+			e.joinLine(e.row - 1)
+			e.row--
+		}
+	case key.Matches(keyMsg, editorKeys.Delete):
+		if e.col < len(e.lines[e.row]) {
+			e.record(editDeleting)
+			e.deleteRange(e.col, e.col+1)
+		} else if e.row < len(e.lines)-1 {
+			e.record(editDeleting)
+			e.joinLine(e.row)
+		}
+	case key.Matches(keyMsg, editorKeys.DeleteWord):
+		if e.col > 0 {
+			e.record(editOther)
+			start := wordStart(e.lines[e.row], e.col)
+			e.deleteRange(start, e.col)
+			e.col = start
+		}
+	case key.Matches(keyMsg, editorKeys.DeleteToLineEnd):
+		if e.col < len(e.lines[e.row]) {
+			e.record(editOther)
+			e.deleteRange(e.col, len(e.lines[e.row]))
+		} else if e.row < len(e.lines)-1 {
+			e.record(editOther)
+			e.joinLine(e.row)
+		}
+	case key.Matches(keyMsg, editorKeys.DeleteToLineStart):
+		if e.col > 0 {
+			e.record(editOther)
+			e.deleteRange(0, e.col)
+			e.col = 0
+		}
+	default:
+		if !e.move(keyMsg) {
+			return e, nil
+		}
+		e.lastEdit = editNone
+		e.scroll()
+		return e, nil
+	}
+
+	e.goal = -1
+	e.scroll()
+	return e, nil
+}
+
+// move moves the cursor for the movement keys, reporting whether msg is one
+func (e *editor) move(msg tea.KeyMsg) bool {
+	goal := -1
+	switch {
+	case key.Matches(msg, editorKeys.Left):
+		if e.col > 0 {
+			e.col--
+		} else if e.row > 0 {
+			e.row--
+			e.col = len(e.lines[e.row])
+		}
+	case key.Matches(msg, editorKeys.Right):
+		if e.col < len(e.lines[e.row]) {
+			e.col++
+		} else if e.row < len(e.lines)-1 {
+			e.row++
+			e.col = 0
+		}
+	case key.Matches(msg, editorKeys.Up):
+		goal = e.moveRows(-1)
+	case key.Matches(msg, editorKeys.Down):
+		goal = e.moveRows(1)
+	case key.Matches(msg, editorKeys.PageUp):
+		goal = e.moveRows(-e.textHeight())
+	case key.Matches(msg, editorKeys.PageDown):
+		goal = e.moveRows(e.textHeight())
+	case key.Matches(msg, editorKeys.WordLeft):
+		if e.col == 0 && e.row > 0 {
+			e.row--
+			e.col = len(e.lines[e.row])
+		} else {
+			e.col = wordStart(e.lines[e.row], e.col)
+		}
+	case key.Matches(msg, editorKeys.WordRight):
+		if e.col == len(e.lines[e.row]) && e.row < len(e.lines)-1 {
+			e.row++
+			e.col = 0
+		} else {
+			e.col = wordEnd(e.lines[e.row], e.col)
+		}
+	case key.Matches(msg, editorKeys.LineStart):
+		e.col = 0
+	case key.Matches(msg, editorKeys.LineEnd):
+		e.col = len(e.lines[e.row])
+	case key.Matches(msg, editorKeys.Start):
+		e.row, e.col = 0, 0
+	case key.Matches(msg, editorKeys.End):
+		e.row = len(e.lines) - 1
+		e.col = len(e.lines[e.row])
+	default:
+		return false
+	}
+	e.goal = goal
+	return true
+}
+
+// moveRows moves the cursor n rows up or down, keeping its column where the
+// rows are long enough. It returns the column to keep on the next move.
+func (e *editor) moveRows(n int) int {
+	rows := e.rows()
+	i := e.cursorRow(rows)
+	goal := e.goal
+	if goal < 0 {
+		goal = e.col - rows[i].start
+	}
+
+	target := max(min(i+n, len(rows)-1), 0)
+	if target == i {
+		// Up on the first row goes to its start, down on the last to its end
+		if n < 0 {
+			e.col = rows[i].start
+		} else {
+			e.col = rows[i].end
+		}
+		return -1
+	}
+
+	r := rows[target]
+	e.row = r.line
+	e.col = r.start + goal
+	if target+1 < len(rows) && rows[target+1].line == r.line {
+		e.col = min(e.col, r.end-1)
+	} else {
+		e.col = min(e.col, r.end)
+	}
+	return goal
+}
+
+// click moves the cursor to the cell x, y of the editor
+func (e *editor) click(x, y int) {
+	if y >= e.textHeight() {
+		return
+	}
+	rows := e.rows()
+	r := rows[min(e.top+y, len(rows)-1)]
+	col := r.start + e.left + max(x-e.digits()-1, 0)
+	if r.end > r.start && col >= r.end && r.end < len(e.lines[r.line]) {
+		col = r.end - 1
+	}
+	e.row, e.col = r.line, min(col, r.end)
+	e.goal = -1
+	e.lastEdit = editNone
+	e.scroll()
+}
+
+func (e *editor) openFind() tea.Cmd {
+	e.find.open = true
+	e.find.replacing = false
+	e.find.replaced = 0
+	e.find.replacement.Blur()
+	e.refreshFind()
+	e.selectMatch()
+	return e.find.query.Focus()
+}
+
+func (e *editor) closeFind() {
+	e.find.open = false
+	e.find.query.Blur()
+	e.find.replacement.Blur()
+	e.find.matches = nil
+	e.scroll()
+}
+
+// refreshFind looks for the query again and makes the first match from the
+// cursor on the current one
+func (e *editor) refreshFind() {
+	f := &e.find
+	f.matches = nil
+	f.current = 0
+	f.err = ""
+	if !f.open || f.query.Value() == "" {
+		return
+	}
+
+	re, err := searchPattern(f.query.Value(), f.matchCase, f.regex)
+	if err != nil {
+		f.err = "Invalid regular expression"
+		return
+	}
+	f.re = re
+
+	for i, line := range e.lines {
+		s := string(line)
+		for _, groups := range re.FindAllStringSubmatchIndex(s, -1) {
+			if groups[0] == groups[1] {
+				continue
+			}
+			f.matches = append(f.matches, editorMatch{
+				row:    i,
+				start:  utf8.RuneCountInString(s[:groups[0]]),
+				end:    utf8.RuneCountInString(s[:groups[1]]),
+				groups: groups,
+			})
+		}
+	}
+
+	for i, match := range f.matches {
+		if match.row > e.row || match.row == e.row && match.start >= e.col {
+			f.current = i
+			break
+		}
+	}
+}
+
+// selectMatch moves the cursor to the current match
+func (e *editor) selectMatch() {
+	if len(e.find.matches) == 0 {
+		return
+	}
+	match := e.find.matches[e.find.current]
+	e.row, e.col = match.row, match.start
+	e.goal = -1
+	e.scroll()
+}
+
+// replacement is what replaces match, with $1 and the like expanded in regex
+// mode
+func (e *editor) replacement(match editorMatch) string {
+	replacement := e.find.replacement.Value()
+	if !e.find.regex {
+		return replacement
+	}
+	line := string(e.lines[match.row])
+	return string(e.find.re.ExpandString(nil, replacement, line, match.groups))
+}
+
+// replaceMatch replaces the current match and moves on to the next
+func (e *editor) replaceMatch() {
+	if len(e.find.matches) == 0 {
+		return
+	}
+	match := e.find.matches[e.find.current]
+	replacement := e.replacement(match)
+
+	e.record(editOther)
+	line := string(e.lines[match.row])
+	e.lines[match.row] = []rune(line[:match.groups[0]] + replacement + line[match.groups[1]:])
+	e.row, e.col = match.row, match.start+utf8.RuneCountInString(replacement)
+
+	e.refreshFind()
+	e.selectMatch()
+}
+
+// replaceAll replaces every match as one step of the undo history
+func (e *editor) replaceAll() {
+	matches := e.find.matches
+	if len(matches) == 0 {
+		return
+	}
+
+	e.record(editOther)
+	// From the last match on, so that the offsets of the others stay right
+	for i := len(matches) - 1; i >= 0; i-- {
+		match := matches[i]
+		line := string(e.lines[match.row])
+		e.lines[match.row] = []rune(line[:match.groups[0]] + e.replacement(match) + line[match.groups[1]:])
+	}
+	e.col = min(e.col, len(e.lines[e.row]))
+
+	e.refreshFind()
+	e.find.replaced = len(matches)
+	e.scroll()
+}
+
+// updateFind handles the keys of the find bar
+func (e editor) updateFind(msg tea.KeyMsg) (editor, tea.Cmd) {
+	f := &e.find
+	f.replaced = 0
+
+	switch {
+	case key.Matches(msg, Keys.Cancel):
+		e.closeFind()
+		return e, nil
+	case key.Matches(msg, Keys.NextField, Keys.PrevField, Keys.FindReplace):
+		f.replacing = !f.replacing && !key.Matches(msg, Keys.FindReplace)
+		if f.replacing {
+			f.query.Blur()
+			return e, f.replacement.Focus()
+		}
+		f.replacement.Blur()
+		return e, f.query.Focus()
+	case key.Matches(msg, Keys.Confirm):
+		if f.replacing {
+			e.replaceMatch()
+		} else if len(f.matches) > 0 {
+			f.current = (f.current + 1) % len(f.matches)
+			e.selectMatch()
+		}
+		return e, nil
+	case key.Matches(msg, Keys.Replace):
+		e.replaceMatch()
+		return e, nil
+	case key.Matches(msg, Keys.ReplaceAll):
+		e.replaceAll()
+		return e, nil
+	case key.Matches(msg, Keys.MatchCase):
+		f.matchCase = !f.matchCase
+	case key.Matches(msg, Keys.UseRegex):
+		f.regex = !f.regex
+	case key.Matches(msg, Keys.Undo):
+		e.undoEdit()
+	case key.Matches(msg, Keys.Redo):
+		e.redoEdit()
+	default:
+		var cmd tea.Cmd
+		if f.replacing {
+			f.replacement, cmd = f.replacement.Update(msg)
+			return e, cmd
+		}
+		query := f.query.Value()
+		f.query, cmd = f.query.Update(msg)
+		if f.query.Value() == query {
+			return e, cmd
+		}
+		e.refreshFind()
+		e.selectMatch()
+		return e, cmd
+	}
+
+	e.refreshFind()
+	e.selectMatch()
+	return e, nil
+}
+
+func (e editor) View() string {
+	rows := e.rows()
+	cursor := e.cursorRow(rows)
+	digits := e.digits()
+	width := e.textWidth()
+
+	empty := e.Value() == ""
+	var lines []string
+	for i := e.top; i < e.top+e.textHeight(); i++ {
+		if i >= len(rows) {
+			lines = append(lines, strings.Repeat(" ", digits+1+width))
+			continue
+		}
+
+		r := rows[i]
+		number := strings.Repeat(" ", digits)
+		if r.start == 0 {
+			number = fmt.Sprintf("%*d", digits, r.line+1)
+		}
+		numberStyle := Subtle
+		if r.line == e.row && e.focused {
+			numberStyle = Normal
+		}
+
+		var text string
+		if empty && e.Placeholder != "" {
+			text = e.placeholderView(width)
+		} else {
+			text = e.rowView(r, i == cursor, width)
+		}
+		lines = append(lines, numberStyle.Render(number)+" "+text)
+	}
+
+	if e.find.open {
+		lines = append(lines, e.findView()...)
+	}
+	lines = append(lines, e.statusView())
+	return strings.Join(lines, "\n")
+}
+
+func (e *editor) placeholderView(width int) string {
+	placeholder := []rune(truncateString(e.Placeholder, width))
+	if !e.focused || len(placeholder) == 0 {
+		return Subtle.Render(padRight(string(placeholder), width))
+	}
+	return cursorStyle.Render(string(placeholder[:1])) + Subtle.Render(padRight(string(placeholder[1:]), width-1))
+}
+
+// rowView draws a row, highlighting matches of the find bar and the cursor
+func (e *editor) rowView(r visualRow, hasCursor bool, width int) string {
+	line := e.lines[r.line]
+	from, to := r.start, r.end
+	if !e.softWrap {
+		from = min(r.start+e.left, r.end)
+		to = min(from+width-1, r.end)
+	}
+
+	// style picks how each rune is drawn: 0 plain, 1 a match, 2 the current
+	// match, 3 the cursor
+	style := func(col int) int {
+		if hasCursor && e.focused && col == e.col {
+			return 3
+		}
+		for i, match := range e.find.matches {
+			if match.row == r.line && col >= match.start && col < match.end {
+				if i == e.find.current {
+					return 2
+				}
+				return 1
+			}
+		}
+		return 0
+	}
+	styles := []lipgloss.Style{Normal, Match, CurrentMatch, cursorStyle}
+
+	var out strings.Builder
+	used := 0
+	for col := from; col < to; {
+		s := style(col)
+		end := col + 1
+		for end < to && style(end) == s {
+			end++
+		}
+		part := string(line[col:end])
+		out.WriteString(styles[s].Render(part))
+		used += ansi.StringWidth(part)
+		col = end
+	}
+	if hasCursor && e.focused && e.col == to {
+		out.WriteString(cursorStyle.Render(" "))
+		used++
+	}
+	out.WriteString(strings.Repeat(" ", max(width-used, 0)))
+	return out.String()
+}
+
+func padRight(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-ansi.StringWidth(s), 0))
+}
+
+// findView is the find bar, two lines high
+func (e *editor) findView() []string {
+	f := &e.find
+	inputWidth := max(min(30, e.width-40), 8)
+	f.query.Width = inputWidth
+	f.replacement.Width = inputWidth
+
+	label := func(name string, active bool) string {
+		if active {
+			return Subtitle.Render(name)
+		}
+		return Subtle.Render(name)
+	}
+
+	status := searchStatus(f.err, f.query.Value(), len(f.matches), f.current)
+	if f.replaced > 0 {
+		status = Success.Render(fmt.Sprintf("Replaced %d", f.replaced))
+	}
+
+	find := label("Find:    ", !f.replacing) + padRight(f.query.View(), inputWidth+2) + status
+	replace := label("Replace: ", f.replacing) + padRight(f.replacement.View(), inputWidth+2) + searchToggles(f.matchCase, f.regex)
+	return []string{ansi.Truncate(find, e.width, ""), ansi.Truncate(replace, e.width, "")}
+}
+
+// statusView tells where the cursor is, how long the text is and whether long
+// lines are wrapped
+func (e *editor) statusView() string {
+	value := e.Value()
+	words := len(strings.Fields(value))
+	characters := utf8.RuneCountInString(value)
+
+	wrap := "no wrap"
+	if e.softWrap {
+		wrap = "soft wrap"
+	}
+	status := fmt.Sprintf("Ln %d, Col %d • %s • %s • %s",
+		e.row+1, e.col+1, countOf(words, "word"), countOf(characters, "character"), wrap)
+	return Subtle.Render(ansi.Truncate(status, e.width, "…"))
+}
+
+// countOf is e.g. "1 word" or "2 words"
+func countOf(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
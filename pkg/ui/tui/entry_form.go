@@ -0,0 +1,320 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/s42yt/thighpads/pkg/data"
+	"github.com/s42yt/thighpads/pkg/database"
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+// The new and edit entry forms share what happens to changes that are not
+// saved yet: they are written to a draft every few seconds, leaving the form
+// asks whether to save or discard them, and drafts left behind by a crash are
+// opened again on the next start.
+
+func (a *App) entryFormOpen() bool {
+	return a.screen == NewEntryScreen || a.screen == EditEntryScreen
+}
+
+// formEntryUUID is the entry the open form edits, empty for a new entry
+func (a *App) formEntryUUID() string {
+	if a.screen == EditEntryScreen {
+		return a.currentEntry.UUID
+	}
+	return ""
+}
+
+// startEntryForm remembers what the form was opened with, to tell when it has
+// unsaved changes, and brings back the draft of changes that were not saved
+// last time
+func (a *App) startEntryForm(baseline models.Entry) {
+	a.entryBaseline = baseline
+	a.pendingLeave = nil
+	a.savedDraft = entryDraft{}
+
+	draft, ok := loadDraft(a.currentTable.UUID, a.formEntryUUID())
+	if !ok {
+		return
+	}
+	a.entryTitleInput.SetValue(draft.Title)
+	a.entryTagsInput.SetValue(draft.Tags)
+	a.entryLangInput.SetValue(draft.Language)
+	a.entryContent.SetValue(draft.Content)
+	a.savedDraft = draft
+	a.successMsg = fmt.Sprintf("Restored unsaved changes from %s. '%s' saves them, '%s' lets you discard them.",
+		draft.SavedAt.Format("Jan 02 15:04"), KeyName(Keys.Save), KeyName(Keys.Cancel))
+}
+
+// entryFields are the fields of the forms in the order NextField moves
+// through them
+var entryFields = []string{"title", "tags", "language", "content"}
+
+// entryLanguageField is the language field of the forms. While it is empty,
+// its label tells which language is detected from the content instead.
+func (a *App) entryLanguageField() string {
+	label := Subtitle.Render("Language:")
+	if a.entryLangInput.Value() == "" {
+		if language := data.DetectLanguage(a.entryContent.Value()); language != "" {
+			label += " " + Subtle.Render("detected "+language)
+		} else {
+			label += " " + Subtle.Render("detected from code blocks")
+		}
+	}
+	return markZone("field:language", label+"\n"+a.entryLangInput.View())
+}
+
+// focusEntryField moves the focus to the title, tags, language or content field
+func (a *App) focusEntryField(field string) {
+	a.entryTitleInput.Blur()
+	a.entryTagsInput.Blur()
+	a.entryLangInput.Blur()
+	a.entryContent.Blur()
+
+	switch field {
+	case "title":
+		a.entryTitleInput.Focus()
+	case "tags":
+		a.entryTagsInput.Focus()
+	case "language":
+		a.entryLangInput.Focus()
+	case "content":
+		a.entryContent.Focus()
+	}
+}
+
+// focusedEntryField is the field of the forms that has the focus
+func (a *App) focusedEntryField() string {
+	switch {
+	case a.entryTitleInput.Focused():
+		return "title"
+	case a.entryTagsInput.Focused():
+		return "tags"
+	case a.entryLangInput.Focused():
+		return "language"
+	}
+	return "content"
+}
+
+// nextEntryField moves the focus on to the next field, from content back to
+// the title
+func (a *App) nextEntryField() {
+	for i, field := range entryFields {
+		if field == a.focusedEntryField() {
+			a.focusEntryField(entryFields[(i+1)%len(entryFields)])
+			return
+		}
+	}
+}
+
+// updateEntryField passes msg on to the focused field
+func (a *App) updateEntryField(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	switch a.focusedEntryField() {
+	case "title":
+		a.entryTitleInput, cmd = a.entryTitleInput.Update(msg)
+	case "tags":
+		a.entryTagsInput, cmd = a.entryTagsInput.Update(msg)
+	case "language":
+		a.entryLangInput, cmd = a.entryLangInput.Update(msg)
+	default:
+		a.entryContent, cmd = a.entryContent.Update(msg)
+	}
+	return cmd
+}
+
+// sizeEntryEditor fits the content editor into the form drawn between header
+// and help. Besides the editor, the form shows the title, tags and language
+// fields, the content label and extra more lines.
+func (a *App) sizeEntryEditor(header, help string, extra int) {
+	a.entryContent.SetWidth(a.width - 10)
+	a.entryContent.SetHeight(a.contentHeight(header, help) - 10 - extra)
+}
+
+// entryFormDirty reports whether the form differs from what it was opened with
+func (a *App) entryFormDirty() bool {
+	return a.entryTitleInput.Value() != a.entryBaseline.Title ||
+		a.entryTagsInput.Value() != a.entryBaseline.Tags ||
+		a.entryLangInput.Value() != a.entryBaseline.Language ||
+		a.entryContent.Value() != a.entryBaseline.Content
+}
+
+// saveDraft writes the unsaved changes of the form to its draft, or removes
+// the draft when the changes were undone
+func (a *App) saveDraft() {
+	if !a.entryFormDirty() {
+		if !a.savedDraft.SavedAt.IsZero() {
+			a.discardDraft()
+		}
+		return
+	}
+
+	draft := entryDraft{
+		TableUUID: a.currentTable.UUID,
+		EntryUUID: a.formEntryUUID(),
+		Title:     a.entryTitleInput.Value(),
+		Tags:      a.entryTagsInput.Value(),
+		Content:   a.entryContent.Value(),
+		Language:  a.entryLangInput.Value(),
+	}
+	if draft.Title == a.savedDraft.Title && draft.Tags == a.savedDraft.Tags &&
+		draft.Content == a.savedDraft.Content && draft.Language == a.savedDraft.Language {
+		return
+	}
+
+	draft.SavedAt = time.Now()
+	if err := writeDraft(draft); err != nil {
+		a.errorMsg = "Could not save a draft: " + err.Error()
+		return
+	}
+	a.savedDraft = draft
+}
+
+func (a *App) discardDraft() {
+	removeDraft(a.currentTable.UUID, a.formEntryUUID())
+	a.savedDraft = entryDraft{}
+}
+
+// saveEntryForm saves the open form, reporting whether that worked
+func (a *App) saveEntryForm() bool {
+	if a.screen == EditEntryScreen {
+		return a.saveEditedEntry()
+	}
+	return a.saveNewEntry()
+}
+
+// leaveEntryForm runs leave, which takes the user away from the form, once
+// they decided what happens to unsaved changes
+func (a *App) leaveEntryForm(leave func(a *App) tea.Cmd) tea.Cmd {
+	if a.entryFormDirty() {
+		a.pendingLeave = leave
+		return nil
+	}
+	a.discardDraft()
+	return leave(a)
+}
+
+// closeEntryForm goes back to where the form was opened from, or on to the
+// next draft while recovering them
+func (a *App) closeEntryForm() tea.Cmd {
+	a.goBack()
+	if a.recovering {
+		a.recoverDraft()
+	}
+	return nil
+}
+
+// updateLeavePrompt handles the keys of the question what to do with unsaved
+// changes
+func (a *App) updateLeavePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	leave := a.pendingLeave
+
+	switch {
+	case key.Matches(msg, Keys.Save):
+		a.pendingLeave = nil
+		if a.saveEntryForm() {
+			return a, leave(a)
+		}
+	case key.Matches(msg, Keys.Discard):
+		a.pendingLeave = nil
+		a.discardDraft()
+		return a, leave(a)
+	case key.Matches(msg, Keys.Cancel):
+		a.pendingLeave = nil
+	case key.Matches(msg, Keys.ForceQuit):
+		// The changes stay in the draft and come back on the next start
+		a.saveDraft()
+		return a, tea.Quit
+	}
+	return a, nil
+}
+
+// entryFormStatus tells whether the form has unsaved changes and when they
+// were last saved as a draft
+func (a *App) entryFormStatus() string {
+	if !a.entryFormDirty() {
+		return ""
+	}
+	status := Warning.Render("Unsaved changes")
+	if !a.savedDraft.SavedAt.IsZero() {
+		status += Subtle.Render(" • draft saved at " + a.savedDraft.SavedAt.Format("15:04:05"))
+	}
+	return status
+}
+
+// entryFormHelp is the help bar of the forms, or the question what to do with
+// unsaved changes while it is asked
+func (a *App) entryFormHelp(saveHelp string) string {
+	if a.pendingLeave != nil {
+		return Warning.Render("This entry has unsaved changes. Save them before leaving?") + "\n" + HelpView(
+			Help("Save", Keys.Save),
+			Help("Discard changes", Keys.Discard),
+			Help("Keep editing", Keys.Cancel),
+			Help("Quit and keep a draft", Keys.ForceQuit),
+		)
+	}
+	if a.entryContent.finding() {
+		return HelpView(
+			Help("Next match", Keys.Confirm),
+			Help("Replace", Keys.Replace),
+			Help("Replace all", Keys.ReplaceAll),
+			Help("Find/replace", Keys.NextField),
+			Help("Close", Keys.Cancel),
+		)
+	}
+	if a.entryContent.Focused() {
+		return HelpView(
+			Help("Next field", Keys.NextField),
+			Help(saveHelp, Keys.Save),
+			Help("Cancel", Keys.Cancel),
+			Help("Undo", Keys.Undo),
+			Help("Find/replace", Keys.FindReplace),
+			Help("Wrap", Keys.SoftWrap),
+		)
+	}
+	return HelpView(
+		Help("Next field", Keys.NextField),
+		Help(saveHelp, Keys.Save),
+		Help("Cancel", Keys.Cancel),
+		Help("Quit", Keys.ForceQuit),
+	)
+}
+
+// recoverDraft opens the form of the newest draft to bring back changes that
+// were not saved when ThighPads was last closed. Drafts of tables and entries
+// that were deleted since are dropped.
+func (a *App) recoverDraft() {
+	a.recovering = false
+
+	for _, draft := range listDrafts() {
+		if draft.TableUUID == "" {
+			os.Remove(draft.path)
+			continue
+		}
+		table, err := database.FindTableByUUID(draft.TableUUID)
+		if err != nil {
+			os.Remove(draft.path)
+			continue
+		}
+
+		if draft.EntryUUID == "" {
+			a.openTable(table)
+			a.openNewEntryScreen()
+		} else {
+			entry, err := database.FindEntryByUUID(draft.EntryUUID)
+			if err != nil || entry.TableID != table.ID {
+				os.Remove(draft.path)
+				continue
+			}
+			a.openTable(table)
+			a.openEntry(entry)
+			a.openEditEntryScreen()
+		}
+		a.recovering = true
+		return
+	}
+}
@@ -0,0 +1,297 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// entrySearch is the search of the entry screen. Matches are highlighted as
+// the query is typed; n and N then move between them.
+type entrySearch struct {
+	input textinput.Model
+	// typing is set while the query is typed
+	typing    bool
+	matchCase bool
+	regex     bool
+	// origin is where the entry was scrolled to when the search started
+	origin  int
+	matches []searchMatch
+	current int
+	err     string
+}
+
+// searchMatch is a match in the content, by line and byte offsets in it. row
+// is the line of the viewport it starts on once long lines are wrapped.
+type searchMatch struct {
+	line, start, end int
+	row              int
+}
+
+func newEntrySearch() entrySearch {
+	input := textinput.New()
+	input.Prompt = "/"
+	input.Placeholder = "Find in entry"
+	return entrySearch{input: input}
+}
+
+func (s *entrySearch) active() bool {
+	return s.typing || s.input.Value() != ""
+}
+
+// searchPattern compiles a query, which is a regular expression in regex mode
+// and plain text otherwise
+func searchPattern(query string, matchCase, regex bool) (*regexp.Regexp, error) {
+	if !regex {
+		query = regexp.QuoteMeta(query)
+	}
+	if !matchCase {
+		query = "(?i)" + query
+	}
+	return regexp.Compile(query)
+}
+
+// searchStatus tells how many matches a query has and which is the current one
+func searchStatus(err, query string, matches, current int) string {
+	switch {
+	case err != "":
+		return Error.Render(err)
+	case query == "":
+		return ""
+	case matches == 0:
+		return Warning.Render("No matches")
+	}
+	return Subtle.Render(fmt.Sprintf("Match %d of %d", current+1, matches))
+}
+
+// searchToggles shows whether a search matches case and is a regular
+// expression, with the keys that toggle them
+func searchToggles(matchCase, regex bool) string {
+	toggle := func(name string, on bool, binding key.Binding) string {
+		text := fmt.Sprintf("[%s] %s", KeyName(binding), name)
+		if on {
+			return Normal.Render(text)
+		}
+		return Subtle.Render(text)
+	}
+	return toggle("Match case", matchCase, Keys.MatchCase) + "  " + toggle("Regex", regex, Keys.UseRegex)
+}
+
+// entryLines are the lines of the entry as shown, with tabs expanded
+func (a *App) entryLines() []string {
+	return strings.Split(strings.ReplaceAll(a.currentEntry.Content, "\t", "    "), "\n")
+}
+
+// findMatches looks for the query in the entry and selects the first match
+// from where the search started
+func (a *App) findMatches() {
+	s := &a.search
+	s.matches = nil
+	s.current = 0
+	s.err = ""
+
+	if s.input.Value() != "" {
+		re, err := searchPattern(s.input.Value(), s.matchCase, s.regex)
+		if err != nil {
+			s.err = "Invalid regular expression"
+		} else {
+			for i, line := range a.entryLines() {
+				for _, loc := range re.FindAllStringIndex(line, -1) {
+					// Empty matches, e.g. of "a*", cannot be highlighted
+					if loc[0] < loc[1] {
+						s.matches = append(s.matches, searchMatch{line: i, start: loc[0], end: loc[1]})
+					}
+				}
+			}
+		}
+	}
+
+	a.renderEntry()
+
+	for i, match := range s.matches {
+		if match.row >= s.origin {
+			s.current = i
+			break
+		}
+	}
+	if len(s.matches) > 0 {
+		a.scrollToMatch()
+	} else {
+		a.entryViewport.SetYOffset(s.origin)
+	}
+}
+
+// renderEntry shows the entry in its viewport, wrapping long lines to its
+// width and highlighting its code and the matches of the search
+func (a *App) renderEntry() {
+	s := &a.search
+	width := max(a.entryViewport.Width, 10)
+
+	lines := a.entryLines()
+	code := entryCode(lines, a.currentEntry.Content, a.currentEntry.Language)
+
+	var rows []string
+	next := 0
+	for i, line := range lines {
+		first := next
+		for next < len(s.matches) && s.matches[next].line == i {
+			next++
+		}
+		matches := s.matches[first:next]
+
+		starts := wrapLine(line, width)
+		for j, start := range starts {
+			end := len(line)
+			if j+1 < len(starts) {
+				end = starts[j+1]
+			}
+			for k := range matches {
+				if matches[k].start >= start && matches[k].start < end {
+					matches[k].row = len(rows)
+				}
+			}
+			rows = append(rows, highlightRow(line, start, end, code[i], matches, first, s.current))
+		}
+	}
+
+	a.entryViewport.SetContent(strings.Join(rows, "\n"))
+}
+
+// highlightRow renders line[start:end], highlighting the parts covered by
+// matches over those of the code; the match numbered current is highlighted
+// differently
+func highlightRow(line string, start, end int, code []codeSpan, matches []searchMatch, first, current int) string {
+	var row strings.Builder
+	pos := start
+	for k, match := range matches {
+		from, to := max(match.start, start), min(match.end, end)
+		if from >= to {
+			continue
+		}
+		writeCode(&row, line, pos, from, code)
+		style := Match
+		if first+k == current {
+			style = CurrentMatch
+		}
+		row.WriteString(style.Render(line[from:to]))
+		pos = to
+	}
+	writeCode(&row, line, pos, end, code)
+	return row.String()
+}
+
+// wrapLine splits line into rows at most width cells wide, breaking after a
+// space where there is one, and returns the byte offset each row starts at
+func wrapLine(line string, width int) []int {
+	starts := []int{0}
+	rowStart, rowWidth, afterSpace := 0, 0, 0
+	for i, r := range line {
+		w := ansi.StringWidth(string(r))
+		if rowWidth+w > width && i > rowStart {
+			if afterSpace > rowStart {
+				rowStart = afterSpace
+			} else {
+				rowStart = i
+			}
+			starts = append(starts, rowStart)
+			rowWidth = ansi.StringWidth(line[rowStart:i])
+		}
+		rowWidth += w
+		if r == ' ' {
+			afterSpace = i + 1
+		}
+	}
+	return starts
+}
+
+// scrollToMatch scrolls the current match into the middle of the entry
+// unless it is visible already
+func (a *App) scrollToMatch() {
+	row := a.search.matches[a.search.current].row
+	vp := &a.entryViewport
+	if row < vp.YOffset || row >= vp.YOffset+vp.Height {
+		vp.SetYOffset(row - vp.Height/2)
+	}
+}
+
+// jumpToMatch selects the match by steps after the current one, wrapping
+// around at either end
+func (a *App) jumpToMatch(by int) {
+	s := &a.search
+	if len(s.matches) == 0 {
+		return
+	}
+	s.current = (s.current + by + len(s.matches)) % len(s.matches)
+	a.renderEntry()
+	a.scrollToMatch()
+}
+
+func (a *App) startEntrySearch() tea.Cmd {
+	a.search.typing = true
+	a.search.origin = a.entryViewport.YOffset
+	a.search.input.CursorEnd()
+	a.findMatches()
+	return a.search.input.Focus()
+}
+
+// stopEntrySearch removes the search and its highlights
+func (a *App) stopEntrySearch() {
+	a.search.typing = false
+	a.search.input.Blur()
+	a.search.input.SetValue("")
+	a.findMatches()
+}
+
+// updateEntrySearch handles the keys of the entry screen while the query is
+// typed
+func (a *App) updateEntrySearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	s := &a.search
+	switch {
+	case key.Matches(msg, Keys.Confirm):
+		s.typing = false
+		s.input.Blur()
+		if s.input.Value() == "" {
+			a.stopEntrySearch()
+		}
+		return a, nil
+	case key.Matches(msg, Keys.Cancel):
+		a.stopEntrySearch()
+		return a, nil
+	case key.Matches(msg, Keys.MatchCase):
+		s.matchCase = !s.matchCase
+		a.findMatches()
+		return a, nil
+	case key.Matches(msg, Keys.UseRegex):
+		s.regex = !s.regex
+		a.findMatches()
+		return a, nil
+	case key.Matches(msg, Keys.ForceQuit):
+		return a, tea.Quit
+	}
+
+	query := s.input.Value()
+	var cmd tea.Cmd
+	s.input, cmd = s.input.Update(msg)
+	if s.input.Value() != query {
+		a.findMatches()
+	}
+	return a, cmd
+}
+
+// entrySearchView is the query and how many matches it has, shown below the
+// entry
+func (a *App) entrySearchView() string {
+	s := &a.search
+	status := searchStatus(s.err, s.input.Value(), len(s.matches), s.current)
+
+	query := s.input.View()
+	if !s.typing {
+		query = Normal.Render("/" + s.input.Value())
+	}
+	return query + "  " + status + "  " + searchToggles(s.matchCase, s.regex)
+}
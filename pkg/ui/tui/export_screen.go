@@ -2,94 +2,322 @@ package tui
 
 import (
 	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/s42yt/thighpads/pkg/config"
 	"github.com/s42yt/thighpads/pkg/data"
 )
 
+// Rows of the export form, in the order ↑/↓ moves through them
+const (
+	exportNameField = iota
+	exportFormatField
+	exportDestinationField
+	exportPathField
+	exportPolicyField
+)
+
+// openExportScreen shows the export form for the current table, or for the
+// tables in exportTableIDs (all tables if empty) when all is set
+func (a *App) openExportScreen(all bool) {
+	a.navigate(ExportScreen)
+	a.exportAll = all
+	a.exportFormat = data.ThighpadFormat
+	a.exportFocus = exportNameField
+
+	template := data.DefaultFilenameTemplate
+	a.exportPolicy = data.VersionExisting
+	if exportsConfig, err := config.LoadExportsConfig(); err == nil {
+		if exportsConfig.FilenameTemplate != "" {
+			template = exportsConfig.FilenameTemplate
+		}
+		a.exportPolicy = data.ParseOverwritePolicy(exportsConfig.OverwritePolicy)
+	}
+
+	a.exportName = TextInputField(template)
+	a.exportPathInput = TextInputField("Folder to export to, e.g. ~/Documents")
+	a.exportPathInput.Blur()
+
+	// The last destination is always a folder typed in by the user
+	a.exportDests = append(data.ExportDestinations(), data.ExportDestination{
+		Name:     "Custom folder",
+		Location: data.PathLocation,
+	})
+	a.exportDest = 0
+}
+
 func (a *App) updateExportScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "1":
-			a.exportLocation = DefaultExport
+		// Digits pick a destination unless a text field has focus
+		if n, err := strconv.Atoi(msg.String()); err == nil && !a.exportTyping() {
+			if n >= 1 && n <= len(a.exportDests) {
+				a.exportDest = n - 1
+			}
+			return a, nil
+		}
+
+		// Letters go to the text field that has focus
+		if a.exportTyping() && typed(msg) {
+			break
+		}
+
+		switch {
+		case key.Matches(msg, Keys.Up, Keys.PrevField):
+			a.moveExportFocus(-1)
 			return a, nil
-		case "2":
-			a.exportLocation = DesktopExport
+		case key.Matches(msg, Keys.Down):
+			a.moveExportFocus(1)
 			return a, nil
-		case "3":
-			a.exportLocation = BothExport
+		case key.Matches(msg, Keys.NextField):
+			a.exportFormat = nextExportFormat(a.exportFormat)
 			return a, nil
-		case "enter":
-			if a.exportName.Value() != "" {
-				var filename string
-				var err error
-
-				switch a.exportLocation {
-				case DefaultExport:
-					filename, err = data.ExportTable(a.currentTable.ID, a.config.Username)
-				case DesktopExport:
-					filename, err = data.ExportTableToDesktop(a.currentTable.ID, a.config.Username)
-				case BothExport:
-					filename, err = data.ExportTableToLocation(a.currentTable.ID, a.config.Username, data.BothLocations)
-				}
-
-				if err != nil {
-					a.errorMsg = err.Error()
-					return a, nil
-				}
-
-				a.screen = TableScreen
-				a.successMsg = "Table exported successfully to: " + filename
+		case key.Matches(msg, Keys.Left, Keys.Right):
+			if a.exportTyping() {
+				break
+			}
+			delta := 1
+			if key.Matches(msg, Keys.Left) {
+				delta = -1
+			}
+			a.cycleExportOption(delta)
+			return a, nil
+		case key.Matches(msg, Keys.Save):
+			if a.customExportDestination() {
+				a.saveExportDestination()
+				return a, nil
+			}
+		case key.Matches(msg, Keys.Confirm):
+			filename, err := a.exportSelection()
+			if err != nil {
+				a.errorMsg = err.Error()
 				return a, nil
 			}
-		case "esc":
-			a.screen = TableScreen
+
+			a.leaveExportScreen()
+			a.successMsg = "Exported successfully to: " + filename
+			return a, nil
+		case key.Matches(msg, Keys.Cancel):
+			a.leaveExportScreen()
 			return a, nil
-		case "ctrl+c":
+		case key.Matches(msg, Keys.ForceQuit):
 			return a, tea.Quit
 		}
 	}
 
-	a.exportName, cmd = a.exportName.Update(msg)
+	switch a.exportFocus {
+	case exportNameField:
+		a.exportName, cmd = a.exportName.Update(msg)
+	case exportPathField:
+		a.exportPathInput, cmd = a.exportPathInput.Update(msg)
+	}
 	return a, cmd
 }
 
+// exportTyping reports whether keys go to one of the text fields
+func (a *App) exportTyping() bool {
+	return a.exportFocus == exportNameField || a.exportFocus == exportPathField
+}
+
+func (a *App) customExportDestination() bool {
+	return a.exportDests[a.exportDest].Location == data.PathLocation &&
+		a.exportDests[a.exportDest].Path == ""
+}
+
+// moveExportFocus moves between the form rows, skipping the folder input
+// unless a custom folder is selected
+func (a *App) moveExportFocus(delta int) {
+	rows := exportPolicyField + 1
+	for {
+		a.exportFocus = (a.exportFocus + delta + rows) % rows
+		if a.exportFocus != exportPathField || a.customExportDestination() {
+			break
+		}
+	}
+
+	a.exportName.Blur()
+	a.exportPathInput.Blur()
+	switch a.exportFocus {
+	case exportNameField:
+		a.exportName.Focus()
+	case exportPathField:
+		a.exportPathInput.Focus()
+	}
+}
+
+func (a *App) cycleExportOption(delta int) {
+	switch a.exportFocus {
+	case exportFormatField:
+		if delta > 0 {
+			a.exportFormat = nextExportFormat(a.exportFormat)
+		} else {
+			a.exportFormat = previousExportFormat(a.exportFormat)
+		}
+	case exportDestinationField:
+		count := len(a.exportDests)
+		a.exportDest = (a.exportDest + delta + count) % count
+	case exportPolicyField:
+		count := len(data.OverwritePolicies)
+		a.exportPolicy = data.OverwritePolicy((int(a.exportPolicy) + delta + count) % count)
+	}
+}
+
+// saveExportDestination stores the typed folder as a named destination so it
+// is offered directly next time
+func (a *App) saveExportDestination() {
+	path := strings.TrimSpace(a.exportPathInput.Value())
+	if path == "" {
+		a.errorMsg = "Enter a folder first"
+		return
+	}
+
+	name := filepath.Base(path)
+	if err := data.AddExportDestination(name, path); err != nil {
+		a.errorMsg = err.Error()
+		return
+	}
+
+	a.exportDests = append(data.ExportDestinations(), data.ExportDestination{
+		Name:     "Custom folder",
+		Location: data.PathLocation,
+	})
+	for i, destination := range a.exportDests {
+		if destination.Name == name && destination.Path == path {
+			a.exportDest = i
+		}
+	}
+	a.moveExportFocus(0)
+	a.successMsg = "Saved " + path + " as destination \"" + name + "\""
+}
+
+func (a *App) exportSelection() (string, error) {
+	destination := a.exportDests[a.exportDest]
+	if a.customExportDestination() {
+		destination.Path = a.exportPathInput.Value()
+	}
+
+	target, err := destination.Target()
+	if err != nil {
+		return "", err
+	}
+	target.Name = strings.TrimSpace(a.exportName.Value())
+	target.Policy = a.exportPolicy
+
+	if a.exportAll {
+		return data.ExportTablesAs(a.exportTableIDs, a.config.Username, target, a.exportFormat)
+	}
+	return data.ExportTablesAs([]uint{a.currentTable.ID}, a.config.Username, target, a.exportFormat)
+}
+
+func (a *App) leaveExportScreen() {
+	a.goBack()
+}
+
+func nextExportFormat(current data.ExportFormat) data.ExportFormat {
+	for i, format := range data.ExportFormats {
+		if format == current {
+			return data.ExportFormats[(i+1)%len(data.ExportFormats)]
+		}
+	}
+	return data.ExportFormats[0]
+}
+
+func previousExportFormat(current data.ExportFormat) data.ExportFormat {
+	count := len(data.ExportFormats)
+	for i, format := range data.ExportFormats {
+		if format == current {
+			return data.ExportFormats[(i+count-1)%count]
+		}
+	}
+	return data.ExportFormats[0]
+}
+
 func (a *App) viewExportScreen() string {
-	title := Title.Render("Export Table")
-	subtitle := Subtitle.Render(a.currentTable.Name)
+	var title, subtitle, exportInfo string
+	if a.exportAll && len(a.exportTableIDs) > 0 {
+		title = Title.Render("Export Marked Tables")
+		subtitle = Subtitle.Render(a.config.Username)
+		exportInfo = BoxStyle.Render(
+			Normal.Render(fmt.Sprintf("Exporting %d of %d tables", len(a.exportTableIDs), len(a.tables))),
+		)
+	} else if a.exportAll {
+		title = Title.Render("Export All Tables")
+		subtitle = Subtitle.Render(a.config.Username)
+		info := fmt.Sprintf("Exporting %d tables", len(a.tables))
+		if a.exportFormat == data.BundleFormat {
+			info += "\n" + Subtle.Render("The bundle includes your settings and restores the whole account.")
+		}
+		exportInfo = BoxStyle.Render(Normal.Render(info))
+	} else {
+		title = Title.Render("Export Table")
+		subtitle = Subtitle.Render(a.currentTable.Name)
+		exportInfo = BoxStyle.Render(
+			Normal.Render(fmt.Sprintf("Exporting table with %d entries", len(a.entries))),
+		)
+	}
+
+	row := func(field int, label, value string) string {
+		if field == a.exportFocus {
+			return Selected.Render(fmt.Sprintf("%-12s", label)) + " " + value
+		}
+		return Unselected.Render(fmt.Sprintf("%-12s", label)) + " " + value
+	}
+	option := func(field int, value string) string {
+		if field == a.exportFocus {
+			return "‹ " + Subtitle.Render(value) + " ›"
+		}
+		return Subtitle.Render(value)
+	}
+
+	destination := a.exportDests[a.exportDest]
+	destinationName := fmt.Sprintf("[%d] %s", a.exportDest+1, destination.Name)
+	if destination.Path != "" {
+		destinationName += Subtle.Render("  " + destination.Path)
+	}
 
-	exportInfo := BoxStyle.Render(
-		Normal.Render(fmt.Sprintf("Exporting table with %d entries", len(a.entries))),
+	rows := []string{
+		row(exportNameField, "File name", a.exportName.View()),
+		row(exportFormatField, "Format", option(exportFormatField, a.exportFormat.String())),
+		row(exportDestinationField, "Destination", option(exportDestinationField, destinationName)),
+	}
+	if a.customExportDestination() {
+		rows = append(rows, row(exportPathField, "Folder", a.exportPathInput.View()))
+	}
+	rows = append(rows,
+		row(exportPolicyField, "If exists", option(exportPolicyField, a.exportPolicy.String())),
+		"",
+		Subtle.Render("Leave the name empty to use the template. Placeholders: {table} {date} {time} {user}"),
 	)
 
-	locationInfo := ""
-	switch a.exportLocation {
-	case DefaultExport:
-		locationInfo = Success.Render("Export location: Default (config folder)")
-	case DesktopExport:
-		locationInfo = Success.Render("Export location: Desktop")
-	case BothExport:
-		locationInfo = Success.Render("Export location: Both config folder and desktop")
-	default:
-		locationInfo = Normal.Render("Select export location: [1] Default  [2] Desktop  [3] Both")
-	}
-
-	help := HelpView(map[string]string{
-		"1-3":    "Select location",
-		"Enter":  "Export table",
-		"Esc":    "Cancel",
-		"Ctrl+C": "Quit",
-	})
+	form := BoxStyle.Render(strings.Join(rows, "\n"))
+
+	keys := []HelpKey{
+		Help("Select field", Keys.Up, Keys.Down),
+		Help("Change option", Keys.Left, Keys.Right),
+		Help("Change format", Keys.NextField),
+		Help("Export", Keys.Confirm),
+	}
+	if a.customExportDestination() {
+		keys = append(keys, Help("Save folder as destination", Keys.Save))
+	}
+	keys = append(keys,
+		Help("Cancel", Keys.Cancel),
+		Help("Quit", Keys.ForceQuit),
+	)
+	help := HelpView(keys...)
 
 	return fmt.Sprintf(
 		"%s\n%s\n\n%s\n\n%s\n\n%s",
 		title,
 		subtitle,
 		exportInfo,
-		locationInfo,
+		form,
 		help,
 	)
 }
@@ -0,0 +1,515 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/s42yt/thighpads/pkg/config"
+	"github.com/s42yt/thighpads/pkg/data"
+)
+
+// pickerItem is a folder or importable file listed in the import file picker
+type pickerItem struct {
+	Name  string
+	Path  string
+	IsDir bool
+}
+
+// pickerPlace is a shortcut to a folder, shown next to the file list
+type pickerPlace struct {
+	Name string
+	Path string
+}
+
+// filePicker holds the state of the file browser on the import screen
+type filePicker struct {
+	dir         string
+	items       []pickerItem
+	cursor      int
+	places      []pickerPlace
+	placeCursor int
+	inPlaces    bool
+	showHidden  bool
+	typing      bool
+	previewPath string
+	preview     *data.ImportPreview
+	previewErr  string
+}
+
+func (a *App) openImportScreen() {
+	a.navigate(ImportScreen)
+	a.importPathInput = TextInputField("Enter path to file or folder")
+	a.importPathInput.Blur()
+	a.importPreview = nil
+	a.importTabular = nil
+
+	a.importPicker = filePicker{places: importPlaces()}
+
+	// Start where the last import came from, or in the exports folder
+	start := ""
+	for _, place := range a.importPicker.places {
+		if isDir(place.Path) {
+			start = place.Path
+			break
+		}
+	}
+	if recent, _ := config.LoadRecentImportDirs(); len(recent) > 0 && isDir(recent[0]) {
+		start = recent[0]
+	}
+	if start == "" {
+		start, _ = os.Getwd()
+	}
+
+	a.loadPickerDir(start)
+}
+
+// importPlaces lists the export folders, saved destinations, the home folder
+// and recently used import locations
+func importPlaces() []pickerPlace {
+	var places []pickerPlace
+
+	if exportPath, err := config.GetExportPath(); err == nil {
+		places = append(places, pickerPlace{Name: "Exports", Path: exportPath})
+	}
+
+	home, _ := os.UserHomeDir()
+
+	// Not config.GetDesktopExportPath, which would create the folder
+	desktopPath := filepath.Join(home, "Desktop", "ThighPads Exports")
+	if exportsConfig, err := config.LoadExportsConfig(); err == nil && exportsConfig.DesktopPath != "" {
+		desktopPath = exportsConfig.DesktopPath
+	}
+	if isDir(desktopPath) {
+		places = append(places, pickerPlace{Name: "Desktop exports", Path: desktopPath})
+	}
+
+	for _, destination := range data.ExportDestinations() {
+		if destination.Location != data.PathLocation {
+			continue
+		}
+		if path, err := data.ExpandHome(destination.Path); err == nil && isDir(path) {
+			places = append(places, pickerPlace{Name: destination.Name, Path: path})
+		}
+	}
+
+	if home != "" {
+		places = append(places, pickerPlace{Name: "Home", Path: home})
+	}
+
+	recent, _ := config.LoadRecentImportDirs()
+	for _, dir := range recent {
+		if isDir(dir) {
+			places = append(places, pickerPlace{Name: "Recent: " + filepath.Base(dir), Path: dir})
+		}
+	}
+
+	return places
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// loadPickerDir lists the folders and importable files in dir, folders first
+func (a *App) loadPickerDir(dir string) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		a.errorMsg = err.Error()
+		return
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		a.errorMsg = err.Error()
+		return
+	}
+
+	var dirs, importable []pickerItem
+	for _, file := range files {
+		if !a.importPicker.showHidden && strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+
+		item := pickerItem{Name: file.Name(), Path: filepath.Join(dir, file.Name())}
+		if file.IsDir() || (file.Type()&os.ModeSymlink != 0 && isDir(item.Path)) {
+			item.IsDir = true
+			dirs = append(dirs, item)
+		} else if data.IsImportableFile(file.Name()) {
+			importable = append(importable, item)
+		}
+	}
+
+	byName := func(items []pickerItem) {
+		sort.Slice(items, func(i, j int) bool {
+			return strings.ToLower(items[i].Name) < strings.ToLower(items[j].Name)
+		})
+	}
+	byName(dirs)
+	byName(importable)
+
+	var items []pickerItem
+	if parent := filepath.Dir(dir); parent != dir {
+		items = append(items, pickerItem{Name: "..", Path: parent, IsDir: true})
+	}
+	items = append(items, dirs...)
+	items = append(items, importable...)
+
+	// Keep the cursor on the folder we came from when going up
+	cursor := 0
+	for i, item := range items {
+		if item.Path == a.importPicker.dir && item.Name != ".." {
+			cursor = i
+		}
+	}
+
+	a.importPicker.dir = dir
+	a.importPicker.items = items
+	a.importPicker.cursor = cursor
+	a.refreshPickerPreview()
+}
+
+func (a *App) selectedPickerItem() (pickerItem, bool) {
+	picker := &a.importPicker
+	if picker.cursor < 0 || picker.cursor >= len(picker.items) {
+		return pickerItem{}, false
+	}
+	return picker.items[picker.cursor], true
+}
+
+// refreshPickerPreview reads the highlighted file for the preview pane. Folders
+// are not previewed since reading a whole vault on every move would be slow.
+func (a *App) refreshPickerPreview() {
+	picker := &a.importPicker
+
+	item, ok := a.selectedPickerItem()
+	if !ok || item.IsDir {
+		picker.previewPath = ""
+		picker.preview = nil
+		picker.previewErr = ""
+		return
+	}
+
+	if item.Path == picker.previewPath {
+		return
+	}
+
+	picker.previewPath = item.Path
+	picker.preview, picker.previewErr = nil, ""
+	preview, err := data.PreviewImport(item.Path)
+	if err != nil {
+		picker.previewErr = err.Error()
+		return
+	}
+	picker.preview = preview
+}
+
+func (a *App) updateImportPicker(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	picker := &a.importPicker
+
+	if picker.typing {
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch {
+			case typed(msg):
+				// Typed into the path field
+			case key.Matches(msg, Keys.Confirm):
+				path, err := data.ExpandHome(strings.TrimSpace(a.importPathInput.Value()))
+				if err != nil || path == "" {
+					return a, nil
+				}
+				picker.typing = false
+				a.importPathInput.Blur()
+				if isDir(path) {
+					a.loadPickerDir(path)
+					return a, nil
+				}
+				return a.startImport(path)
+			case key.Matches(msg, Keys.Cancel):
+				picker.typing = false
+				a.importPathInput.Blur()
+				return a, nil
+			case key.Matches(msg, Keys.ForceQuit):
+				return a, tea.Quit
+			}
+		}
+
+		a.importPathInput, cmd = a.importPathInput.Update(msg)
+		return a, cmd
+	}
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return a, nil
+	}
+
+	if picker.inPlaces {
+		switch {
+		case key.Matches(keyMsg, Keys.Up):
+			if picker.placeCursor > 0 {
+				picker.placeCursor--
+			}
+		case key.Matches(keyMsg, Keys.Down):
+			if picker.placeCursor < len(picker.places)-1 {
+				picker.placeCursor++
+			}
+		case key.Matches(keyMsg, Keys.Select, Keys.Right):
+			if len(picker.places) > 0 {
+				picker.inPlaces = false
+				a.loadPickerDir(picker.places[picker.placeCursor].Path)
+			}
+		case key.Matches(keyMsg, Keys.NextField):
+			picker.inPlaces = false
+		case key.Matches(keyMsg, Keys.Cancel):
+			a.goBack()
+		case key.Matches(keyMsg, Keys.ForceQuit):
+			return a, tea.Quit
+		}
+		return a, nil
+	}
+
+	switch {
+	case key.Matches(keyMsg, Keys.Up):
+		if picker.cursor > 0 {
+			picker.cursor--
+			a.refreshPickerPreview()
+		}
+	case key.Matches(keyMsg, Keys.Down):
+		if picker.cursor < len(picker.items)-1 {
+			picker.cursor++
+			a.refreshPickerPreview()
+		}
+	case key.Matches(keyMsg, Keys.Top):
+		picker.cursor = 0
+		a.refreshPickerPreview()
+	case key.Matches(keyMsg, Keys.Bottom):
+		picker.cursor = len(picker.items) - 1
+		a.refreshPickerPreview()
+	case key.Matches(keyMsg, Keys.Left, Keys.Parent):
+		a.loadPickerDir(filepath.Dir(picker.dir))
+	case key.Matches(keyMsg, Keys.Select, Keys.Right):
+		item, ok := a.selectedPickerItem()
+		if !ok {
+			return a, nil
+		}
+		if item.IsDir {
+			a.loadPickerDir(item.Path)
+			return a, nil
+		}
+		if key.Matches(keyMsg, Keys.Select) {
+			return a.startImport(item.Path)
+		}
+	case key.Matches(keyMsg, Keys.ImportFolder):
+		// Import the highlighted folder itself, e.g. an Obsidian vault
+		if item, ok := a.selectedPickerItem(); ok && item.IsDir && item.Name != ".." {
+			return a.startImport(item.Path)
+		}
+	case key.Matches(keyMsg, Keys.HiddenFiles):
+		picker.showHidden = !picker.showHidden
+		a.loadPickerDir(picker.dir)
+	case key.Matches(keyMsg, Keys.NextField):
+		if len(picker.places) > 0 {
+			picker.inPlaces = true
+		}
+	case key.Matches(keyMsg, Keys.TypePath):
+		picker.typing = true
+		a.importPathInput.SetValue(picker.dir + string(filepath.Separator))
+		a.importPathInput.CursorEnd()
+		a.importPathInput.Focus()
+	case key.Matches(keyMsg, Keys.Cancel):
+		a.goBack()
+	case key.Matches(keyMsg, Keys.ForceQuit):
+		return a, tea.Quit
+	}
+
+	return a, nil
+}
+
+// startImport reads path and continues with the column mapping for tabular
+// files or the import preview for everything else
+func (a *App) startImport(path string) (tea.Model, tea.Cmd) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		a.errorMsg = "File does not exist"
+		return a, nil
+	}
+
+	if data.IsTabularFile(path) {
+		tabular, err := data.ReadTabularFile(path)
+		if err != nil {
+			a.errorMsg = err.Error()
+			return a, nil
+		}
+
+		a.importTabular = tabular
+		a.importMapping = tabular.GuessMapping()
+		a.importField = 0
+		return a, nil
+	}
+
+	preview, err := data.PreviewImport(path)
+	if err != nil {
+		a.errorMsg = err.Error()
+		return a, nil
+	}
+
+	a.importPreview = preview
+	return a, nil
+}
+
+// rememberImportDir records the folder an import came from as a recent location
+func rememberImportDir(path string) {
+	dir := path
+	if !isDir(dir) {
+		dir = filepath.Dir(path)
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		config.AddRecentImportDir(abs)
+	}
+}
+
+func (a *App) viewImportPicker(title string) string {
+	picker := &a.importPicker
+
+	rows := a.height - 16
+	if rows < 5 {
+		rows = 5
+	}
+
+	placesWidth := 24
+	previewWidth := 36
+	wide := a.width >= 110
+	filesWidth := a.width - placesWidth - 14
+	if wide {
+		filesWidth -= previewWidth + 3
+	}
+	if filesWidth < 20 {
+		filesWidth = 20
+	}
+
+	// Places
+	var placeLines []string
+	placeLines = append(placeLines, Subtitle.Render("Places"))
+	for i, place := range picker.places {
+		name := truncateString(place.Name, placesWidth-2)
+		if picker.inPlaces && i == picker.placeCursor {
+			placeLines = append(placeLines, Selected.Render(name))
+		} else {
+			placeLines = append(placeLines, Unselected.Render(name))
+		}
+	}
+	places := lipgloss.NewStyle().Width(placesWidth).Render(strings.Join(placeLines, "\n"))
+
+	// Files
+	offset := 0
+	if picker.cursor >= rows {
+		offset = picker.cursor - rows + 1
+	}
+
+	var fileLines []string
+	fileLines = append(fileLines, Subtitle.Render(truncateString(picker.dir, filesWidth)))
+	if len(picker.items) == 0 {
+		fileLines = append(fileLines, Subtle.Render("No folders or importable files here"))
+	}
+	for i := offset; i < len(picker.items) && i < offset+rows; i++ {
+		item := picker.items[i]
+		name := item.Name
+		if item.IsDir {
+			name += string(filepath.Separator)
+		}
+		name = truncateString(name, filesWidth-2)
+
+		if !picker.inPlaces && i == picker.cursor {
+			fileLines = append(fileLines, Selected.Render(name))
+		} else {
+			fileLines = append(fileLines, Unselected.Render(name))
+		}
+	}
+	files := lipgloss.NewStyle().Width(filesWidth).Render(strings.Join(fileLines, "\n"))
+
+	preview := a.viewPickerPreview(previewWidth)
+
+	var content string
+	if wide {
+		content = lipgloss.JoinHorizontal(lipgloss.Top, places, "   ", files, "   ", preview)
+	} else {
+		content = lipgloss.JoinHorizontal(lipgloss.Top, places, "   ", files) + "\n\n" + preview
+	}
+
+	if picker.typing {
+		content += "\n\n" + Normal.Render("Go to: ") + a.importPathInput.View()
+	}
+
+	help := HelpView(
+		Help("Navigate", Keys.Up, Keys.Down),
+		Help("Open/Preview import", Keys.Select),
+		Help("Parent folder", Keys.Left),
+		Help("Import folder", Keys.ImportFolder),
+		Help("Places", Keys.NextField),
+		Help("Type a path", Keys.TypePath),
+		Help("Hidden files", Keys.HiddenFiles),
+		Help("Cancel", Keys.Cancel),
+	)
+	if picker.typing {
+		help = HelpView(
+			Help("Go to path", Keys.Confirm),
+			Help("Back to browser", Keys.Cancel),
+		)
+	}
+
+	return fmt.Sprintf(
+		"%s\n\n%s\n\n%s",
+		title,
+		BoxStyle.Render(content),
+		help,
+	)
+}
+
+// viewPickerPreview summarises the highlighted file: table name, author,
+// export date and entry count
+func (a *App) viewPickerPreview(width int) string {
+	picker := &a.importPicker
+	style := lipgloss.NewStyle().Width(width)
+
+	item, ok := a.selectedPickerItem()
+	switch {
+	case !ok:
+		return style.Render("")
+	case item.IsDir && item.Name == "..":
+		return style.Render(Subtle.Render("Parent folder"))
+	case item.IsDir:
+		return style.Render(Subtle.Render("Folder. Press Enter to open it or 'i' to import it as notes, an Obsidian vault or a Joplin export."))
+	case picker.previewErr != "":
+		return style.Render(Error.Render(picker.previewErr))
+	case picker.preview == nil:
+		return style.Render("")
+	}
+
+	preview := picker.preview
+	lines := []string{Subtitle.Render(preview.Source.String())}
+
+	field := func(label, value string) {
+		if value != "" {
+			lines = append(lines, Subtle.Render(fmt.Sprintf("%-9s", label))+Normal.Render(value))
+		}
+	}
+
+	if len(preview.Tables) == 1 {
+		field("Table", preview.Tables[0].Name)
+		field("Author", preview.Tables[0].Author)
+	} else {
+		field("Tables", fmt.Sprintf("%d", len(preview.Tables)))
+	}
+	if !preview.ExportedAt.IsZero() {
+		field("Exported", preview.ExportedAt.Format("Jan 02, 2006 15:04"))
+	}
+	field("By", preview.ExportedBy)
+	field("Entries", fmt.Sprintf("%d", preview.EntryCount()))
+
+	return style.Render(strings.Join(lines, "\n"))
+}
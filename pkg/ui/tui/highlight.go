@@ -0,0 +1,352 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/s42yt/thighpads/pkg/data"
+)
+
+// codeKind is what a part of a line of code is highlighted as
+type codeKind int
+
+const (
+	codeKeyword codeKind = iota + 1
+	codeString
+	codeNumber
+	codeComment
+	codeFence
+)
+
+func (k codeKind) style() lipgloss.Style {
+	switch k {
+	case codeKeyword:
+		return CodeKeyword
+	case codeString:
+		return CodeString
+	case codeNumber:
+		return CodeNumber
+	case codeComment:
+		return CodeComment
+	}
+	return CodeFence
+}
+
+// codeSpan is a highlighted part of a line, by byte offsets
+type codeSpan struct {
+	start, end int
+	kind       codeKind
+}
+
+// syntax describes just enough of a language to highlight its keywords,
+// strings, numbers and comments
+type syntax struct {
+	keywords     map[string]bool
+	ignoreCase   bool
+	lineComments []string
+	blockComment [2]string
+	// quotes start strings that end on the same line, multiline ones that
+	// may span lines. Backslashes escape the next character except in raw
+	// strings.
+	quotes    string
+	multiline []string
+	raw       string
+}
+
+// codeState carries a block comment or string that is still open at the end
+// of a line over to the next one
+type codeState struct {
+	close string
+	kind  codeKind
+}
+
+func words(list string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.Fields(list) {
+		set[word] = true
+	}
+	return set
+}
+
+var cKeywords = "auto break case char const continue default do double else enum extern float for goto if inline int long register return short signed sizeof static struct switch typedef union unsigned void volatile while NULL true false bool "
+
+var jsKeywords = "async await break case catch class const continue debugger default delete do else export extends false finally for from function if import in instanceof let new null of return static super switch this throw true try typeof undefined var void while yield "
+
+var syntaxes = map[string]*syntax{
+	"go": {
+		keywords: words("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var " +
+			"true false nil iota any bool byte error int int8 int16 int32 int64 uint uint8 uint16 uint32 uint64 uintptr float32 float64 rune string " +
+			"append cap close copy delete len make max min new panic print println recover"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+		multiline:    []string{"`"},
+		raw:          "`",
+	},
+	"python": {
+		keywords: words("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield " +
+			"True False None self print len range str int float list dict set tuple"),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		multiline:    []string{`"""`, "'''"},
+	},
+	"javascript": {
+		keywords:     words(jsKeywords),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+		multiline:    []string{"`"},
+	},
+	"typescript": {
+		keywords:     words(jsKeywords + "abstract any as boolean declare enum implements interface keyof namespace never number private protected public readonly string type unknown"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+		multiline:    []string{"`"},
+	},
+	"shell": {
+		keywords:     words("if then else elif fi for while until do done case esac in function select return exit break continue local export readonly declare unset source alias set shift trap true false"),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	},
+	"sql": {
+		keywords: words("select from where and or not in is null like between join inner left right outer full cross on as group by order having limit offset union all distinct " +
+			"insert into values update set delete create alter drop table index view primary key foreign references unique default check constraint " +
+			"begin commit rollback transaction case when then else end exists asc desc count sum avg min max with returning true false"),
+		ignoreCase:   true,
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `'"`,
+	},
+	"rust": {
+		keywords: words("as async await break const continue crate dyn else enum extern false fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while " +
+			"bool char str String i8 i16 i32 i64 i128 isize u8 u16 u32 u64 u128 usize f32 f64 Option Some None Result Ok Err Vec Box"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"`,
+	},
+	"c": {
+		keywords:     words(cKeywords),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	},
+	"cpp": {
+		keywords: words(cKeywords + "auto class constexpr delete explicit friend mutable namespace new noexcept nullptr operator override private protected public " +
+			"template this throw try catch typename using virtual std string vector"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	},
+	"java": {
+		keywords: words("abstract assert boolean break byte case catch char class const continue default do double else enum extends final finally float for if implements import instanceof int interface long native new package private protected public return short static super switch synchronized this throw throws transient try var void volatile while " +
+			"true false null String"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+		multiline:    []string{`"""`},
+	},
+	"csharp": {
+		keywords:     words("abstract as async await base bool break case catch char class const continue decimal default delegate do double else enum event explicit false finally float for foreach if implicit in int interface internal is lock long namespace new null object operator out override params private protected public readonly ref return sealed static string struct switch this throw true try typeof uint ulong using var virtual void while"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	},
+	"ruby": {
+		keywords:     words("alias and begin break case class def do else elsif end ensure false for if in module next nil not or redo require rescue retry return self super then true undef unless until when while yield puts attr_accessor attr_reader"),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	},
+	"json": {
+		keywords: words("true false null"),
+		quotes:   `"`,
+	},
+	"yaml": {
+		keywords:     words("true false null yes no on off"),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	},
+	"toml": {
+		keywords:     words("true false"),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		multiline:    []string{`"""`, "'''"},
+	},
+}
+
+// entryCode highlights the code of an entry: its fenced code blocks or, when
+// there are none, all of it if the entry has a language. Untagged blocks are
+// taken to be in the language of the entry.
+func entryCode(lines []string, content, language string) [][]codeSpan {
+	spans := make([][]codeSpan, len(lines))
+	language, _ = data.EntryLanguage(language, content)
+
+	blocks := data.CodeBlocks(content)
+	if len(blocks) == 0 {
+		highlightLines(lines, syntaxes[language], spans)
+		return spans
+	}
+
+	for _, block := range blocks {
+		if block.Start >= len(lines) {
+			break
+		}
+		spans[block.Start] = []codeSpan{{0, len(lines[block.Start]), codeFence}}
+		end := min(block.End, len(lines))
+		if end < len(lines) {
+			spans[end] = []codeSpan{{0, len(lines[end]), codeFence}}
+		}
+
+		blockLanguage := block.Language
+		if blockLanguage == "" {
+			blockLanguage = language
+		}
+		highlightLines(lines[block.Start+1:end], syntaxes[blockLanguage], spans[block.Start+1:end])
+	}
+	return spans
+}
+
+func highlightLines(lines []string, s *syntax, spans [][]codeSpan) {
+	if s == nil {
+		return
+	}
+	var state codeState
+	for i, line := range lines {
+		spans[i] = s.highlight(line, &state)
+	}
+}
+
+// highlight splits a line into spans, continuing a comment or string left
+// open by the previous line
+func (s *syntax) highlight(line string, state *codeState) []codeSpan {
+	var spans []codeSpan
+	i := 0
+	if state.close != "" {
+		end, closed := s.closing(line, 0, state.close)
+		spans = append(spans, codeSpan{0, end, state.kind})
+		if !closed {
+			return spans
+		}
+		state.close = ""
+		i = end
+	}
+
+	for i < len(line) {
+		rest := line[i:]
+
+		if s.lineComment(line, i) {
+			return append(spans, codeSpan{i, len(line), codeComment})
+		}
+
+		if open := s.blockComment[0]; open != "" && strings.HasPrefix(rest, open) {
+			end, closed := s.closing(line, i+len(open), s.blockComment[1])
+			spans = append(spans, codeSpan{i, end, codeComment})
+			if !closed {
+				*state = codeState{close: s.blockComment[1], kind: codeComment}
+			}
+			i = end
+			continue
+		}
+
+		if delim := s.multilineAt(rest); delim != "" {
+			end, closed := s.closing(line, i+len(delim), delim)
+			spans = append(spans, codeSpan{i, end, codeString})
+			if !closed {
+				*state = codeState{close: delim, kind: codeString}
+			}
+			i = end
+			continue
+		}
+
+		c := line[i]
+		switch {
+		case strings.IndexByte(s.quotes, c) >= 0:
+			end, _ := s.closing(line, i+1, string(c))
+			spans = append(spans, codeSpan{i, end, codeString})
+			i = end
+		case isDigit(c) && (i == 0 || !isWordByte(line[i-1])):
+			end := i + 1
+			for end < len(line) && (isWordByte(line[end]) || line[end] == '.') {
+				end++
+			}
+			spans = append(spans, codeSpan{i, end, codeNumber})
+			i = end
+		case isWordByte(c):
+			end := i + 1
+			for end < len(line) && isWordByte(line[end]) {
+				end++
+			}
+			word := line[i:end]
+			if s.ignoreCase {
+				word = strings.ToLower(word)
+			}
+			if s.keywords[word] {
+				spans = append(spans, codeSpan{i, end, codeKeyword})
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+	return spans
+}
+
+// lineComment tells whether a line comment starts at i. A # only starts one
+// at the start of a line or after a space, so that e.g. ${#list} in shell
+// scripts is not taken for one.
+func (s *syntax) lineComment(line string, i int) bool {
+	for _, start := range s.lineComments {
+		if strings.HasPrefix(line[i:], start) {
+			return start != "#" || i == 0 || line[i-1] == ' ' || line[i-1] == '\t'
+		}
+	}
+	return false
+}
+
+func (s *syntax) multilineAt(text string) string {
+	for _, delim := range s.multiline {
+		if strings.HasPrefix(text, delim) {
+			return delim
+		}
+	}
+	return ""
+}
+
+// closing finds the delimiter that closes a string or comment from offset
+// from on and returns the offset just after it, or the end of the line and
+// false if the line does not close it
+func (s *syntax) closing(line string, from int, delim string) (int, bool) {
+	for i := from; i < len(line); i++ {
+		if line[i] == '\\' && delim != s.raw && delim != s.blockComment[1] {
+			i++
+			continue
+		}
+		if strings.HasPrefix(line[i:], delim) {
+			return i + len(delim), true
+		}
+	}
+	return len(line), false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || isDigit(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// writeCode writes line[from:to], styling the parts covered by spans
+func writeCode(b *strings.Builder, line string, from, to int, spans []codeSpan) {
+	pos := from
+	for _, span := range spans {
+		start, end := max(span.start, pos), min(span.end, to)
+		if start >= end {
+			continue
+		}
+		b.WriteString(line[pos:start])
+		b.WriteString(span.kind.style().Render(line[start:end]))
+		pos = end
+	}
+	b.WriteString(line[pos:to])
+}
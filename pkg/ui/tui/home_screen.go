@@ -3,6 +3,8 @@ package tui
 import (
 	"fmt"
 
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
@@ -11,31 +13,55 @@ func (a *App) updateHomeScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "n":
-			a.screen = NewTableScreen
-			a.tableNameInput = TextInputField("Enter table name")
-			return a, nil
-		case "i":
-			a.screen = ImportScreen
-			a.importPathInput = TextInputField("Enter path to .thighpad file")
-			return a, nil
-		case "q", "ctrl+c":
-			return a, tea.Quit
-		case "enter":
+		// While filtering, keys are typed into the filter
+		if a.list.FilterState() == list.Filtering {
+			break
+		}
+
+		switch {
+		case key.Matches(msg, Keys.Select):
 			if len(a.tables) > 0 {
 				selected, ok := a.list.SelectedItem().(Selectable)
 				if ok {
 					for _, table := range a.tables {
 						if table.ID == selected.ID {
-							a.currentTable = table
-							a.screen = TableScreen
-							a.loadEntries()
+							a.openTable(table)
 							return a, nil
 						}
 					}
 				}
 			}
+		case key.Matches(msg, Keys.Mark):
+			if len(a.tables) > 0 {
+				a.toggleTableMark()
+				return a, nil
+			}
+		case key.Matches(msg, Keys.New):
+			a.openNewTableScreen()
+			return a, nil
+		case key.Matches(msg, Keys.Import):
+			a.openImportScreen()
+			return a, nil
+		case key.Matches(msg, Keys.Export):
+			if len(a.tables) > 0 {
+				a.exportTableIDs = a.markedTableIDs()
+				a.openExportScreen(true)
+				return a, nil
+			}
+		case key.Matches(msg, Keys.Sync):
+			a.openSyncScreen()
+			return a, nil
+		case key.Matches(msg, Keys.Theme):
+			a.openThemeScreen()
+			return a, nil
+		case key.Matches(msg, Keys.Back) && len(a.history) > 0 && a.list.FilterState() == list.Unfiltered:
+			a.goBack()
+			return a, nil
+		case key.Matches(msg, Keys.Forward):
+			a.goForward()
+			return a, nil
+		case key.Matches(msg, Keys.Quit):
+			return a, tea.Quit
 		}
 	}
 
@@ -46,29 +72,79 @@ func (a *App) updateHomeScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return a, cmd
 }
 
+// toggleTableMark marks or unmarks the selected table for a multi-table export
+func (a *App) toggleTableMark() {
+	selected, ok := a.list.SelectedItem().(Selectable)
+	if !ok {
+		return
+	}
+
+	selected.Marked = !selected.Marked
+	if selected.Marked {
+		a.markedTables[selected.ID] = true
+	} else {
+		delete(a.markedTables, selected.ID)
+	}
+	a.list.SetItem(a.list.Index(), selected)
+}
+
+// markedTableIDs returns the marked tables in list order, or nil if none are
+// marked so that everything gets exported
+func (a *App) markedTableIDs() []uint {
+	var ids []uint
+	for _, table := range a.tables {
+		if a.markedTables[table.ID] {
+			ids = append(ids, table.ID)
+		}
+	}
+	return ids
+}
+
 func (a *App) viewHomeScreen() string {
 	title := Title.Copy().Width(a.width - 4).Render("ThighPads")
 	subtitle := Subtitle.Copy().Width(a.width - 4).Render(fmt.Sprintf("Welcome, %s", a.config.Username))
+	if summary := a.syncSummary(); summary != "" {
+		subtitle += "\n" + summary
+	}
+
+	exportHelp := "Export all"
+	if len(a.markedTables) > 0 {
+		exportHelp = fmt.Sprintf("Export %d marked", len(a.markedTables))
+	}
+
+	backHelp := HelpKey{}
+	if len(a.history) > 0 {
+		backHelp = Help("Back", Keys.Back)
+	}
+
+	help := HelpView(
+		Help("Navigate", Keys.Up, Keys.Down),
+		Help("Select table", Keys.Select),
+		Help("Mark table", Keys.Mark),
+		Help("New table", Keys.New),
+		Help("Import table", Keys.Import),
+		Help(exportHelp, Keys.Export),
+		Help("Sync", Keys.Sync),
+		Help("Theme", Keys.Theme),
+		Help("Commands", Keys.Palette),
+		backHelp,
+		a.forwardHelp(),
+		Help("Quit", Keys.Quit),
+	)
+
+	header := title + "\n" + subtitle
 
 	var content string
 	if len(a.tables) == 0 {
-		content = BoxStyle.Copy().Width(a.width - 4).Render(Normal.Render("You don't have any tables yet. Press 'n' to create your first one."))
+		content = BoxStyle.Copy().Width(a.width - 4).Render(Normal.Render(fmt.Sprintf("You don't have any tables yet. Press '%s' to create your first one.", KeyName(Keys.New))))
 	} else {
+		a.list.SetHeight(a.contentHeight(header, help))
 		content = BoxStyle.Copy().Width(a.width - 4).Render(a.list.View())
 	}
 
-	help := HelpView(map[string]string{
-		"↑/↓":   "Navigate",
-		"Enter": "Select table",
-		"n":     "New table",
-		"i":     "Import table",
-		"q":     "Quit",
-	})
-
 	return fmt.Sprintf(
-		"%s\n%s\n\n%s\n\n%s",
-		title,
-		subtitle,
+		"%s\n\n%s\n\n%s",
+		header,
 		content,
 		help,
 	)
@@ -2,76 +2,242 @@ package tui
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/s42yt/thighpads/pkg/config"
 	"github.com/s42yt/thighpads/pkg/data"
 )
 
 func (a *App) updateImportScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var cmd tea.Cmd
+	if a.importPreview != nil {
+		return a.updateImportPreview(msg)
+	}
+
+	if a.importTabular != nil {
+		return a.updateImportMapping(msg)
+	}
+
+	return a.updateImportPicker(msg)
+}
 
+func (a *App) updateImportPreview(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyEnter:
-			if a.importPathInput.Value() != "" {
-				path := a.importPathInput.Value()
-
-				if _, err := os.Stat(path); os.IsNotExist(err) {
-					a.errorMsg = "File does not exist"
-					return a, nil
-				}
+		switch {
+		case key.Matches(msg, Keys.Confirm):
+			restoreConfig := a.importPreview.RestoreConfig
+			path := a.importPreview.Path
+			count, err := a.importPreview.Import(a.config.Username)
+			a.importPreview = nil
+			if err != nil {
+				a.errorMsg = err.Error()
+				return a, nil
+			}
 
-				if !strings.HasSuffix(path, data.FileExtension) {
-					a.errorMsg = "File must have " + data.FileExtension + " extension"
-					return a, nil
-				}
+			rememberImportDir(path)
 
-				err := data.ImportFile(path, a.config.Username)
-				if err != nil {
-					a.errorMsg = err.Error()
-					return a, nil
+			if restoreConfig {
+				if cfg, err := config.LoadConfig(); err == nil {
+					a.config = cfg
 				}
+			}
 
-				a.screen = HomeScreen
-				a.loadTables()
+			a.importTabular = nil
+			a.navigate(HomeScreen)
+			a.loadTables()
+			if count == 1 {
 				a.successMsg = "Table imported successfully."
-				return a, nil
+			} else {
+				a.successMsg = fmt.Sprintf("%d tables imported successfully.", count)
 			}
-		case tea.KeyEsc:
-			a.screen = HomeScreen
 			return a, nil
-		case tea.KeyCtrlC:
+		case key.Matches(msg, Keys.Cancel):
+			// Going back from a CSV preview returns to the column mapping
+			a.importPreview = nil
+			return a, nil
+		case key.Matches(msg, Keys.Merge):
+			if len(a.importPreview.Tables) == 1 {
+				a.openMergeScreen()
+				return a, nil
+			}
+		case key.Matches(msg, Keys.RestoreSettings):
+			if len(a.importPreview.ConfigFiles) > 0 {
+				a.importPreview.RestoreConfig = !a.importPreview.RestoreConfig
+				return a, nil
+			}
+		case key.Matches(msg, Keys.ForceQuit):
 			return a, tea.Quit
 		}
 	}
 
-	a.importPathInput, cmd = a.importPathInput.Update(msg)
-	return a, cmd
+	return a, nil
 }
 
 func (a *App) viewImportScreen() string {
 	title := Title.Render("Import Table")
 
-	importInput := BoxStyle.Render(
-		fmt.Sprintf("%s\n\n%s",
-			Normal.Render("Enter path to .thighpad file:"),
-			a.importPathInput.View(),
-		),
+	if a.importPreview != nil {
+		return a.viewImportPreview(title)
+	}
+
+	if a.importTabular != nil {
+		return a.viewImportMapping(title)
+	}
+
+	return a.viewImportPicker(title)
+}
+
+func (a *App) viewImportPreview(title string) string {
+	preview := a.importPreview
+
+	summary := fmt.Sprintf("%s\n%s\n\n%s",
+		Subtitle.Render(preview.Source.String()),
+		Subtle.Render(preview.Path),
+		Normal.Render(fmt.Sprintf("The following will be created (%d tables, %d entries):",
+			len(preview.Tables), preview.EntryCount())),
+	)
+
+	maxLines := a.height - 18
+	if maxLines < 3 {
+		maxLines = 3
+	}
+
+	var lines []string
+	for i, table := range preview.Tables {
+		if i == maxLines && len(preview.Tables) > maxLines+1 {
+			lines = append(lines, Subtle.Render(fmt.Sprintf("  ... and %d more tables", len(preview.Tables)-i)))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("  %s %s",
+			Normal.Render(table.Name),
+			Subtle.Render(fmt.Sprintf("(%d entries)", len(table.Entries)))))
+	}
+
+	if len(preview.ConfigFiles) > 0 {
+		lines = append(lines, "")
+		if preview.RestoreConfig {
+			lines = append(lines, Warning.Render("Settings from the bundle will replace your current settings."))
+		} else {
+			lines = append(lines, Subtle.Render(fmt.Sprintf("The bundle also contains settings, press '%s' to restore them.", KeyName(Keys.RestoreSettings))))
+		}
+	}
+
+	content := BoxStyle.Render(summary + "\n\n" + strings.Join(lines, "\n"))
+
+	keys := []HelpKey{Help("Import as new table", Keys.Confirm)}
+	if len(preview.Tables) == 1 {
+		keys = append(keys, Help("Merge into existing table", Keys.Merge))
+	}
+	if len(preview.ConfigFiles) > 0 {
+		keys = append(keys, Help("Toggle restoring settings", Keys.RestoreSettings))
+	}
+	keys = append(keys,
+		Help("Back", Keys.Cancel),
+		Help("Quit", Keys.ForceQuit),
+	)
+	help := HelpView(keys...)
+
+	return fmt.Sprintf(
+		"%s\n\n%s\n\n%s",
+		title,
+		content,
+		help,
 	)
+}
+
+func (a *App) updateImportMapping(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, Keys.Up, Keys.PrevField):
+			a.importField = (a.importField + len(data.EntryFields) - 1) % len(data.EntryFields)
+		case key.Matches(msg, Keys.Down, Keys.NextField):
+			a.importField = (a.importField + 1) % len(data.EntryFields)
+		case key.Matches(msg, Keys.Left):
+			a.cycleImportColumn(-1)
+		case key.Matches(msg, Keys.Right):
+			a.cycleImportColumn(1)
+		case key.Matches(msg, Keys.Confirm):
+			preview, err := a.importTabular.Preview(a.importMapping)
+			if err != nil {
+				a.errorMsg = err.Error()
+				return a, nil
+			}
+			a.importPreview = preview
+		case key.Matches(msg, Keys.Cancel):
+			a.importTabular = nil
+		case key.Matches(msg, Keys.ForceQuit):
+			return a, tea.Quit
+		}
+	}
+
+	return a, nil
+}
 
-	help := HelpView(map[string]string{
-		"Enter":  "Import table",
-		"Esc":    "Cancel",
-		"Ctrl+C": "Quit",
-	})
+// cycleImportColumn moves the selected field's source column by delta, with
+// "not mapped" sitting before the first column.
+func (a *App) cycleImportColumn(delta int) {
+	field := data.EntryFields[a.importField]
+	columns := a.importTabular.Columns
+
+	current := -1
+	for i, column := range columns {
+		if column == a.importMapping[field] {
+			current = i
+			break
+		}
+	}
+
+	next := (current + 1 + delta + len(columns) + 1) % (len(columns) + 1)
+	if next == 0 {
+		delete(a.importMapping, field)
+	} else {
+		a.importMapping[field] = columns[next-1]
+	}
+}
+
+func (a *App) viewImportMapping(title string) string {
+	header := fmt.Sprintf("%s\n%s\n\n%s",
+		Subtitle.Render("Map columns"),
+		Subtle.Render(a.importTabular.Path),
+		Normal.Render(fmt.Sprintf("%d rows found. Choose which column fills each field:", len(a.importTabular.Rows))),
+	)
+
+	var lines []string
+	for i, field := range data.EntryFields {
+		column := a.importMapping[field]
+		value := Subtle.Render("(not mapped)")
+		if column != "" {
+			value = Normal.Render(column)
+			if sample := a.importTabular.Rows[0][column]; sample != "" {
+				value += Subtle.Render("  e.g. " + truncateString(strings.ReplaceAll(sample, "\n", " "), 30))
+			}
+		}
+
+		label := fmt.Sprintf("%-8s", field.String())
+		if i == a.importField {
+			lines = append(lines, Selected.Render(label)+" ‹ "+value+" ›")
+		} else {
+			lines = append(lines, Unselected.Render(label)+"   "+value)
+		}
+	}
+
+	content := BoxStyle.Render(header + "\n\n" + strings.Join(lines, "\n"))
+
+	help := HelpView(
+		Help("Select field", Keys.Up, Keys.Down),
+		Help("Change column", Keys.Left, Keys.Right),
+		Help("Preview import", Keys.Confirm),
+		Help("Back", Keys.Cancel),
+		Help("Quit", Keys.ForceQuit),
+	)
 
 	return fmt.Sprintf(
 		"%s\n\n%s\n\n%s",
 		title,
-		importInput,
+		content,
 		help,
 	)
 }
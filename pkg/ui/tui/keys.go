@@ -0,0 +1,562 @@
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/s42yt/thighpads/pkg/config"
+)
+
+// KeyMap holds every key binding of the TUI. Screens match key presses
+// against it and build their help bar from it, so the help always shows the
+// keys that actually work.
+type KeyMap struct {
+	// Moving around
+	Up       key.Binding
+	Down     key.Binding
+	Left     key.Binding
+	Right    key.Binding
+	Top      key.Binding
+	Bottom   key.Binding
+	PageUp   key.Binding
+	PageDown key.Binding
+	Select   key.Binding
+	Back     key.Binding
+	Forward  key.Binding
+	Quit     key.Binding
+	// Palette opens the command palette on any screen
+	Palette key.Binding
+
+	// Forms and text fields, where letters are typed rather than bound
+	Confirm   key.Binding
+	Cancel    key.Binding
+	ForceQuit key.Binding
+	Save      key.Binding
+	NextField key.Binding
+	PrevField key.Binding
+	// Discard leaves an entry form without saving its changes
+	Discard key.Binding
+
+	// Entry editor
+	Undo        key.Binding
+	Redo        key.Binding
+	FindReplace key.Binding
+	Replace     key.Binding
+	ReplaceAll  key.Binding
+	SoftWrap    key.Binding
+
+	// Tables and entries
+	New         key.Binding
+	Delete      key.Binding
+	Edit        key.Binding
+	Copy        key.Binding
+	Mark        key.Binding
+	Import      key.Binding
+	Export      key.Binding
+	Sync        key.Binding
+	Theme       key.Binding
+	Attachments key.Binding
+	// Preview shows or hides the entry preview next to the list
+	Preview key.Binding
+
+	// Searching an entry
+	Find          key.Binding
+	NextMatch     key.Binding
+	PreviousMatch key.Binding
+	MatchCase     key.Binding
+	UseRegex      key.Binding
+
+	// Attachments screen
+	Attach   key.Binding
+	Paste    key.Binding
+	Open     key.Binding
+	SaveCopy key.Binding
+
+	// Sync screen
+	SyncFolder   key.Binding
+	KeepVersion  key.Binding
+	OtherVersion key.Binding
+
+	// Theme screen
+	Reload key.Binding
+
+	// Import screens
+	Parent          key.Binding
+	ImportFolder    key.Binding
+	HiddenFiles     key.Binding
+	TypePath        key.Binding
+	Merge           key.Binding
+	RestoreSettings key.Binding
+	KeepMine        key.Binding
+	TakeTheirs      key.Binding
+	KeepBoth        key.Binding
+	Next            key.Binding
+	Previous        key.Binding
+}
+
+// Keys is the key map in use, see LoadKeyMap
+var Keys = NewKeyMap(nil)
+
+// defaultBindings are the keys of every binding, by the name used in
+// keymap.json
+var defaultBindings = map[string][]string{
+	"up":       {"up", "k"},
+	"down":     {"down", "j"},
+	"left":     {"left", "h"},
+	"right":    {"right", "l"},
+	"top":      {"home", "g"},
+	"bottom":   {"end", "G"},
+	"pageUp":   {"pgup"},
+	"pageDown": {"pgdown"},
+	"select":   {"enter"},
+	"back":     {"b", "esc"},
+	"forward":  {"f"},
+	"quit":     {"q", "ctrl+c"},
+	"palette":  {"ctrl+p"},
+
+	"confirm":   {"enter"},
+	"cancel":    {"esc"},
+	"forceQuit": {"ctrl+c"},
+	"save":      {"ctrl+s"},
+	"nextField": {"tab"},
+	"prevField": {"shift+tab"},
+	"discard":   {"d"},
+
+	"undo":        {"ctrl+z"},
+	"redo":        {"ctrl+y"},
+	"findReplace": {"ctrl+f"},
+	"replace":     {"ctrl+r"},
+	"replaceAll":  {"alt+a"},
+	"softWrap":    {"alt+z"},
+
+	"new":         {"n"},
+	"delete":      {"d"},
+	"edit":        {"e"},
+	"copy":        {"c"},
+	"mark":        {" "},
+	"import":      {"i"},
+	"export":      {"e"},
+	"sync":        {"s"},
+	"theme":       {"t"},
+	"attachments": {"a"},
+	"preview":     {"p"},
+
+	"find":          {"/"},
+	"nextMatch":     {"n"},
+	"previousMatch": {"N"},
+	"matchCase":     {"alt+c"},
+	"useRegex":      {"alt+r"},
+
+	"attach":   {"a"},
+	"paste":    {"p"},
+	"open":     {"o"},
+	"saveCopy": {"s"},
+
+	"syncFolder":   {"f"},
+	"keepVersion":  {"k"},
+	"otherVersion": {"o"},
+
+	"reload": {"r"},
+
+	"parent":          {"backspace"},
+	"importFolder":    {"i"},
+	"hiddenFiles":     {"."},
+	"typePath":        {"p", "/"},
+	"merge":           {"m"},
+	"restoreSettings": {"c"},
+	"keepMine":        {"1", "m"},
+	"takeTheirs":      {"2", "t"},
+	"keepBoth":        {"3", "b"},
+	"next":            {"n"},
+	"previous":        {"p"},
+}
+
+// keyPresets change some of the default bindings
+var keyPresets = map[string]map[string][]string{
+	"default": {},
+	"vim": {
+		"select":   {"enter", "l"},
+		"back":     {"esc", "h", "b"},
+		"top":      {"g", "home"},
+		"pageUp":   {"ctrl+b", "ctrl+u", "pgup"},
+		"pageDown": {"ctrl+f", "ctrl+d", "pgdown"},
+		"new":      {"n", "o"},
+		"edit":     {"e", "i"},
+		"copy":     {"y", "c"},
+		"delete":   {"d", "x"},
+		"next":     {"n", "]"},
+		"previous": {"N", "["},
+	},
+	"emacs": {
+		"palette":  {"alt+x"},
+		"up":       {"ctrl+p", "up"},
+		"down":     {"ctrl+n", "down"},
+		"left":     {"ctrl+b", "left"},
+		"right":    {"ctrl+f", "right"},
+		"top":      {"alt+<", "home"},
+		"bottom":   {"alt+>", "end"},
+		"pageUp":   {"alt+v", "pgup"},
+		"pageDown": {"ctrl+v", "pgdown"},
+		"back":     {"ctrl+g", "esc", "b"},
+		"find":     {"ctrl+s", "/"},
+		"cancel":   {"ctrl+g", "esc"},
+		"undo":     {"ctrl+_", "ctrl+z"},
+		"copy":     {"alt+w", "c"},
+		"paste":    {"ctrl+y", "p"},
+		"delete":   {"ctrl+d", "d"},
+		"next":     {"alt+n", "n"},
+		"previous": {"alt+p", "p"},
+	},
+}
+
+// keyMapFile is keymap.json in the config folder
+type keyMapFile struct {
+	// Preset is "default", "vim" or "emacs"
+	Preset string `json:"preset"`
+	// Bindings replace the keys of single bindings of the preset
+	Bindings map[string][]string `json:"bindings"`
+}
+
+// LoadKeyMap reads keymap.json and makes it the key map in use. A missing
+// file means the default bindings. Mistakes in the file are skipped and
+// described by the result, as are bindings some screen cannot use because
+// their keys are taken.
+func LoadKeyMap() []string {
+	overrides, problems := readKeyMapFile()
+	Keys = NewKeyMap(overrides)
+	return append(problems, Keys.conflicts()...)
+}
+
+func readKeyMapFile() (map[string][]string, []string) {
+	path, err := config.GetKeymapPath()
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	var file keyMapFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, []string{config.KeymapFileName + ": " + err.Error()}
+	}
+
+	var problems []string
+	if file.Preset == "" {
+		file.Preset = "default"
+	}
+	preset, ok := keyPresets[file.Preset]
+	if !ok {
+		problems = append(problems, fmt.Sprintf("unknown preset %q, using the default keys", file.Preset))
+	}
+
+	overrides := map[string][]string{}
+	for name, keys := range preset {
+		overrides[name] = keys
+	}
+
+	names := make([]string, 0, len(file.Bindings))
+	for name := range file.Bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := defaultBindings[name]; !ok {
+			problems = append(problems, fmt.Sprintf("unknown binding %q", name))
+			continue
+		}
+
+		keys := make([]string, len(file.Bindings[name]))
+		for i, k := range file.Bindings[name] {
+			if k == "space" {
+				k = " "
+			}
+			keys[i] = k
+		}
+		overrides[name] = keys
+	}
+
+	return overrides, problems
+}
+
+// NewKeyMap builds a key map from the default bindings, replacing the keys of
+// the bindings named in overrides. An empty list of keys turns a binding off.
+func NewKeyMap(overrides map[string][]string) KeyMap {
+	var keyMap KeyMap
+	for name, binding := range keyMap.bindings() {
+		keys, ok := overrides[name]
+		if !ok {
+			keys = defaultBindings[name]
+		}
+		*binding = newBinding(keys)
+	}
+	return keyMap
+}
+
+func newBinding(keys []string) key.Binding {
+	if len(keys) == 0 {
+		return key.NewBinding(key.WithDisabled())
+	}
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(keyName(keys[0]), ""))
+}
+
+// bindings maps the names used in keymap.json to the bindings
+func (k *KeyMap) bindings() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"up":       &k.Up,
+		"down":     &k.Down,
+		"left":     &k.Left,
+		"right":    &k.Right,
+		"top":      &k.Top,
+		"bottom":   &k.Bottom,
+		"pageUp":   &k.PageUp,
+		"pageDown": &k.PageDown,
+		"select":   &k.Select,
+		"back":     &k.Back,
+		"forward":  &k.Forward,
+		"quit":     &k.Quit,
+		"palette":  &k.Palette,
+
+		"confirm":   &k.Confirm,
+		"cancel":    &k.Cancel,
+		"forceQuit": &k.ForceQuit,
+		"save":      &k.Save,
+		"nextField": &k.NextField,
+		"prevField": &k.PrevField,
+		"discard":   &k.Discard,
+
+		"undo":        &k.Undo,
+		"redo":        &k.Redo,
+		"findReplace": &k.FindReplace,
+		"replace":     &k.Replace,
+		"replaceAll":  &k.ReplaceAll,
+		"softWrap":    &k.SoftWrap,
+
+		"new":         &k.New,
+		"delete":      &k.Delete,
+		"edit":        &k.Edit,
+		"copy":        &k.Copy,
+		"mark":        &k.Mark,
+		"import":      &k.Import,
+		"export":      &k.Export,
+		"sync":        &k.Sync,
+		"theme":       &k.Theme,
+		"attachments": &k.Attachments,
+		"preview":     &k.Preview,
+
+		"find":          &k.Find,
+		"nextMatch":     &k.NextMatch,
+		"previousMatch": &k.PreviousMatch,
+		"matchCase":     &k.MatchCase,
+		"useRegex":      &k.UseRegex,
+
+		"attach":   &k.Attach,
+		"paste":    &k.Paste,
+		"open":     &k.Open,
+		"saveCopy": &k.SaveCopy,
+
+		"syncFolder":   &k.SyncFolder,
+		"keepVersion":  &k.KeepVersion,
+		"otherVersion": &k.OtherVersion,
+
+		"reload": &k.Reload,
+
+		"parent":          &k.Parent,
+		"importFolder":    &k.ImportFolder,
+		"hiddenFiles":     &k.HiddenFiles,
+		"typePath":        &k.TypePath,
+		"merge":           &k.Merge,
+		"restoreSettings": &k.RestoreSettings,
+		"keepMine":        &k.KeepMine,
+		"takeTheirs":      &k.TakeTheirs,
+		"keepBoth":        &k.KeepBoth,
+		"next":            &k.Next,
+		"previous":        &k.Previous,
+	}
+}
+
+// screenBindings lists the bindings each screen handles, in the order it
+// checks them: when two bindings share a key, the first one gets it.
+var screenBindings = []struct {
+	screen   string
+	bindings []string
+}{
+	{"home", []string{"select", "mark", "new", "import", "export", "sync", "theme", "back", "forward", "quit", "up", "down", "top", "bottom", "pageUp", "pageDown"}},
+	{"table", []string{"select", "new", "delete", "export", "preview", "back", "forward", "quit", "up", "down", "top", "bottom", "pageUp", "pageDown"}},
+	{"entry", []string{"find", "nextMatch", "previousMatch", "matchCase", "useRegex", "cancel", "edit", "copy", "attachments", "back", "forward", "quit", "up", "down", "pageUp", "pageDown"}},
+	{"entry search", []string{"confirm", "cancel", "matchCase", "useRegex", "forceQuit"}},
+	{"copy code", []string{"copy", "confirm", "cancel", "forceQuit"}},
+	{"entry form", []string{"nextField", "save", "cancel", "forceQuit", "undo", "redo", "findReplace", "softWrap"}},
+	{"find and replace", []string{"save", "forceQuit", "cancel", "nextField", "prevField", "findReplace", "confirm", "replace", "replaceAll", "matchCase", "useRegex", "undo", "redo"}},
+	{"unsaved changes", []string{"save", "discard", "cancel", "forceQuit"}},
+	{"attachments", []string{"up", "down", "attach", "paste", "open", "select", "saveCopy", "delete", "back", "forward", "quit"}},
+	{"sync", []string{"sync", "syncFolder", "keepVersion", "otherVersion", "up", "down", "back", "quit"}},
+	{"theme", []string{"up", "down", "reload", "select", "back", "quit"}},
+	{"import", []string{"up", "down", "top", "bottom", "left", "parent", "select", "right", "importFolder", "hiddenFiles", "nextField", "typePath", "cancel", "forceQuit"}},
+	{"import preview", []string{"confirm", "cancel", "merge", "restoreSettings", "forceQuit"}},
+	{"column mapping", []string{"up", "prevField", "down", "nextField", "left", "right", "confirm", "cancel", "forceQuit"}},
+	{"merge", []string{"keepMine", "takeTheirs", "keepBoth", "right", "next", "nextField", "left", "previous", "prevField", "confirm", "cancel", "forceQuit"}},
+	{"export", []string{"up", "prevField", "down", "nextField", "left", "right", "save", "confirm", "cancel", "forceQuit"}},
+	{"command palette", []string{"cancel", "up", "down", "confirm", "forceQuit"}},
+}
+
+// conflicts describes bindings that cannot be used on some screen because
+// every one of their keys is taken by a binding checked before them
+func (k *KeyMap) conflicts() []string {
+	bindings := k.bindings()
+
+	var problems []string
+	for _, screen := range screenBindings {
+		// The palette key is checked before those of any screen
+		taken := map[string]string{}
+		for _, key := range k.Palette.Keys() {
+			taken[key] = "palette"
+		}
+		for _, name := range screen.bindings {
+			binding := bindings[name]
+			if !binding.Enabled() {
+				continue
+			}
+
+			shadowedBy := ""
+			for _, key := range binding.Keys() {
+				owner, ok := taken[key]
+				if !ok {
+					shadowedBy = ""
+					break
+				}
+				shadowedBy = owner
+			}
+			if shadowedBy != "" {
+				problems = append(problems, "on the "+screen.screen+" screen, "+name+" is hidden by "+shadowedBy)
+			}
+
+			for _, key := range binding.Keys() {
+				if _, ok := taken[key]; !ok {
+					taken[key] = name
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// ListKeyMap is the key map of the table and entry lists
+func (k *KeyMap) ListKeyMap() list.KeyMap {
+	keyMap := list.DefaultKeyMap()
+	keyMap.CursorUp = k.Up
+	keyMap.CursorDown = k.Down
+	keyMap.PrevPage = k.PageUp
+	keyMap.NextPage = k.PageDown
+	keyMap.GoToStart = k.Top
+	keyMap.GoToEnd = k.Bottom
+	keyMap.Quit = k.Quit
+	keyMap.ForceQuit = k.ForceQuit
+	return keyMap
+}
+
+// ViewportKeyMap is the key map of scrollable text such as an entry
+func (k *KeyMap) ViewportKeyMap() viewport.KeyMap {
+	keyMap := viewport.DefaultKeyMap()
+	keyMap.Up = k.Up
+	keyMap.Down = k.Down
+	keyMap.PageUp = k.PageUp
+	keyMap.PageDown = k.PageDown
+	keyMap.HalfPageUp = key.NewBinding(key.WithKeys("ctrl+u"))
+	keyMap.HalfPageDown = key.NewBinding(key.WithKeys("ctrl+d"))
+	keyMap.Left = key.NewBinding(key.WithDisabled())
+	keyMap.Right = key.NewBinding(key.WithDisabled())
+	return keyMap
+}
+
+// typed reports whether msg types text, in which case a focused text field
+// should get it rather than a binding
+func typed(msg tea.KeyMsg) bool {
+	return msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace
+}
+
+// keyTypes maps the names of keys that are not letters to their types
+var keyTypes = func() map[string]tea.KeyType {
+	types := map[string]tea.KeyType{}
+	for t := tea.KeyType(-100); t <= 127; t++ {
+		if name := t.String(); name != "" && t != tea.KeyRunes {
+			types[name] = t
+		}
+	}
+	return types
+}()
+
+// keyPress is the message a key press of k sends, given by its name as in
+// key bindings. It lets clicks run the action of a binding.
+func keyPress(k string) (tea.KeyMsg, bool) {
+	alt := false
+	if rest, ok := strings.CutPrefix(k, "alt+"); ok && rest != "" {
+		alt, k = true, rest
+	}
+	if t, ok := keyTypes[k]; ok {
+		return tea.KeyMsg{Type: t, Alt: alt}, true
+	}
+	if runes := []rune(k); len(runes) == 1 {
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: runes, Alt: alt}, true
+	}
+	return tea.KeyMsg{}, false
+}
+
+// keyName is how a key is shown in the help bar and in messages
+func keyName(k string) string {
+	switch k {
+	case "up":
+		return "↑"
+	case "down":
+		return "↓"
+	case "left":
+		return "←"
+	case "right":
+		return "→"
+	case " ":
+		return "Space"
+	case "pgup":
+		return "PgUp"
+	case "pgdown":
+		return "PgDn"
+	case "shift+tab":
+		return "Shift+Tab"
+	}
+
+	// enter -> Enter, ctrl+s -> Ctrl+S, alt+v -> Alt+V
+	if len(k) > 1 {
+		parts := []rune(k)
+		parts[0] = toUpper(parts[0])
+		for i := 1; i < len(parts); i++ {
+			if parts[i-1] == '+' {
+				parts[i] = toUpper(parts[i])
+			}
+		}
+		return string(parts)
+	}
+	return k
+}
+
+func toUpper(r rune) rune {
+	if r >= 'a' && r <= 'z' {
+		return r - 'a' + 'A'
+	}
+	return r
+}
+
+// KeyName is the first key of a binding, for messages such as "press 'd'
+// again"
+func KeyName(binding key.Binding) string {
+	return binding.Help().Key
+}
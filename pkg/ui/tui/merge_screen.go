@@ -0,0 +1,238 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/s42yt/thighpads/pkg/data"
+	"github.com/s42yt/thighpads/pkg/database"
+)
+
+func (a *App) openMergeScreen() {
+	tables, err := database.GetTables()
+	if err != nil {
+		a.errorMsg = err.Error()
+		return
+	}
+
+	if len(tables) == 0 {
+		a.errorMsg = "There are no tables to merge into"
+		return
+	}
+
+	suggested, hasSuggestion := a.importPreview.SuggestMergeTarget()
+
+	items := make([]list.Item, len(tables))
+	selected := 0
+	for i, table := range tables {
+		items[i] = Selectable{
+			Title:       table.Name,
+			Description: fmt.Sprintf("Created by %s on %s", table.Author, table.CreatedAt.Format("Jan 02, 2006")),
+			ID:          table.ID,
+		}
+		if hasSuggestion && table.ID == suggested.ID {
+			selected = i
+		}
+	}
+
+	a.list = SelectableList("Merge into table", items, a.width-4, a.height-12)
+	a.list.Select(selected)
+	a.mergePlan = nil
+	a.mergeIndex = 0
+	a.screen = MergeScreen
+}
+
+func (a *App) updateMergeScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	if a.mergePlan != nil {
+		return a.updateMergeConflicts(msg)
+	}
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, Keys.Select):
+			selected, ok := a.list.SelectedItem().(Selectable)
+			if !ok {
+				return a, nil
+			}
+
+			plan, err := a.importPreview.PlanMerge(selected.ID)
+			if err != nil {
+				a.errorMsg = err.Error()
+				return a, nil
+			}
+
+			a.mergePlan = plan
+			if len(plan.Conflicts) == 0 {
+				return a.applyMerge()
+			}
+
+			a.mergeIndex = 0
+			a.refreshMergeDiff()
+			return a, nil
+		case key.Matches(msg, Keys.Cancel):
+			a.screen = ImportScreen
+			return a, nil
+		case key.Matches(msg, Keys.ForceQuit):
+			return a, tea.Quit
+		}
+	}
+
+	a.list, cmd = a.list.Update(msg)
+	return a, cmd
+}
+
+func (a *App) updateMergeConflicts(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	conflicts := a.mergePlan.Conflicts
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, Keys.KeepMine):
+			conflicts[a.mergeIndex].Resolution = data.KeepMine
+			a.nextMergeConflict()
+			return a, nil
+		case key.Matches(msg, Keys.TakeTheirs):
+			conflicts[a.mergeIndex].Resolution = data.TakeTheirs
+			a.nextMergeConflict()
+			return a, nil
+		case key.Matches(msg, Keys.KeepBoth):
+			conflicts[a.mergeIndex].Resolution = data.KeepBoth
+			a.nextMergeConflict()
+			return a, nil
+		case key.Matches(msg, Keys.Right, Keys.Next, Keys.NextField):
+			a.nextMergeConflict()
+			return a, nil
+		case key.Matches(msg, Keys.Left, Keys.Previous, Keys.PrevField):
+			if a.mergeIndex > 0 {
+				a.mergeIndex--
+				a.refreshMergeDiff()
+			}
+			return a, nil
+		case key.Matches(msg, Keys.Confirm):
+			return a.applyMerge()
+		case key.Matches(msg, Keys.Cancel):
+			a.mergePlan = nil
+			return a, nil
+		case key.Matches(msg, Keys.ForceQuit):
+			return a, tea.Quit
+		}
+	}
+
+	a.mergeViewport, cmd = a.mergeViewport.Update(msg)
+	return a, cmd
+}
+
+func (a *App) nextMergeConflict() {
+	if a.mergeIndex < len(a.mergePlan.Conflicts)-1 {
+		a.mergeIndex++
+		a.refreshMergeDiff()
+	}
+}
+
+func (a *App) refreshMergeDiff() {
+	conflict := a.mergePlan.Conflicts[a.mergeIndex]
+
+	a.mergeViewport = viewport.New(a.width-10, a.height-24)
+	a.mergeViewport.SetContent(SideBySideDiff(conflict.Mine.Content, conflict.Theirs.Content, a.width-10))
+}
+
+func (a *App) applyMerge() (tea.Model, tea.Cmd) {
+	result, err := a.mergePlan.Apply()
+	if err != nil {
+		a.errorMsg = err.Error()
+		return a, nil
+	}
+
+	a.currentTable = a.mergePlan.Table
+	a.mergePlan = nil
+	a.importPreview = nil
+	a.importTabular = nil
+	a.navigate(TableScreen)
+	a.loadEntries()
+	a.successMsg = fmt.Sprintf("Merged: %d added, %d updated, %d kept.", result.Added, result.Updated, result.Kept)
+	return a, nil
+}
+
+func (a *App) viewMergeScreen() string {
+	title := Title.Render("Merge Import")
+
+	if a.mergePlan != nil {
+		return a.viewMergeConflicts(title)
+	}
+
+	content := BoxStyle.Copy().Width(a.width - 4).Render(a.list.View())
+
+	help := HelpView(
+		Help("Navigate", Keys.Up, Keys.Down),
+		Help("Merge into table", Keys.Select),
+		Help("Back", Keys.Cancel),
+		Help("Quit", Keys.ForceQuit),
+	)
+
+	return fmt.Sprintf(
+		"%s\n\n%s\n\n%s",
+		title,
+		content,
+		help,
+	)
+}
+
+func (a *App) viewMergeConflicts(title string) string {
+	plan := a.mergePlan
+	conflict := plan.Conflicts[a.mergeIndex]
+
+	summary := Subtle.Render(fmt.Sprintf("Into %s: %d new, %d unchanged, %d conflicting",
+		plan.Table.Name, len(plan.New), plan.Unchanged, len(plan.Conflicts)))
+
+	heading := Subtitle.Render(fmt.Sprintf("Conflict %d of %d: %s", a.mergeIndex+1, len(plan.Conflicts), conflict.Mine.Title))
+
+	details := fmt.Sprintf("%s %s\n%s %s\n%s %s\n%s %s",
+		Subtle.Render("Title:  "), compareField(conflict.Mine.Title, conflict.Theirs.Title),
+		Subtle.Render("Tags:   "), compareField(conflict.Mine.Tags, conflict.Theirs.Tags),
+		Subtle.Render("Lang:   "), compareField(conflict.Mine.Language, conflict.Theirs.Language),
+		Subtle.Render("Updated:"), compareField(
+			conflict.Mine.UpdatedAt.Format("Jan 02, 2006 15:04"),
+			conflict.Theirs.UpdatedAt.Format("Jan 02, 2006 15:04")),
+	)
+
+	resolution := Normal.Render("Resolution: ") + Success.Render(conflict.Resolution.String())
+
+	content := BoxStyle.Copy().Width(a.width - 4).Render(fmt.Sprintf("%s\n%s\n\n%s\n\n%s\n\n%s",
+		summary,
+		heading,
+		details,
+		a.mergeViewport.View(),
+		resolution,
+	))
+
+	help := HelpView(
+		Help("Keep mine", Keys.KeepMine),
+		Help("Take theirs", Keys.TakeTheirs),
+		Help("Keep both", Keys.KeepBoth),
+		Help("Prev/next conflict", Keys.Left, Keys.Right),
+		Help("Scroll diff", Keys.Up, Keys.Down),
+		Help("Apply merge", Keys.Confirm),
+		Help("Back", Keys.Cancel),
+	)
+
+	return fmt.Sprintf(
+		"%s\n\n%s\n\n%s",
+		title,
+		content,
+		help,
+	)
+}
+
+func compareField(mine, theirs string) string {
+	if mine == theirs {
+		return Normal.Render(mine)
+	}
+	return Error.Render(mine) + Subtle.Render(" → ") + Success.Render(theirs)
+}
@@ -0,0 +1,117 @@
+package tui
+
+import (
+	"strconv"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// updateMouse handles clicks, the mouse wheel and dragging the border between
+// the list and the preview of the table screen. Clicks are matched to the
+// zones of the last frame, see zones.go.
+func (a *App) updateMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	if a.resizing {
+		switch msg.Action {
+		case tea.MouseActionMotion:
+			if rect, ok := zones["split"]; ok {
+				a.splitWidth = a.clampSplit(msg.X - rect.x0 - 1)
+			}
+		case tea.MouseActionRelease:
+			a.resizing = false
+		}
+		return a, nil
+	}
+
+	// The entry scrolls by a few lines per step of the wheel
+	if tea.MouseEvent(msg).IsWheel() && a.screen == ViewEntryScreen && !a.palette.open {
+		var cmd tea.Cmd
+		a.entryViewport, cmd = a.entryViewport.Update(msg)
+		return a, cmd
+	}
+
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		return a.press(Keys.Up)
+	case tea.MouseButtonWheelDown:
+		return a.press(Keys.Down)
+	case tea.MouseButtonLeft:
+		if msg.Action == tea.MouseActionPress {
+			return a.click(msg.X, msg.Y)
+		}
+	}
+	return a, nil
+}
+
+// press acts as if the first key of binding was pressed
+func (a *App) press(binding key.Binding) (tea.Model, tea.Cmd) {
+	if !binding.Enabled() {
+		return a, nil
+	}
+	msg, ok := keyPress(binding.Keys()[0])
+	if !ok {
+		return a, nil
+	}
+	return a.Update(msg)
+}
+
+func (a *App) click(x, y int) (tea.Model, tea.Cmd) {
+	if a.palette.open {
+		if i, ok := zoneAt("palette:", x, y); ok {
+			n, _ := strconv.Atoi(i)
+			if n == a.palette.cursor {
+				return a.press(Keys.Confirm)
+			}
+			a.palette.cursor = n
+		} else if _, ok := zoneAt("palette", x, y); !ok {
+			// Clicking next to the palette closes it
+			a.palette.open = false
+		}
+		return a, nil
+	}
+
+	// Help bar entries run their action
+	if k, ok := zoneAt("key:", x, y); ok {
+		if msg, ok := keyPress(k); ok {
+			return a.Update(msg)
+		}
+		return a, nil
+	}
+
+	switch a.screen {
+	case HomeScreen, TableScreen:
+		if a.screen == TableScreen && a.showPreview() {
+			// The borders of list and preview can be dragged
+			if rect, ok := zones["split"]; ok && y >= rect.y0 && y <= rect.y1 && (x == rect.x1 || x == rect.x1+1) {
+				a.resizing = true
+				return a, nil
+			}
+			if _, ok := zoneAt("preview", x, y); ok {
+				return a.press(Keys.Select)
+			}
+		}
+
+		// The first click selects an item, clicking it again opens it
+		if i, ok := zoneAt("item:", x, y); ok && a.list.FilterState() != list.Filtering {
+			n, _ := strconv.Atoi(i)
+			if n == a.list.Index() {
+				return a.press(Keys.Select)
+			}
+			a.list.Select(n)
+		}
+	case NewEntryScreen, EditEntryScreen:
+		if a.pendingLeave != nil {
+			break
+		}
+		if rect, ok := zones["editor"]; ok && y >= rect.y0 && y <= rect.y1 && x >= rect.x0 {
+			// Clicking the text moves the cursor there
+			a.focusEntryField("content")
+			a.entryContent.click(x-rect.x0, y-rect.y0)
+		} else if field, ok := zoneAtRow("field:", y); ok {
+			a.focusEntryField(field)
+		}
+	}
+
+	return a, nil
+}
@@ -0,0 +1,215 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/s42yt/thighpads/pkg/database"
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+// maxHistory is how many locations back and forward navigation remember
+const maxHistory = 50
+
+// location is a screen back and forward navigation can return to, together
+// with where the user was on it: the selected list item and filter on the
+// home and table screens, the scroll position of an entry and the selected
+// attachment
+type location struct {
+	screen Screen
+	table  models.Table
+	entry  models.Entry
+	index  int
+	filter string
+}
+
+// browsing reports whether a screen is remembered by the navigation history.
+// Forms and dialogs are not; leaving them goes back to where they were
+// opened from.
+func browsing(screen Screen) bool {
+	switch screen {
+	case HomeScreen, TableScreen, ViewEntryScreen, AttachmentsScreen:
+		return true
+	}
+	return false
+}
+
+// here is the current location
+func (a *App) here() location {
+	loc := location{screen: a.screen, table: a.currentTable, entry: a.currentEntry}
+	switch a.screen {
+	case HomeScreen, TableScreen:
+		loc.index = a.list.Index()
+		if a.list.FilterState() != list.Unfiltered {
+			loc.filter = a.list.FilterValue()
+		}
+	case ViewEntryScreen:
+		loc.index = a.entryViewport.YOffset
+	case AttachmentsScreen:
+		loc.index = a.attachmentIndex
+	}
+	return loc
+}
+
+// samePlace reports whether two locations show the same table or entry on the
+// same screen
+func (l location) samePlace(other location) bool {
+	return l.screen == other.screen && l.table.ID == other.table.ID && l.entry.ID == other.entry.ID
+}
+
+// navigate switches to screen, remembering the current screen so that Back
+// returns to it. Going somewhere new forgets the locations Back came from.
+func (a *App) navigate(screen Screen) {
+	if browsing(a.screen) {
+		a.history = pushLocation(a.history, a.here())
+		a.future = nil
+	}
+	a.screen = screen
+}
+
+// goBack returns to the previous location, or to the home screen when there
+// is none
+func (a *App) goBack() {
+	current := a.here()
+	for len(a.history) > 0 && a.history[len(a.history)-1].samePlace(current) {
+		a.history = a.history[:len(a.history)-1]
+	}
+
+	if len(a.history) == 0 {
+		if a.screen != HomeScreen {
+			if browsing(a.screen) {
+				a.future = pushLocation(a.future, current)
+			}
+			a.restore(location{screen: HomeScreen})
+		}
+		return
+	}
+
+	previous := a.history[len(a.history)-1]
+	a.history = a.history[:len(a.history)-1]
+	if browsing(a.screen) {
+		a.future = pushLocation(a.future, current)
+	}
+	a.restore(previous)
+}
+
+// goForward undoes the last goBack
+func (a *App) goForward() {
+	if len(a.future) == 0 {
+		return
+	}
+
+	next := a.future[len(a.future)-1]
+	a.future = a.future[:len(a.future)-1]
+	if browsing(a.screen) {
+		a.history = pushLocation(a.history, a.here())
+	}
+	a.restore(next)
+}
+
+func pushLocation(stack []location, loc location) []location {
+	if len(stack) > 0 && stack[len(stack)-1].samePlace(loc) {
+		stack[len(stack)-1] = loc
+		return stack
+	}
+	if len(stack) == maxHistory {
+		stack = stack[1:]
+	}
+	return append(stack, loc)
+}
+
+// restore shows a remembered location, reloading what it shows since it may
+// have changed in the meantime
+func (a *App) restore(loc location) {
+	a.screen = loc.screen
+	a.currentTable = loc.table
+
+	switch loc.screen {
+	case HomeScreen:
+		a.loadTables()
+		a.restoreList(loc)
+	case TableScreen:
+		a.loadEntries()
+		a.restoreList(loc)
+	case ViewEntryScreen, AttachmentsScreen:
+		entry, err := database.GetEntry(loc.entry.ID)
+		if err != nil {
+			// The entry was deleted, show its table instead
+			a.screen = TableScreen
+			a.loadEntries()
+			a.errorMsg = "The entry \"" + loc.entry.Title + "\" no longer exists."
+			return
+		}
+		a.showEntry(entry)
+		if loc.screen == ViewEntryScreen {
+			a.entryViewport.SetYOffset(loc.index)
+		} else {
+			a.attachmentIndex = loc.index
+			a.attachmentMode = attachmentBrowse
+			a.loadAttachments()
+		}
+	}
+}
+
+// restoreList selects the list item and reapplies the filter of loc
+func (a *App) restoreList(loc location) {
+	if loc.filter != "" {
+		a.list.SetFilterText(loc.filter)
+	}
+	if loc.index < len(a.list.VisibleItems()) {
+		a.list.Select(loc.index)
+	}
+}
+
+// breadcrumb shows where the current screen is, e.g. "Home › Recipes ›
+// Pancakes"
+func (a *App) breadcrumb() string {
+	parts := []string{"Home"}
+
+	inTable := a.currentTable.ID != 0
+	switch a.screen {
+	case SetupScreen, HomeScreen:
+		return ""
+	case TableScreen:
+		parts = append(parts, a.currentTable.Name)
+	case ViewEntryScreen:
+		parts = append(parts, a.currentTable.Name, a.currentEntry.Title)
+	case EditEntryScreen:
+		parts = append(parts, a.currentTable.Name, a.currentEntry.Title, "Edit")
+	case AttachmentsScreen:
+		parts = append(parts, a.currentTable.Name, a.currentEntry.Title, "Attachments")
+	case NewEntryScreen:
+		parts = append(parts, a.currentTable.Name, "New entry")
+	case NewTableScreen:
+		parts = append(parts, "New table")
+	case ImportScreen:
+		parts = append(parts, "Import")
+	case MergeScreen:
+		parts = append(parts, "Import", "Merge")
+	case ExportScreen:
+		if !a.exportAll && inTable {
+			parts = append(parts, a.currentTable.Name)
+		}
+		parts = append(parts, "Export")
+	case SyncScreen:
+		parts = append(parts, "Sync")
+	case ThemeScreen:
+		parts = append(parts, "Theme")
+	}
+
+	last := len(parts) - 1
+	for i, part := range parts[:last] {
+		parts[i] = Subtle.Render(truncateString(part, 30))
+	}
+	parts[last] = Normal.Render(truncateString(parts[last], 30))
+	return strings.Join(parts, Subtle.Render(" › "))
+}
+
+// forwardHelp is the help bar entry of Keys.Forward, left out when there is
+// nothing to go forward to
+func (a *App) forwardHelp() HelpKey {
+	if len(a.future) == 0 {
+		return HelpKey{}
+	}
+	return Help("Forward", Keys.Forward)
+}
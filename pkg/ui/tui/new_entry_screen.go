@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/s42yt/thighpads/pkg/data"
 	"github.com/s42yt/thighpads/pkg/database"
 	"github.com/s42yt/thighpads/pkg/models"
 )
@@ -14,85 +16,85 @@ func (a *App) updateNewEntryScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyTab:
-			if a.entryTitleInput.Focused() {
-				a.entryTitleInput.Blur()
-				a.entryTagsInput.Focus()
-				a.entryContent.Blur()
-			} else if a.entryTagsInput.Focused() {
-				a.entryTitleInput.Blur()
-				a.entryTagsInput.Blur()
-				a.entryContent.Focus()
-			} else {
-				a.entryTitleInput.Focus()
-				a.entryTagsInput.Blur()
-				a.entryContent.Blur()
-			}
-			return a, nil
-		case tea.KeyCtrlS:
-			if a.entryTitleInput.Value() != "" {
-				newEntry := models.Entry{
-					TableID:   a.currentTable.ID,
-					Title:     a.entryTitleInput.Value(),
-					Tags:      a.entryTagsInput.Value(),
-					Content:   a.entryContent.Value(),
-					CreatedAt: time.Now(),
-				}
-
-				err := database.CreateEntry(&newEntry)
-				if err != nil {
-					a.errorMsg = err.Error()
-					return a, nil
-				}
+		if a.pendingLeave != nil {
+			return a.updateLeavePrompt(msg)
+		}
+		if a.entryContent.finding() && !key.Matches(msg, Keys.Save, Keys.ForceQuit) {
+			a.entryContent, cmd = a.entryContent.Update(msg)
+			return a, cmd
+		}
 
-				a.screen = TableScreen
-				a.loadEntries()
-				a.successMsg = "Entry created successfully."
-				return a, nil
+		switch {
+		case typed(msg):
+			// Typed into the focused field
+		case key.Matches(msg, Keys.NextField):
+			a.nextEntryField()
+			return a, nil
+		case key.Matches(msg, Keys.Save):
+			if a.saveNewEntry() {
+				return a, a.closeEntryForm()
 			}
-		case tea.KeyEsc:
-			a.screen = TableScreen
 			return a, nil
-		case tea.KeyCtrlC:
-			return a, tea.Quit
+		case key.Matches(msg, Keys.Cancel):
+			return a, a.leaveEntryForm((*App).closeEntryForm)
+		case key.Matches(msg, Keys.ForceQuit):
+			return a, a.leaveEntryForm(func(*App) tea.Cmd { return tea.Quit })
 		}
 	}
 
-	if a.entryTitleInput.Focused() {
-		a.entryTitleInput, cmd = a.entryTitleInput.Update(msg)
-	} else if a.entryTagsInput.Focused() {
-		a.entryTagsInput, cmd = a.entryTagsInput.Update(msg)
-	} else {
-		a.entryContent, cmd = a.entryContent.Update(msg)
+	return a, a.updateEntryField(msg)
+}
+
+// saveNewEntry creates the entry of the form, reporting whether that worked
+func (a *App) saveNewEntry() bool {
+	if a.entryTitleInput.Value() == "" {
+		a.errorMsg = "Title cannot be empty"
+		return false
+	}
+
+	newEntry := models.Entry{
+		TableID:   a.currentTable.ID,
+		Title:     a.entryTitleInput.Value(),
+		Tags:      a.entryTagsInput.Value(),
+		Content:   a.entryContent.Value(),
+		Language:  data.NormalizeLanguage(a.entryLangInput.Value()),
+		CreatedAt: time.Now(),
 	}
 
-	return a, cmd
+	err := database.CreateEntry(&newEntry)
+	if err != nil {
+		a.errorMsg = err.Error()
+		return false
+	}
+
+	a.discardDraft()
+	a.successMsg = "Entry created successfully."
+	return true
 }
 
 func (a *App) viewNewEntryScreen() string {
 	title := Title.Render("New Entry")
 	subtitle := Subtitle.Render(a.currentTable.Name)
+	if status := a.entryFormStatus(); status != "" {
+		subtitle += "  " + status
+	}
 
-	titleInput := Subtitle.Render("Title:") + "\n" + a.entryTitleInput.View()
-	tagsInput := Subtitle.Render("Tags:") + "\n" + a.entryTagsInput.View()
-	content := Subtitle.Render("Content:") + "\n" + a.entryContent.View()
+	titleInput := markZone("field:title", Subtitle.Render("Title:")+"\n"+a.entryTitleInput.View())
+	tagsInput := markZone("field:tags", Subtitle.Render("Tags:")+"\n"+a.entryTagsInput.View())
+	languageInput := a.entryLanguageField()
+	help := a.entryFormHelp("Save entry")
+	a.sizeEntryEditor(title+"\n"+subtitle, help, 0)
+	content := markZone("field:content", Subtitle.Render("Content:")+"\n"+markZone("editor", a.entryContent.View()))
 
 	form := BoxStyle.Render(
-		fmt.Sprintf("%s\n\n%s\n\n%s",
+		fmt.Sprintf("%s\n\n%s\n\n%s\n\n%s",
 			titleInput,
 			tagsInput,
+			languageInput,
 			content,
 		),
 	)
 
-	help := HelpView(map[string]string{
-		"Tab":    "Next field",
-		"Ctrl+S": "Save entry",
-		"Esc":    "Cancel",
-		"Ctrl+C": "Quit",
-	})
-
 	return fmt.Sprintf(
 		"%s\n%s\n\n%s\n\n%s",
 		title,
@@ -4,18 +4,26 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/s42yt/thighpads/pkg/database"
 	"github.com/s42yt/thighpads/pkg/models"
 )
 
+func (a *App) openNewTableScreen() {
+	a.navigate(NewTableScreen)
+	a.tableNameInput = TextInputField("Enter table name")
+}
+
 func (a *App) updateNewTableScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyEnter:
+		switch {
+		case typed(msg):
+			// Typed into the focused field
+		case key.Matches(msg, Keys.Confirm):
 			if a.tableNameInput.Value() != "" {
 				newTable := models.Table{
 					Name:      a.tableNameInput.Value(),
@@ -29,15 +37,14 @@ func (a *App) updateNewTableScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return a, nil
 				}
 
-				a.screen = HomeScreen
-				a.loadTables()
+				a.goBack()
 				a.successMsg = "Table created successfully."
 				return a, nil
 			}
-		case tea.KeyEsc:
-			a.screen = HomeScreen
+		case key.Matches(msg, Keys.Cancel):
+			a.goBack()
 			return a, nil
-		case tea.KeyCtrlC:
+		case key.Matches(msg, Keys.ForceQuit):
 			return a, tea.Quit
 		}
 	}
@@ -56,11 +63,11 @@ func (a *App) viewNewTableScreen() string {
 		),
 	)
 
-	help := HelpView(map[string]string{
-		"Enter":  "Create table",
-		"Esc":    "Cancel",
-		"Ctrl+C": "Quit",
-	})
+	help := HelpView(
+		Help("Create table", Keys.Confirm),
+		Help("Cancel", Keys.Cancel),
+		Help("Quit", Keys.ForceQuit),
+	)
 
 	return fmt.Sprintf(
 		"%s\n\n%s\n\n%s",
@@ -0,0 +1,452 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/s42yt/thighpads/pkg/data"
+	"github.com/s42yt/thighpads/pkg/database"
+	"github.com/s42yt/thighpads/pkg/models"
+)
+
+// paletteRows is how many matches the command palette shows at once
+const paletteRows = 10
+
+// paletteCommand is something the command palette can run
+type paletteCommand struct {
+	Title string
+	// Hint is shown next to the title in a subtle color, e.g. the table of
+	// an entry
+	Hint string
+	Run  func(a *App) tea.Cmd
+}
+
+// commandPalette lists every action of the app, every table and every entry
+// and narrows them down as the user types. In search mode it lists the
+// entries containing the query instead.
+type commandPalette struct {
+	open      bool
+	searching bool
+	input     textinput.Model
+	commands  []paletteCommand
+	matches   []paletteCommand
+	cursor    int
+	offset    int
+}
+
+func (a *App) openPalette() {
+	a.palette = commandPalette{
+		open:     true,
+		input:    TextInputField("Type a command, table or entry"),
+		commands: a.paletteCommands(),
+	}
+	a.palette.input.Prompt = "> "
+	a.filterPalette()
+}
+
+// paletteCommands collects what the palette offers on the current screen
+func (a *App) paletteCommands() []paletteCommand {
+	var commands []paletteCommand
+	add := func(title, hint string, run func(a *App) tea.Cmd) {
+		commands = append(commands, paletteCommand{Title: title, Hint: hint, Run: run})
+	}
+
+	inTable := a.currentTable.ID != 0 && (a.screen == TableScreen || a.screen == ViewEntryScreen || a.screen == AttachmentsScreen)
+
+	if inTable {
+		add("New entry", "in "+a.currentTable.Name, func(a *App) tea.Cmd {
+			a.openNewEntryScreen()
+			return nil
+		})
+	}
+	if a.screen == ViewEntryScreen {
+		add("Find in entry", a.currentEntry.Title, func(a *App) tea.Cmd {
+			return a.startEntrySearch()
+		})
+		for i, block := range data.CodeBlocks(a.currentEntry.Content) {
+			add(fmt.Sprintf("Copy code block %d", i+1), codeBlockSummary(block), func(a *App) tea.Cmd {
+				a.copyCodeBlock(i)
+				return nil
+			})
+		}
+	}
+	if a.screen == ViewEntryScreen || a.screen == AttachmentsScreen {
+		add("Edit entry", a.currentEntry.Title, func(a *App) tea.Cmd {
+			a.openEditEntryScreen()
+			return nil
+		})
+		add("Attachments", a.currentEntry.Title, func(a *App) tea.Cmd {
+			a.openAttachmentsScreen()
+			return nil
+		})
+	}
+	add("Search entries", "by title, tags and content", func(a *App) tea.Cmd {
+		a.palette.open = true
+		a.palette.searching = true
+		a.palette.input.SetValue("")
+		a.palette.input.Placeholder = "Search all entries"
+		a.filterPalette()
+		return nil
+	})
+	add("New table", "", func(a *App) tea.Cmd {
+		a.openNewTableScreen()
+		return nil
+	})
+	add("Import", "tables, notes folders, CSV and more", func(a *App) tea.Cmd {
+		a.openImportScreen()
+		return nil
+	})
+	if inTable {
+		add("Export table", a.currentTable.Name, func(a *App) tea.Cmd {
+			a.openExportScreen(false)
+			return nil
+		})
+	}
+	if len(a.tables) > 0 {
+		add("Export all tables", "", func(a *App) tea.Cmd {
+			a.exportTableIDs = nil
+			a.openExportScreen(true)
+			return nil
+		})
+	}
+	add("Sync now", "", func(a *App) tea.Cmd {
+		a.openSyncScreen()
+		return a.startSync()
+	})
+	add("Sync settings", "", func(a *App) tea.Cmd {
+		a.openSyncScreen()
+		return nil
+	})
+	add("Switch theme", "preview all themes", func(a *App) tea.Cmd {
+		a.openThemeScreen()
+		return nil
+	})
+
+	themes, _ := LoadThemes()
+	for _, theme := range themes {
+		theme := theme
+		add("Theme: "+theme.Name, "", func(a *App) tea.Cmd {
+			a.useTheme(theme)
+			return nil
+		})
+	}
+
+	add("Back", "", func(a *App) tea.Cmd {
+		a.goBack()
+		return nil
+	})
+	if len(a.future) > 0 {
+		add("Forward", "", func(a *App) tea.Cmd {
+			a.goForward()
+			return nil
+		})
+	}
+	add("Home", "", func(a *App) tea.Cmd {
+		a.navigate(HomeScreen)
+		a.loadTables()
+		return nil
+	})
+
+	tables, _ := database.GetTables()
+	for _, table := range tables {
+		table := table
+		add("Table: "+table.Name, "", func(a *App) tea.Cmd {
+			a.openTable(table)
+			return nil
+		})
+	}
+	for _, table := range tables {
+		entries, _ := database.GetEntries(table.ID)
+		for _, entry := range entries {
+			table, entry := table, entry
+			add("Entry: "+entry.Title, "in "+table.Name, func(a *App) tea.Cmd {
+				a.openTable(table)
+				a.openEntry(entry)
+				return nil
+			})
+		}
+	}
+
+	add("Quit", "", func(a *App) tea.Cmd {
+		return tea.Quit
+	})
+
+	return commands
+}
+
+// filterPalette updates the matches after the query changed
+func (a *App) filterPalette() {
+	palette := &a.palette
+	query := strings.TrimSpace(palette.input.Value())
+	palette.cursor, palette.offset = 0, 0
+
+	if palette.searching {
+		palette.matches = searchEntries(query)
+		return
+	}
+
+	if query == "" {
+		palette.matches = palette.commands
+		return
+	}
+
+	type scored struct {
+		command paletteCommand
+		score   int
+	}
+	var results []scored
+	for _, command := range palette.commands {
+		if score, ok := fuzzyScore(query, command.Title); ok {
+			results = append(results, scored{command, score})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].score > results[j].score })
+
+	palette.matches = make([]paletteCommand, len(results))
+	for i, result := range results {
+		palette.matches[i] = result.command
+	}
+}
+
+// searchEntries finds the entries of all tables that contain every word of
+// query in their title, tags or content
+func searchEntries(query string) []paletteCommand {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return nil
+	}
+
+	var matches []paletteCommand
+	tables, _ := database.GetTables()
+	for _, table := range tables {
+		entries, _ := database.GetEntries(table.ID)
+		for _, entry := range entries {
+			text := strings.ToLower(entry.Title + "\n" + entry.Tags + "\n" + entry.Content)
+			found := true
+			for _, word := range words {
+				if !strings.Contains(text, word) {
+					found = false
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+
+			table, entry := table, entry
+			matches = append(matches, paletteCommand{
+				Title: entry.Title,
+				Hint:  "in " + table.Name + "  " + snippet(entry, words[0]),
+				Run: func(a *App) tea.Cmd {
+					a.openTable(table)
+					a.openEntry(entry)
+					return nil
+				},
+			})
+		}
+	}
+	return matches
+}
+
+// snippet is the line of an entry's content that contains word
+func snippet(entry models.Entry, word string) string {
+	for _, line := range strings.Split(entry.Content, "\n") {
+		if strings.Contains(strings.ToLower(line), word) {
+			return strings.TrimSpace(line)
+		}
+	}
+	return ""
+}
+
+// fuzzyScore reports whether the letters of query appear in text in order,
+// ignoring case, and how well they match: consecutive letters and letters at
+// the start of words count more, gaps count less.
+func fuzzyScore(query, text string) (int, bool) {
+	queryRunes := []rune(strings.ToLower(query))
+	textRunes := []rune(strings.ToLower(text))
+
+	score, q, last := 0, 0, -1
+	for i := 0; i < len(textRunes) && q < len(queryRunes); i++ {
+		if queryRunes[q] == ' ' {
+			// Spaces in the query match any gap
+			q++
+			if q == len(queryRunes) {
+				break
+			}
+		}
+		if textRunes[i] != queryRunes[q] {
+			continue
+		}
+
+		switch {
+		case last >= 0 && i == last+1:
+			score += 5
+		case i == 0 || !unicode.IsLetter(textRunes[i-1]) && !unicode.IsDigit(textRunes[i-1]):
+			score += 3
+		default:
+			score++
+		}
+		if last >= 0 {
+			score -= min(i-last-1, 3)
+		}
+		last = i
+		q++
+	}
+
+	if q < len(queryRunes) {
+		return 0, false
+	}
+	return score, true
+}
+
+func (a *App) updatePalette(msg tea.Msg) (tea.Model, tea.Cmd) {
+	palette := &a.palette
+
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case typed(msg):
+			// Typed into the query
+		case key.Matches(msg, Keys.Palette, Keys.Cancel):
+			if palette.searching {
+				a.openPalette()
+				return a, nil
+			}
+			palette.open = false
+			return a, nil
+		case key.Matches(msg, Keys.Up):
+			if palette.cursor > 0 {
+				palette.cursor--
+			}
+			if palette.cursor < palette.offset {
+				palette.offset = palette.cursor
+			}
+			return a, nil
+		case key.Matches(msg, Keys.Down):
+			if palette.cursor < len(palette.matches)-1 {
+				palette.cursor++
+			}
+			if palette.cursor >= palette.offset+paletteRows {
+				palette.offset = palette.cursor - paletteRows + 1
+			}
+			return a, nil
+		case key.Matches(msg, Keys.Confirm):
+			if len(palette.matches) == 0 {
+				return a, nil
+			}
+			command := palette.matches[palette.cursor]
+			palette.open = false
+
+			// Unsaved changes of an entry form the command leaves are kept
+			// as a draft, which the form brings back when opened again
+			form := a.entryFormOpen()
+			if form {
+				a.saveDraft()
+			}
+			cmd := command.Run(a)
+			if form && !a.entryFormOpen() {
+				a.recovering = false
+				if !a.savedDraft.SavedAt.IsZero() {
+					a.successMsg = "Your unsaved changes are kept as a draft until you open the entry form again."
+				}
+			}
+			return a, cmd
+		case key.Matches(msg, Keys.ForceQuit):
+			// Over an entry form, ask what to do with its unsaved changes
+			// just like the form does
+			if a.entryFormOpen() {
+				palette.open = false
+				return a, a.leaveEntryForm(func(*App) tea.Cmd { return tea.Quit })
+			}
+			return a, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	previous := palette.input.Value()
+	palette.input, cmd = palette.input.Update(msg)
+	if palette.input.Value() != previous {
+		a.filterPalette()
+	}
+	return a, cmd
+}
+
+func (a *App) viewPalette() string {
+	palette := &a.palette
+
+	width := min(a.width-8, 72)
+	if width < 30 {
+		width = 30
+	}
+
+	title := "Commands"
+	if palette.searching {
+		title = "Search entries"
+	}
+
+	lines := []string{Subtitle.Render(title), palette.input.View(), ""}
+	if len(palette.matches) == 0 {
+		if palette.searching && strings.TrimSpace(palette.input.Value()) == "" {
+			lines = append(lines, Subtle.Render("Type to search the title, tags and content of all entries"))
+		} else {
+			lines = append(lines, Subtle.Render("Nothing found"))
+		}
+	}
+
+	end := min(palette.offset+paletteRows, len(palette.matches))
+	for i := palette.offset; i < end; i++ {
+		command := palette.matches[i]
+		title := truncateString(command.Title, width-8)
+		hint := ""
+		if command.Hint != "" {
+			hint = truncateString(command.Hint, width-8-len(title)-2)
+		}
+
+		line := Unselected.Render(title) + " " + Subtle.Render(hint)
+		if i == palette.cursor {
+			line = Selected.Render(title) + " " + Subtle.Render(hint)
+		}
+		lines = append(lines, markZone(fmt.Sprintf("palette:%d", i), line))
+	}
+	if len(palette.matches) > paletteRows {
+		lines = append(lines, Subtle.Render(fmt.Sprintf("%d of %d", palette.cursor+1, len(palette.matches))))
+	}
+
+	lines = append(lines, "", Subtle.Render(fmt.Sprintf("%s: Select • %s: Run • %s: Close",
+		Help("", Keys.Up, Keys.Down).Keys, KeyName(Keys.Confirm), KeyName(Keys.Cancel))))
+
+	return markZone("palette", FocusedBoxStyle.Copy().Width(width).Render(strings.Join(lines, "\n")))
+}
+
+// overlay draws box over the middle of view, keeping the rest of view visible
+// around it
+func overlay(view, box string, width int) string {
+	viewLines := strings.Split(view, "\n")
+	boxLines := strings.Split(box, "\n")
+	boxWidth := lipgloss.Width(box)
+
+	x := max((width-boxWidth)/2, 0)
+	y := 3
+
+	for len(viewLines) < y+len(boxLines) {
+		viewLines = append(viewLines, "")
+	}
+
+	for i, boxLine := range boxLines {
+		line := viewLines[y+i]
+		left := ansi.Truncate(line, x, "")
+		if padding := x - ansi.StringWidth(left); padding > 0 {
+			left += strings.Repeat(" ", padding)
+		}
+		right := ansi.TruncateLeft(line, x+boxWidth, "")
+		viewLines[y+i] = left + "\x1b[0m" + boxLine + "\x1b[0m" + right
+	}
+
+	return strings.Join(viewLines, "\n")
+}
@@ -3,6 +3,7 @@ package tui
 import (
 	"fmt"
 
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/s42yt/thighpads/pkg/config"
 )
@@ -12,8 +13,10 @@ func (a *App) updateSetupScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyEnter:
+		switch {
+		case typed(msg):
+			// Typed into the focused field
+		case key.Matches(msg, Keys.Confirm):
 			if a.usernameInput.Value() != "" {
 				a.config.Username = a.usernameInput.Value()
 				err := config.SaveConfig(a.config)
@@ -27,7 +30,7 @@ func (a *App) updateSetupScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
 				a.successMsg = "Setup complete! Welcome to ThighPads."
 				return a, nil
 			}
-		case tea.KeyCtrlC:
+		case key.Matches(msg, Keys.ForceQuit):
 			return a, tea.Quit
 		}
 	}
@@ -47,10 +50,10 @@ func (a *App) viewSetupScreen() string {
 		),
 	)
 
-	help := HelpView(map[string]string{
-		"Enter":  "Save username",
-		"Ctrl+C": "Quit",
-	})
+	help := HelpView(
+		Help("Save username", Keys.Confirm),
+		Help("Quit", Keys.ForceQuit),
+	)
 
 	return fmt.Sprintf(
 		"%s\n\n%s\n\n%s\n\n%s",
@@ -0,0 +1,403 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/s42yt/thighpads/pkg/syncer"
+)
+
+type syncDoneMsg struct {
+	result syncer.Result
+	err    error
+}
+
+// syncTickMsg starts a timed sync. Ticks from before the last sync carry an
+// older generation and are ignored, so that syncing by hand does not stack up
+// timers.
+type syncTickMsg struct {
+	generation int
+}
+
+// startSync runs a sync in the background unless one is running already or
+// no shared folder is set up
+func (a *App) startSync() tea.Cmd {
+	if a.syncing || !a.syncStatus.Configured {
+		return nil
+	}
+
+	a.syncing = true
+	return func() tea.Msg {
+		result, err := syncer.Sync()
+		return syncDoneMsg{result: result, err: err}
+	}
+}
+
+func (a *App) scheduleSync() tea.Cmd {
+	if !a.syncStatus.Configured {
+		return nil
+	}
+
+	a.syncGeneration++
+	generation := a.syncGeneration
+	return tea.Tick(syncer.Interval(), func(time.Time) tea.Msg {
+		return syncTickMsg{generation: generation}
+	})
+}
+
+func (a *App) refreshSyncStatus() {
+	status, err := syncer.GetStatus()
+	if err != nil {
+		a.errorMsg = err.Error()
+		return
+	}
+
+	a.syncStatus = status
+	if a.conflictIndex >= len(status.Conflicts) {
+		a.conflictIndex = len(status.Conflicts) - 1
+	}
+	if a.conflictIndex < 0 {
+		a.conflictIndex = 0
+	}
+	a.refreshConflictDiff()
+}
+
+// handleSyncMsg updates the app after a background sync, whatever screen is
+// shown
+func (a *App) handleSyncMsg(msg tea.Msg) (tea.Cmd, bool) {
+	switch msg := msg.(type) {
+	case syncTickMsg:
+		if msg.generation != a.syncGeneration {
+			return nil, true
+		}
+		return a.startSync(), true
+
+	case syncDoneMsg:
+		a.syncing = false
+		a.syncErr = msg.err
+		a.refreshSyncStatus()
+
+		if msg.err == nil && msg.result.Received > 0 {
+			switch a.screen {
+			case HomeScreen:
+				a.loadTables()
+			case TableScreen:
+				a.loadEntries()
+			}
+		}
+
+		if a.screen == SyncScreen && msg.err == nil {
+			a.successMsg = fmt.Sprintf("Synced: %d sent, %d received, %d new conflicts.", msg.result.Sent, msg.result.Received, msg.result.Conflicts)
+		}
+
+		return a.scheduleSync(), true
+	}
+
+	return nil, false
+}
+
+func (a *App) openSyncScreen() {
+	a.navigate(SyncScreen)
+	a.syncEditing = false
+	a.conflictIndex = 0
+	a.refreshSyncStatus()
+}
+
+func (a *App) updateSyncScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	if a.syncEditing {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch {
+			case typed(msg):
+				// Typed into the focused field
+			case key.Matches(msg, Keys.Confirm):
+				if err := syncer.SetFolder(a.syncFolderInput.Value()); err != nil {
+					a.errorMsg = err.Error()
+					return a, nil
+				}
+				a.syncEditing = false
+				a.refreshSyncStatus()
+				return a, a.startSync()
+			case key.Matches(msg, Keys.Cancel):
+				a.syncEditing = false
+				return a, nil
+			case key.Matches(msg, Keys.ForceQuit):
+				return a, tea.Quit
+			}
+		}
+
+		a.syncFolderInput, cmd = a.syncFolderInput.Update(msg)
+		return a, cmd
+	}
+
+	conflicts := a.syncStatus.Conflicts
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, Keys.Sync):
+			if !a.syncStatus.Configured {
+				a.errorMsg = syncer.ErrNotConfigured.Error()
+				return a, nil
+			}
+			return a, a.startSync()
+		case key.Matches(msg, Keys.SyncFolder):
+			a.syncEditing = true
+			a.syncFolderInput = TextInputField("Shared folder, e.g. ~/Sync")
+			if a.syncStatus.Git {
+				a.syncFolderInput = TextInputField("Git remote, e.g. git@example.com:me/notes.git")
+			}
+			a.syncFolderInput.SetValue(a.syncStatus.Folder)
+			return a, nil
+		case key.Matches(msg, Keys.KeepVersion):
+			if len(conflicts) > 0 {
+				if err := syncer.DismissConflict(a.conflictIndex); err != nil {
+					a.errorMsg = err.Error()
+					return a, nil
+				}
+				a.refreshSyncStatus()
+				a.successMsg = "Kept the synced version."
+			}
+			return a, nil
+		case key.Matches(msg, Keys.OtherVersion):
+			if len(conflicts) > 0 {
+				if err := syncer.UseOtherVersion(a.conflictIndex); err != nil {
+					a.errorMsg = err.Error()
+					return a, nil
+				}
+				a.refreshSyncStatus()
+				a.successMsg = "Restored the other version. It is sent to your other devices on the next sync."
+				return a, a.startSync()
+			}
+			return a, nil
+		case key.Matches(msg, Keys.Up):
+			if a.conflictIndex > 0 {
+				a.conflictIndex--
+				a.refreshConflictDiff()
+			}
+			return a, nil
+		case key.Matches(msg, Keys.Down):
+			if a.conflictIndex < len(conflicts)-1 {
+				a.conflictIndex++
+				a.refreshConflictDiff()
+			}
+			return a, nil
+		case key.Matches(msg, Keys.Back):
+			a.goBack()
+			return a, nil
+		case key.Matches(msg, Keys.Quit):
+			return a, tea.Quit
+		}
+	}
+
+	a.conflictDiff, cmd = a.conflictDiff.Update(msg)
+	return a, cmd
+}
+
+func (a *App) refreshConflictDiff() {
+	conflicts := a.syncStatus.Conflicts
+	if len(conflicts) == 0 {
+		return
+	}
+
+	conflict := conflicts[a.conflictIndex]
+	height := a.height - 30
+	if height < 5 {
+		height = 5
+	}
+
+	a.conflictDiff = viewport.New(a.width-10, height)
+	a.conflictDiff.SetContent(LabeledDiff(
+		conflictText(conflict.Kept),
+		conflictText(conflict.Other),
+		"Kept ("+a.deviceName(conflict.Kept.Device)+")",
+		"Other ("+a.deviceName(conflict.Other.Device)+")",
+		a.width-10,
+	))
+}
+
+// conflictText renders one side of a conflict for the diff
+func conflictText(version syncer.Change) string {
+	switch {
+	case version.Deleted:
+		return "(deleted)"
+	case version.Entry != nil:
+		return "# " + version.Entry.Title + "\nTags: " + version.Entry.Tags + "\n\n" + version.Entry.Content
+	case version.Table != nil:
+		return version.Table.Name + "\nAuthor: " + version.Table.Author
+	}
+	return ""
+}
+
+func (a *App) deviceName(id string) string {
+	if id == a.syncStatus.DeviceID {
+		return "this device"
+	}
+	for _, device := range a.syncStatus.Devices {
+		if device.ID == id {
+			return device.Name
+		}
+	}
+	return "another device"
+}
+
+// syncSummary is the one line sync status shown on the home screen
+func (a *App) syncSummary() string {
+	status := a.syncStatus
+	if !status.Configured {
+		return ""
+	}
+
+	switch {
+	case a.syncing:
+		return Subtle.Render("Syncing…")
+	case a.syncErr != nil:
+		return Error.Render("Sync failed: " + a.syncErr.Error())
+	case len(status.Conflicts) == 1:
+		return Warning.Render(fmt.Sprintf("1 sync conflict to review, press '%s'", KeyName(Keys.Sync)))
+	case len(status.Conflicts) > 1:
+		return Warning.Render(fmt.Sprintf("%d sync conflicts to review, press '%s'", len(status.Conflicts), KeyName(Keys.Sync)))
+	case status.LastSync.IsZero():
+		return Subtle.Render("Not synced yet")
+	}
+	return Subtle.Render("Synced " + formatAgo(status.LastSync))
+}
+
+func formatAgo(t time.Time) string {
+	elapsed := time.Since(t)
+	switch {
+	case elapsed < time.Minute:
+		return "just now"
+	case elapsed < time.Hour:
+		return fmt.Sprintf("%d min ago", int(elapsed.Minutes()))
+	case elapsed < 24*time.Hour:
+		return fmt.Sprintf("%d h ago", int(elapsed.Hours()))
+	}
+	return "on " + t.Format("Jan 02, 2006 15:04")
+}
+
+func (a *App) viewSyncScreen() string {
+	title := Title.Copy().Width(a.width - 4).Render("Sync")
+	status := a.syncStatus
+
+	subtitleText, folderLabel := "Keep your tables in sync through a shared folder", "Folder:      "
+	if status.Git {
+		subtitleText, folderLabel = "Keep your tables in sync through a git remote", "Remote:      "
+	} else if status.Server {
+		subtitleText, folderLabel = "Keep your tables in sync through a sync server", "Server:      "
+	}
+	subtitle := Subtitle.Copy().Width(a.width - 4).Render(subtitleText)
+
+	var lines []string
+	if a.syncEditing && status.Git {
+		lines = append(lines,
+			Normal.Render("Git remote:"),
+			a.syncFolderInput.View(),
+			"",
+			Subtle.Render("Any URL git can pull from and push to works, including the path of a bare repository."),
+			Subtle.Render("Leave it empty to turn sync off."),
+		)
+	} else if a.syncEditing {
+		lines = append(lines,
+			Normal.Render("Shared folder:"),
+			a.syncFolderInput.View(),
+			"",
+			Subtle.Render("Pick a folder that a tool like Syncthing or Dropbox keeps in sync between your devices,"),
+			Subtle.Render("or enter the URL of a sync server with its token, e.g. http://TOKEN@host:8420."),
+			Subtle.Render("Leave it empty to turn sync off."),
+		)
+	} else if !status.Configured && status.Git {
+		lines = append(lines,
+			Normal.Render(fmt.Sprintf("Sync is not set up. Press '%s' to add a git remote.", KeyName(Keys.SyncFolder))),
+		)
+	} else if !status.Configured {
+		lines = append(lines,
+			Normal.Render(fmt.Sprintf("Sync is not set up. Press '%s' to choose a shared folder.", KeyName(Keys.SyncFolder))),
+		)
+	} else {
+		lastSync := "never"
+		if a.syncing {
+			lastSync = "syncing now…"
+		} else if !status.LastSync.IsZero() {
+			lastSync = formatAgo(status.LastSync)
+		}
+
+		lines = append(lines,
+			Subtle.Render(folderLabel)+Normal.Render(status.Folder),
+			Subtle.Render("This device: ")+Normal.Render(status.DeviceName),
+			Subtle.Render("Last sync:   ")+Normal.Render(lastSync),
+		)
+		if a.syncErr != nil {
+			lines = append(lines, Error.Render("Last sync failed: "+a.syncErr.Error()))
+		}
+
+		// Devices only register themselves in shared folders
+		if !status.Git && len(status.Devices) == 0 {
+			lines = append(lines, Subtle.Render("Other devices: none yet"))
+		} else if !status.Git {
+			var devices []string
+			for _, device := range status.Devices {
+				devices = append(devices, fmt.Sprintf("%s (%s)", device.Name, formatAgo(device.LastSync)))
+			}
+			lines = append(lines, Subtle.Render("Other devices: ")+Normal.Render(strings.Join(devices, ", ")))
+		}
+	}
+
+	if len(status.Conflicts) > 0 && !a.syncEditing {
+		lines = append(lines, "", Subtitle.Render(fmt.Sprintf("Conflicts (%d)", len(status.Conflicts))))
+		for i, conflict := range status.Conflicts {
+			line := truncateString(fmt.Sprintf("%s: %s, edited on %s and %s",
+				conflict.Kind,
+				conflict.Kept.Title(),
+				a.deviceName(conflict.Kept.Device),
+				a.deviceName(conflict.Other.Device),
+			), a.width-14)
+			if i == a.conflictIndex {
+				lines = append(lines, Selected.Render(line))
+			} else {
+				lines = append(lines, Unselected.Render(line))
+			}
+		}
+		lines = append(lines, "", a.conflictDiff.View())
+	}
+
+	content := BoxStyle.Copy().Width(a.width - 4).Render(strings.Join(lines, "\n"))
+
+	folderHelp := "Shared folder"
+	if status.Git {
+		folderHelp = "Git remote"
+	}
+	keys := []HelpKey{
+		Help("Sync now", Keys.Sync),
+		Help(folderHelp, Keys.SyncFolder),
+	}
+	if len(status.Conflicts) > 0 {
+		keys = append(keys,
+			Help("Select", Keys.Up, Keys.Down),
+			Help("Keep", Keys.KeepVersion),
+			Help("Use other", Keys.OtherVersion),
+		)
+	}
+	keys = append(keys, Help("Back", Keys.Back))
+	if a.syncEditing {
+		keys = []HelpKey{
+			Help("Save", Keys.Confirm),
+			Help("Cancel", Keys.Cancel),
+		}
+	}
+	help := HelpView(keys...)
+
+	return fmt.Sprintf(
+		"%s\n%s\n\n%s\n\n%s",
+		title,
+		subtitle,
+		content,
+		help,
+	)
+}
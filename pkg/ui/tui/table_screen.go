@@ -2,38 +2,54 @@ package tui
 
 import (
 	"fmt"
+	"strings"
 
-	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/s42yt/thighpads/pkg/database"
+	"github.com/s42yt/thighpads/pkg/models"
 )
 
+// splitMinWidth is the terminal width from which the table screen shows a
+// preview of the selected entry next to the list
+const splitMinWidth = 100
+
+// paneMinWidth is how narrow dragging can make the list or the preview
+const paneMinWidth = 24
+
 func (a *App) updateTableScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "n":
-			a.screen = NewEntryScreen
-			a.entryTitleInput = TextInputField("Enter title")
-			a.entryTagsInput = TextInputField("Enter tags (comma-separated)")
-			a.entryContent = textarea.New()
-			a.entryContent.Placeholder = "Enter your content here..."
-			a.entryContent.SetWidth(a.width - 6)
-			a.entryContent.SetHeight(a.height - 20)
-			a.entryContent.Focus()
+		// While filtering, keys are typed into the filter
+		if a.list.FilterState() == list.Filtering {
+			break
+		}
+
+		switch {
+		case key.Matches(msg, Keys.New):
+			a.openNewEntryScreen()
 			return a, nil
-		case "e":
-			a.screen = ExportScreen
-			a.exportName = TextInputField(a.currentTable.Name)
-			a.exportLocation = -1
+		case key.Matches(msg, Keys.Export):
+			a.openExportScreen(false)
 			return a, nil
-		case "b":
-			a.screen = HomeScreen
-			a.loadTables()
+		case key.Matches(msg, Keys.Preview):
+			if a.width >= splitMinWidth {
+				a.hidePreview = !a.hidePreview
+			}
+			return a, nil
+		case key.Matches(msg, Keys.Back) && a.list.FilterState() == list.Unfiltered:
+			// With a filter applied, Esc clears the filter first
+			a.goBack()
 			return a, nil
-		case "d":
+		case key.Matches(msg, Keys.Forward):
+			a.goForward()
+			return a, nil
+		case key.Matches(msg, Keys.Delete):
 			if len(a.entries) > 0 {
 				selected, ok := a.list.SelectedItem().(Selectable)
 				if ok {
@@ -50,31 +66,17 @@ func (a *App) updateTableScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
 						return a, nil
 					} else {
 						a.errorMsg = "confirm_delete"
-						a.successMsg = "Press 'd' again to confirm deletion"
+						a.successMsg = fmt.Sprintf("Press '%s' again to confirm deletion", KeyName(Keys.Delete))
 						return a, nil
 					}
 				}
 			}
-		case "q", "ctrl+c":
+		case key.Matches(msg, Keys.Quit):
 			return a, tea.Quit
-		case "enter":
-			if len(a.entries) > 0 {
-				selected, ok := a.list.SelectedItem().(Selectable)
-				if ok {
-					for _, entry := range a.entries {
-						if entry.ID == selected.ID {
-							a.currentEntry = entry
-
-							a.entryViewport.Width = a.width - 6
-							a.entryViewport.Height = a.height - 16
-							a.entryViewport.SetContent(entry.Content)
-							a.entryViewport.GotoTop()
-
-							a.screen = ViewEntryScreen
-							return a, nil
-						}
-					}
-				}
+		case key.Matches(msg, Keys.Select):
+			if entry, ok := a.selectedEntry(); ok {
+				a.openEntry(entry)
+				return a, nil
 			}
 		}
 	}
@@ -86,44 +88,158 @@ func (a *App) updateTableScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return a, cmd
 }
 
+// selectedEntry is the entry highlighted in the list of the table screen
+func (a *App) selectedEntry() (models.Entry, bool) {
+	selected, ok := a.list.SelectedItem().(Selectable)
+	if !ok {
+		return models.Entry{}, false
+	}
+	for _, entry := range a.entries {
+		if entry.ID == selected.ID {
+			return entry, true
+		}
+	}
+	return models.Entry{}, false
+}
+
+// clampSplit keeps the width of the list next to the preview within bounds.
+// 0 means the default of two fifths of the screen.
+func (a *App) clampSplit(listWidth int) int {
+	if listWidth <= 0 {
+		listWidth = (a.width - 6) * 2 / 5
+	}
+	return max(min(listWidth, a.width-6-paneMinWidth), paneMinWidth)
+}
+
+// showPreview reports whether the table screen is split into list and preview
+func (a *App) showPreview() bool {
+	return a.width >= splitMinWidth && !a.hidePreview && len(a.entries) > 0
+}
+
+func (a *App) openTable(table models.Table) {
+	a.navigate(TableScreen)
+	a.currentTable = table
+	a.loadEntries()
+}
+
+func (a *App) openEntry(entry models.Entry) {
+	a.navigate(ViewEntryScreen)
+	a.showEntry(entry)
+}
+
+// showEntry makes entry the current one, scrolled to the top
+func (a *App) showEntry(entry models.Entry) {
+	a.currentEntry = entry
+	a.loadAttachments()
+
+	a.entryViewport = viewport.New(a.width-8, a.height-16)
+	a.entryViewport.KeyMap = Keys.ViewportKeyMap()
+	a.search = newEntrySearch()
+	a.copyingCode, a.copyNumber = false, ""
+	a.renderEntry()
+}
+
+func (a *App) openNewEntryScreen() {
+	a.navigate(NewEntryScreen)
+	a.entryTitleInput = TextInputField("Enter title")
+	a.entryTagsInput = TextInputField("Enter tags (comma-separated)")
+	a.entryLangInput = TextInputField("e.g. go or shell")
+	a.entryContent = newEditor("Enter your content here...")
+	a.focusEntryField("title")
+
+	a.startEntryForm(models.Entry{})
+}
+
 func (a *App) viewTableScreen() string {
 	title := Title.Copy().Width(a.width - 4).Render(a.currentTable.Name)
 	subtitle := Subtitle.Copy().Width(a.width - 4).Render(fmt.Sprintf("Created by %s on %s",
 		a.currentTable.Author,
 		a.currentTable.CreatedAt.Format("Jan 02, 2006")))
+	header := title + "\n" + subtitle
+
+	previewHelp := ""
+	if a.width >= splitMinWidth && len(a.entries) > 0 {
+		previewHelp = "Hide preview"
+		if a.hidePreview {
+			previewHelp = "Show preview"
+		}
+	}
+
+	help := HelpView(
+		Help("Navigate", Keys.Up, Keys.Down),
+		Help("View entry", Keys.Select),
+		Help("New entry", Keys.New),
+		Help("Delete entry", Keys.Delete),
+		Help("Export table", Keys.Export),
+		Help(previewHelp, Keys.Preview),
+		Help("Back", Keys.Back),
+		a.forwardHelp(),
+		Help("Quit", Keys.Quit),
+	)
+
+	parts := []string{header, help}
+	if a.errorMsg == "confirm_delete" {
+		warningBox := Warning.Copy().Width(a.width - 6).Render(fmt.Sprintf("Press '%s' again to confirm deletion", KeyName(Keys.Delete)))
+		header += "\n\n" + warningBox
+		parts = append(parts, warningBox)
+		a.errorMsg = "confirm_delete"
+	}
 
 	var content string
 	if len(a.entries) == 0 {
 		content = BoxStyle.Copy().Width(a.width - 4).Render(
-			Normal.Render("This table is empty. Press 'n' to create your first entry."))
+			Normal.Render(fmt.Sprintf("This table is empty. Press '%s' to create your first entry.", KeyName(Keys.New))))
+	} else if a.showPreview() {
+		// Both boxes together are as wide as the single one
+		listWidth := a.clampSplit(a.splitWidth)
+		previewWidth := a.width - 6 - listWidth
+
+		a.list.SetWidth(listWidth - 2)
+		a.list.SetHeight(a.contentHeight(parts...))
+		list := BoxStyle.Copy().Width(listWidth).Render(a.list.View())
+
+		// The preview box is as high as the list box, borders included
+		height := lipgloss.Height(list) - 2
+		preview := BoxStyle.Copy().Width(previewWidth).Height(height).Render(a.viewEntryPreview(previewWidth-4, height-2))
+		content = lipgloss.JoinHorizontal(lipgloss.Top, markZone("split", list), markZone("preview", preview))
 	} else {
 
 		a.list.SetWidth(a.width - 6)
-		a.list.SetHeight(a.height - 12)
+		a.list.SetHeight(a.contentHeight(parts...))
 		content = BoxStyle.Copy().Width(a.width - 4).Render(a.list.View())
 	}
 
-	if a.errorMsg == "confirm_delete" {
-		warningBox := Warning.Copy().Width(a.width - 6).Render("Press 'd' again to confirm deletion")
-		content = warningBox + "\n\n" + content
-		a.errorMsg = "confirm_delete"
-	}
-
-	help := HelpView(map[string]string{
-		"↑/↓":   "Navigate",
-		"Enter": "View entry",
-		"n":     "New entry",
-		"d":     "Delete entry",
-		"e":     "Export table",
-		"b":     "Back to home",
-		"q":     "Quit",
-	})
-
 	return fmt.Sprintf(
-		"%s\n%s\n\n%s\n\n%s",
-		title,
-		subtitle,
+		"%s\n\n%s\n\n%s",
+		header,
 		content,
 		help,
 	)
 }
+
+// viewEntryPreview renders the title, tags and the start of the content of the
+// selected entry, wrapped to width and cut to height lines
+func (a *App) viewEntryPreview(width, height int) string {
+	entry, ok := a.selectedEntry()
+	if !ok {
+		return Subtle.Render("No entry selected")
+	}
+
+	lines := []string{
+		Subtitle.Render(truncateString(entry.Title, width)),
+		Subtle.Render(truncateString("Tags: "+entry.Tags, width)),
+		"",
+	}
+	if strings.TrimSpace(entry.Content) == "" {
+		lines = append(lines, Subtle.Render("This entry is empty."))
+	} else {
+		wrapped := lipgloss.NewStyle().Width(width).Render(entry.Content)
+		lines = append(lines, strings.Split(Normal.Render(wrapped), "\n")...)
+	}
+
+	if len(lines) > height {
+		more := len(lines) - height + 1
+		lines = append(lines[:height-1], Subtle.Render(fmt.Sprintf("… %d more lines, press '%s' to read all", more, KeyName(Keys.Select))))
+	}
+	return strings.Join(lines, "\n")
+}
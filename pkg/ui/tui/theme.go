@@ -2,15 +2,144 @@ package tui
 
 import "github.com/charmbracelet/lipgloss"
 
+// DefaultThemeName is used when config.json does not name a theme
+const DefaultThemeName = "auto"
+
+// Theme holds the colors the TUI is drawn with. Colors may be adaptive, i.e.
+// pick a light or dark variant depending on the terminal's background.
+type Theme struct {
+	Name string
+	// Source is the file a user theme was loaded from, empty for built-ins
+	Source string
+
+	Accent       lipgloss.TerminalColor
+	Secondary    lipgloss.TerminalColor
+	Text         lipgloss.TerminalColor
+	Subtle       lipgloss.TerminalColor
+	Error        lipgloss.TerminalColor
+	Success      lipgloss.TerminalColor
+	Warning      lipgloss.TerminalColor
+	Background   lipgloss.TerminalColor
+	SelectedText lipgloss.TerminalColor
+}
+
+// BuiltinThemes are always available, in the order the theme screen lists
+// them
+var BuiltinThemes = []Theme{
+	{
+		Name:         "auto",
+		Accent:       lipgloss.AdaptiveColor{Light: "#5A3FC0", Dark: "#7D56F4"},
+		Secondary:    lipgloss.AdaptiveColor{Light: "#7B5CD6", Dark: "#AE88FF"},
+		Text:         lipgloss.AdaptiveColor{Light: "#1A1A1A", Dark: "#FFFFFF"},
+		Subtle:       lipgloss.AdaptiveColor{Light: "#6B6B6B", Dark: "#888888"},
+		Error:        lipgloss.AdaptiveColor{Light: "#C62828", Dark: "#FF5555"},
+		Success:      lipgloss.AdaptiveColor{Light: "#2E7D32", Dark: "#55FF55"},
+		Warning:      lipgloss.AdaptiveColor{Light: "#B26A00", Dark: "#FFAA55"},
+		Background:   lipgloss.NoColor{},
+		SelectedText: lipgloss.Color("#FFFFFF"),
+	},
+	{
+		Name:         "dark",
+		Accent:       lipgloss.Color("#7D56F4"),
+		Secondary:    lipgloss.Color("#AE88FF"),
+		Text:         lipgloss.Color("#FFFFFF"),
+		Subtle:       lipgloss.Color("#888888"),
+		Error:        lipgloss.Color("#FF5555"),
+		Success:      lipgloss.Color("#55FF55"),
+		Warning:      lipgloss.Color("#FFAA55"),
+		Background:   lipgloss.Color("#222222"),
+		SelectedText: lipgloss.Color("#FFFFFF"),
+	},
+	{
+		Name:         "light",
+		Accent:       lipgloss.Color("#5A3FC0"),
+		Secondary:    lipgloss.Color("#7B5CD6"),
+		Text:         lipgloss.Color("#1A1A1A"),
+		Subtle:       lipgloss.Color("#6B6B6B"),
+		Error:        lipgloss.Color("#C62828"),
+		Success:      lipgloss.Color("#2E7D32"),
+		Warning:      lipgloss.Color("#B26A00"),
+		Background:   lipgloss.NoColor{},
+		SelectedText: lipgloss.Color("#FFFFFF"),
+	},
+	{
+		Name:         "high-contrast",
+		Accent:       lipgloss.AdaptiveColor{Light: "#0000CC", Dark: "#FFFF00"},
+		Secondary:    lipgloss.AdaptiveColor{Light: "#006666", Dark: "#00FFFF"},
+		Text:         lipgloss.AdaptiveColor{Light: "#000000", Dark: "#FFFFFF"},
+		Subtle:       lipgloss.AdaptiveColor{Light: "#333333", Dark: "#D0D0D0"},
+		Error:        lipgloss.AdaptiveColor{Light: "#CC0000", Dark: "#FF3333"},
+		Success:      lipgloss.AdaptiveColor{Light: "#006600", Dark: "#00FF00"},
+		Warning:      lipgloss.AdaptiveColor{Light: "#994C00", Dark: "#FFA500"},
+		Background:   lipgloss.NoColor{},
+		SelectedText: lipgloss.AdaptiveColor{Light: "#FFFFFF", Dark: "#000000"},
+	},
+	{
+		Name:         "solarized",
+		Accent:       lipgloss.Color("#268BD2"),
+		Secondary:    lipgloss.Color("#2AA198"),
+		Text:         lipgloss.AdaptiveColor{Light: "#586E75", Dark: "#93A1A1"},
+		Subtle:       lipgloss.AdaptiveColor{Light: "#93A1A1", Dark: "#657B83"},
+		Error:        lipgloss.Color("#DC322F"),
+		Success:      lipgloss.Color("#859900"),
+		Warning:      lipgloss.Color("#CB4B16"),
+		Background:   lipgloss.AdaptiveColor{Light: "#FDF6E3", Dark: "#002B36"},
+		SelectedText: lipgloss.Color("#FDF6E3"),
+	},
+}
+
+// The styles below are rebuilt by ApplyTheme; the colors are those of the
+// current theme.
 var (
-	accentColor     = lipgloss.Color("#7D56F4")
-	secondaryColor  = lipgloss.Color("#AE88FF")
-	textColor       = lipgloss.Color("#FFFFFF")
-	subtleColor     = lipgloss.Color("#888888")
-	errorColor      = lipgloss.Color("#FF5555")
-	successColor    = lipgloss.Color("#55FF55")
-	warningColor    = lipgloss.Color("#FFAA55")
-	backgroundColor = lipgloss.Color("#222222")
+	currentTheme Theme
+
+	accentColor     lipgloss.TerminalColor
+	secondaryColor  lipgloss.TerminalColor
+	textColor       lipgloss.TerminalColor
+	subtleColor     lipgloss.TerminalColor
+	errorColor      lipgloss.TerminalColor
+	successColor    lipgloss.TerminalColor
+	warningColor    lipgloss.TerminalColor
+	backgroundColor lipgloss.TerminalColor
+
+	Title           lipgloss.Style
+	Subtitle        lipgloss.Style
+	Normal          lipgloss.Style
+	Subtle          lipgloss.Style
+	Success         lipgloss.Style
+	Error           lipgloss.Style
+	Warning         lipgloss.Style
+	Selected        lipgloss.Style
+	Unselected      lipgloss.Style
+	Match           lipgloss.Style
+	CurrentMatch    lipgloss.Style
+	CodeKeyword     lipgloss.Style
+	CodeString      lipgloss.Style
+	CodeNumber      lipgloss.Style
+	CodeComment     lipgloss.Style
+	CodeFence       lipgloss.Style
+	BoxStyle        lipgloss.Style
+	FocusedBoxStyle lipgloss.Style
+	AppStyle        lipgloss.Style
+)
+
+func init() {
+	ApplyTheme(BuiltinThemes[0])
+}
+
+// ApplyTheme switches every style to the colors of theme. Views pick it up
+// the next time they render.
+func ApplyTheme(theme Theme) {
+	currentTheme = theme
+
+	accentColor = theme.Accent
+	secondaryColor = theme.Secondary
+	textColor = theme.Text
+	subtleColor = theme.Subtle
+	errorColor = theme.Error
+	successColor = theme.Success
+	warningColor = theme.Warning
+	backgroundColor = theme.Background
 
 	Title = lipgloss.NewStyle().
 		Foreground(accentColor).
@@ -20,8 +149,8 @@ var (
 		Padding(0, 2)
 
 	Subtitle = lipgloss.NewStyle().
-			Foreground(secondaryColor).
-			Bold(true)
+		Foreground(secondaryColor).
+		Bold(true)
 
 	Normal = lipgloss.NewStyle().
 		Foreground(textColor)
@@ -39,29 +168,55 @@ var (
 		Foreground(warningColor)
 
 	Selected = lipgloss.NewStyle().
-			Foreground(textColor).
-			Background(accentColor).
-			Bold(true).
-			Padding(0, 1)
+		Foreground(theme.SelectedText).
+		Background(accentColor).
+		Bold(true).
+		Padding(0, 1)
 
 	Unselected = lipgloss.NewStyle().
-			Foreground(subtleColor).
-			Padding(0, 1)
+		Foreground(subtleColor).
+		Padding(0, 1)
+
+	Match = lipgloss.NewStyle().
+		Foreground(theme.SelectedText).
+		Background(warningColor)
+
+	CurrentMatch = lipgloss.NewStyle().
+		Foreground(theme.SelectedText).
+		Background(accentColor).
+		Bold(true)
+
+	CodeKeyword = lipgloss.NewStyle().
+		Foreground(secondaryColor).
+		Bold(true)
+
+	CodeString = lipgloss.NewStyle().
+		Foreground(successColor)
+
+	CodeNumber = lipgloss.NewStyle().
+		Foreground(warningColor)
+
+	CodeComment = lipgloss.NewStyle().
+		Foreground(subtleColor).
+		Italic(true)
+
+	CodeFence = lipgloss.NewStyle().
+		Foreground(subtleColor)
 
 	BoxStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(accentColor).
-			Padding(1, 2)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(accentColor).
+		Padding(1, 2)
 
 	FocusedBoxStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(secondaryColor).
-			Padding(1, 2)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(secondaryColor).
+		Padding(1, 2)
 
 	AppStyle = lipgloss.NewStyle().
-			Background(backgroundColor).
-			Padding(1, 2)
-)
+		Background(backgroundColor).
+		Padding(1, 2)
+}
 
 // Helper function to apply width to styles
 func WithWidth(style lipgloss.Style, width int) lipgloss.Style {
@@ -0,0 +1,297 @@
+package tui
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/s42yt/thighpads/pkg/config"
+)
+
+// themeColors are the colors of a theme file. Empty values are taken from the
+// base theme.
+type themeColors struct {
+	Accent       string `json:"accent"`
+	Secondary    string `json:"secondary"`
+	Text         string `json:"text"`
+	Subtle       string `json:"subtle"`
+	Error        string `json:"error"`
+	Success      string `json:"success"`
+	Warning      string `json:"warning"`
+	Background   string `json:"background"`
+	SelectedText string `json:"selectedText"`
+}
+
+// themeFile is a user theme in themes/. Top level colors are used on any
+// terminal, the light and dark sections override them depending on the
+// terminal's background.
+type themeFile struct {
+	Name string `json:"name"`
+	// Base is the built-in theme that colors left out are taken from
+	Base string `json:"base"`
+	themeColors
+	Light themeColors `json:"light"`
+	Dark  themeColors `json:"dark"`
+}
+
+// fields maps the keys of a theme file to the colors
+func (c *themeColors) fields() map[string]*string {
+	return map[string]*string{
+		"accent":       &c.Accent,
+		"secondary":    &c.Secondary,
+		"text":         &c.Text,
+		"subtle":       &c.Subtle,
+		"error":        &c.Error,
+		"success":      &c.Success,
+		"warning":      &c.Warning,
+		"background":   &c.Background,
+		"selectedText": &c.SelectedText,
+	}
+}
+
+// LoadThemes returns the built-in themes followed by the user themes sorted
+// by name. Theme files that cannot be read are skipped; the second result
+// describes what was wrong with them.
+func LoadThemes() ([]Theme, []string) {
+	themes := append([]Theme(nil), BuiltinThemes...)
+
+	themesPath, err := config.GetThemesPath()
+	if err != nil {
+		return themes, []string{err.Error()}
+	}
+
+	files, err := os.ReadDir(themesPath)
+	if os.IsNotExist(err) {
+		return themes, nil
+	} else if err != nil {
+		return themes, []string{err.Error()}
+	}
+
+	var userThemes []Theme
+	var problems []string
+	for _, file := range files {
+		ext := strings.ToLower(filepath.Ext(file.Name()))
+		if file.IsDir() || (ext != ".json" && ext != ".toml") {
+			continue
+		}
+
+		path := filepath.Join(themesPath, file.Name())
+		theme, err := loadThemeFile(path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", file.Name(), err))
+			continue
+		}
+		userThemes = append(userThemes, theme)
+	}
+
+	sort.Slice(userThemes, func(i, j int) bool { return userThemes[i].Name < userThemes[j].Name })
+	return append(themes, userThemes...), problems
+}
+
+// FindTheme looks up a theme by name, ignoring case
+func FindTheme(themes []Theme, name string) (Theme, bool) {
+	for _, theme := range themes {
+		if strings.EqualFold(theme.Name, name) {
+			return theme, true
+		}
+	}
+	return Theme{}, false
+}
+
+func loadThemeFile(path string) (Theme, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Theme{}, err
+	}
+
+	var file themeFile
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		err = parseThemeTOML(content, &file)
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	}
+	if err != nil {
+		return Theme{}, err
+	}
+
+	if file.Name == "" {
+		file.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if _, builtin := FindTheme(BuiltinThemes, file.Name); builtin {
+		return Theme{}, fmt.Errorf("the name %q is taken by a built-in theme", file.Name)
+	}
+
+	baseName := file.Base
+	if baseName == "" {
+		baseName = DefaultThemeName
+	}
+	base, ok := FindTheme(BuiltinThemes, baseName)
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown base theme %q", baseName)
+	}
+
+	theme := Theme{Name: file.Name, Source: path}
+	colors := []struct {
+		target *lipgloss.TerminalColor
+		base   lipgloss.TerminalColor
+		all    string
+		light  string
+		dark   string
+	}{
+		{&theme.Accent, base.Accent, file.Accent, file.Light.Accent, file.Dark.Accent},
+		{&theme.Secondary, base.Secondary, file.Secondary, file.Light.Secondary, file.Dark.Secondary},
+		{&theme.Text, base.Text, file.Text, file.Light.Text, file.Dark.Text},
+		{&theme.Subtle, base.Subtle, file.Subtle, file.Light.Subtle, file.Dark.Subtle},
+		{&theme.Error, base.Error, file.Error, file.Light.Error, file.Dark.Error},
+		{&theme.Success, base.Success, file.Success, file.Light.Success, file.Dark.Success},
+		{&theme.Warning, base.Warning, file.Warning, file.Light.Warning, file.Dark.Warning},
+		{&theme.Background, base.Background, file.Background, file.Light.Background, file.Dark.Background},
+		{&theme.SelectedText, base.SelectedText, file.SelectedText, file.Light.SelectedText, file.Dark.SelectedText},
+	}
+	for _, color := range colors {
+		for _, value := range []string{color.all, color.light, color.dark} {
+			if !validColor(value) {
+				return Theme{}, fmt.Errorf("invalid color %q", value)
+			}
+		}
+		*color.target = themeColor(color.base, color.all, color.light, color.dark)
+	}
+
+	return theme, nil
+}
+
+// themeColor combines the colors given for a theme color. "none" turns the
+// color off, which is mostly useful for the background.
+func themeColor(base lipgloss.TerminalColor, all, light, dark string) lipgloss.TerminalColor {
+	if all == "" && light == "" && dark == "" {
+		return base
+	}
+
+	if light == "" {
+		light = all
+	}
+	if dark == "" {
+		dark = all
+	}
+	if light == "" || dark == "" {
+		// Only one variant is given; the other one comes from the base theme
+		adaptive, ok := base.(lipgloss.AdaptiveColor)
+		if !ok {
+			adaptive = lipgloss.AdaptiveColor{Light: colorString(base), Dark: colorString(base)}
+		}
+		if light == "" {
+			light = adaptive.Light
+		}
+		if dark == "" {
+			dark = adaptive.Dark
+		}
+	}
+
+	if light == dark {
+		return singleColor(light)
+	}
+	// An empty variant of an adaptive color is left uncolored
+	return lipgloss.AdaptiveColor{Light: strings.TrimSuffix(light, "none"), Dark: strings.TrimSuffix(dark, "none")}
+}
+
+func singleColor(value string) lipgloss.TerminalColor {
+	if value == "none" || value == "" {
+		return lipgloss.NoColor{}
+	}
+	return lipgloss.Color(value)
+}
+
+func colorString(color lipgloss.TerminalColor) string {
+	if color, ok := color.(lipgloss.Color); ok {
+		return string(color)
+	}
+	return ""
+}
+
+// validColor accepts hex colors, ANSI color numbers and "none"
+func validColor(value string) bool {
+	if value == "" || value == "none" {
+		return true
+	}
+	if strings.HasPrefix(value, "#") {
+		hex := value[1:]
+		if len(hex) != 3 && len(hex) != 6 {
+			return false
+		}
+		_, err := strconv.ParseUint(hex, 16, 32)
+		return err == nil
+	}
+	n, err := strconv.Atoi(value)
+	return err == nil && n >= 0 && n <= 255
+}
+
+// parseThemeTOML reads the small part of TOML theme files need: string
+// values, comments and the [light] and [dark] tables
+func parseThemeTOML(content []byte, file *themeFile) error {
+	fields := file.themeColors.fields()
+	fields["name"] = &file.Name
+	fields["base"] = &file.Base
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			section := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(stripComment(line), "["), "]"))
+			switch section {
+			case "light":
+				fields = file.Light.fields()
+			case "dark":
+				fields = file.Dark.fields()
+			default:
+				return fmt.Errorf("line %d: unknown section [%s]", lineNumber, section)
+			}
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return fmt.Errorf("line %d: expected key = \"value\"", lineNumber)
+		}
+		key = strings.TrimSpace(key)
+		value, err := strconv.Unquote(stripComment(strings.TrimSpace(value)))
+		if err != nil {
+			return fmt.Errorf("line %d: value of %s must be a quoted string", lineNumber, key)
+		}
+
+		field, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("line %d: unknown key %s", lineNumber, key)
+		}
+		*field = value
+	}
+	return scanner.Err()
+}
+
+// stripComment drops a trailing # comment that follows a quoted value
+func stripComment(s string) string {
+	if strings.HasPrefix(s, `"`) {
+		if end := strings.Index(s[1:], `"`); end >= 0 {
+			return s[:end+2]
+		}
+		return s
+	}
+	if i := strings.Index(s, "#"); i >= 0 {
+		return strings.TrimSpace(s[:i])
+	}
+	return s
+}
@@ -0,0 +1,153 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/s42yt/thighpads/pkg/config"
+)
+
+// applyConfiguredTheme switches to the theme named in config.json, falling
+// back to the default theme when it does not exist (anymore)
+func (a *App) applyConfiguredTheme() {
+	name := a.config.Theme
+	if name == "" {
+		name = DefaultThemeName
+	}
+
+	themes, _ := LoadThemes()
+	theme, ok := FindTheme(themes, name)
+	if !ok {
+		theme, _ = FindTheme(themes, DefaultThemeName)
+		a.errorMsg = fmt.Sprintf("Theme %q not found, using %s. Press '%s' to pick another one.", name, DefaultThemeName, KeyName(Keys.Theme))
+	}
+	ApplyTheme(theme)
+}
+
+func (a *App) openThemeScreen() {
+	a.navigate(ThemeScreen)
+	a.themeSaved = currentTheme
+	a.reloadThemes()
+}
+
+// reloadThemes reads the theme files again, so that they can be tweaked
+// while the screen is open
+func (a *App) reloadThemes() {
+	a.themes, a.themeProblems = LoadThemes()
+
+	a.themeIndex = 0
+	for i, theme := range a.themes {
+		if theme.Name == currentTheme.Name {
+			a.themeIndex = i
+			ApplyTheme(theme)
+			break
+		}
+	}
+}
+
+// useTheme switches to theme and saves it as the theme to start with
+func (a *App) useTheme(theme Theme) bool {
+	ApplyTheme(theme)
+	a.config.Theme = theme.Name
+	if err := config.SaveConfig(a.config); err != nil {
+		a.errorMsg = err.Error()
+		return false
+	}
+	a.successMsg = fmt.Sprintf("Theme set to %s.", theme.Name)
+	return true
+}
+
+func (a *App) updateThemeScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, Keys.Up):
+			if a.themeIndex > 0 {
+				a.themeIndex--
+				ApplyTheme(a.themes[a.themeIndex])
+			}
+		case key.Matches(msg, Keys.Down):
+			if a.themeIndex < len(a.themes)-1 {
+				a.themeIndex++
+				ApplyTheme(a.themes[a.themeIndex])
+			}
+		case key.Matches(msg, Keys.Reload):
+			a.reloadThemes()
+			a.successMsg = "Reloaded the theme files."
+		case key.Matches(msg, Keys.Select):
+			if a.useTheme(a.themes[a.themeIndex]) {
+				a.goBack()
+			}
+		case key.Matches(msg, Keys.Back):
+			ApplyTheme(a.themeSaved)
+			a.goBack()
+		case key.Matches(msg, Keys.Quit):
+			return a, tea.Quit
+		}
+	}
+
+	return a, nil
+}
+
+func (a *App) viewThemeScreen() string {
+	title := Title.Copy().Width(a.width - 4).Render("Theme")
+	subtitle := Subtitle.Copy().Width(a.width - 4).Render("The preview follows the highlighted theme")
+
+	var names []string
+	for i, theme := range a.themes {
+		suffix := ""
+		if theme.Source == "" {
+			suffix = Subtle.Render(" (built-in)")
+		}
+		if i == a.themeIndex {
+			names = append(names, Selected.Render(theme.Name)+suffix)
+		} else {
+			names = append(names, Unselected.Render(theme.Name)+suffix)
+		}
+	}
+	list := strings.Join(names, "\n")
+
+	previewWidth := a.width - lipgloss.Width(list) - 20
+	if previewWidth < 30 {
+		previewWidth = 30
+	}
+	preview := BoxStyle.Copy().Width(previewWidth).Render(strings.Join([]string{
+		Subtitle.Render("Heading"),
+		Normal.Render("Regular text of an entry."),
+		Subtle.Render("Tags: notes, ideas"),
+		Selected.Render("Selected item") + " " + Unselected.Render("Other item"),
+		Success.Render("Saved.") + " " + Warning.Render("1 sync conflict") + " " + Error.Render("Error: file not found"),
+	}, "\n"))
+
+	content := lipgloss.JoinHorizontal(lipgloss.Top, list, "    ", preview)
+
+	var lines []string
+	if theme := a.themes[a.themeIndex]; theme.Source != "" {
+		lines = append(lines, Subtle.Render("File: "+theme.Source))
+	} else if themesPath, err := config.GetThemesPath(); err == nil {
+		lines = append(lines, Subtle.Render("Add your own themes as .toml or .json files in "+themesPath))
+	}
+	for _, problem := range a.themeProblems {
+		lines = append(lines, Error.Render(truncateString("Skipped "+problem, a.width-14)))
+	}
+
+	box := BoxStyle.Copy().Width(a.width - 4).Render(content + "\n\n" + strings.Join(lines, "\n"))
+
+	help := HelpView(
+		Help("Preview", Keys.Up, Keys.Down),
+		Help("Use theme", Keys.Select),
+		Help("Reload files", Keys.Reload),
+		Help("Back", Keys.Back),
+	)
+
+	return fmt.Sprintf(
+		"%s\n%s\n\n%s\n\n%s",
+		title,
+		subtitle,
+		box,
+		help,
+	)
+}
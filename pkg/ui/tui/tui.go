@@ -2,15 +2,18 @@ package tui
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
-	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/s42yt/thighpads/pkg/config"
+	"github.com/s42yt/thighpads/pkg/data"
 	"github.com/s42yt/thighpads/pkg/database"
 	"github.com/s42yt/thighpads/pkg/models"
+	"github.com/s42yt/thighpads/pkg/syncer"
 )
 
 type Screen int
@@ -25,12 +28,10 @@ const (
 	EditEntryScreen
 	ImportScreen
 	ExportScreen
-)
-
-const (
-	DefaultExport = iota
-	DesktopExport
-	BothExport
+	MergeScreen
+	AttachmentsScreen
+	SyncScreen
+	ThemeScreen
 )
 
 type App struct {
@@ -47,13 +48,58 @@ type App struct {
 	tableNameInput  textinput.Model
 	entryTitleInput textinput.Model
 	entryTagsInput  textinput.Model
-	entryContent    textarea.Model
+	entryLangInput  textinput.Model
+	entryContent    editor
 	entryViewport   viewport.Model
+	search          entrySearch
+	copyingCode     bool
+	copyNumber      string
 	importPathInput textinput.Model
+	importPicker    filePicker
+	importPreview   *data.ImportPreview
+	importTabular   *data.TabularData
+	importMapping   data.ColumnMapping
+	importField     int
+	mergePlan       *data.MergePlan
+	mergeIndex      int
+	mergeViewport   viewport.Model
+	attachmentIndex int
+	attachmentMode  int
+	attachmentInput textinput.Model
+	syncStatus      syncer.Status
+	syncing         bool
+	syncErr         error
+	syncGeneration  int
+	syncEditing     bool
+	syncFolderInput textinput.Model
+	conflictIndex   int
+	conflictDiff    viewport.Model
 	exportName      textinput.Model
+	exportPathInput textinput.Model
+	exportFocus     int
+	exportPolicy    data.OverwritePolicy
+	exportDests     []data.ExportDestination
+	exportDest      int
 	errorMsg        string
 	successMsg      string
-	exportLocation  int
+	exportFormat    data.ExportFormat
+	exportAll       bool
+	exportTableIDs  []uint
+	markedTables    map[uint]bool
+	themes          []Theme
+	themeIndex      int
+	themeProblems   []string
+	themeSaved      Theme
+	palette         commandPalette
+	hidePreview     bool
+	history         []location
+	future          []location
+	entryBaseline   models.Entry
+	savedDraft      entryDraft
+	pendingLeave    func(a *App) tea.Cmd
+	recovering      bool
+	splitWidth      int
+	resizing        bool
 	bottomGap       int
 }
 
@@ -92,19 +138,33 @@ func Initialize() (*tea.Program, error) {
 		config:    cfg,
 		bottomGap: 4,
 	}
+	app.applyConfiguredTheme()
+	if problems := LoadKeyMap(); len(problems) > 0 {
+		app.errorMsg = config.KeymapFileName + ": " + strings.Join(problems, "; ")
+	}
 
 	if app.screen == SetupScreen {
 		app.usernameInput = TextInputField("Enter your username")
 	} else {
 		app.loadTables()
+		app.recoverDraft()
 	}
 
-	p := tea.NewProgram(app, tea.WithAltScreen())
+	if status, err := syncer.GetStatus(); err == nil {
+		app.syncStatus = status
+	}
+
+	options := []tea.ProgramOption{tea.WithAltScreen()}
+	if !cfg.DisableMouse {
+		options = append(options, tea.WithMouseCellMotion())
+	}
+	p := tea.NewProgram(app, options...)
 	return p, nil
 }
 
+// Init merges the changes of other devices on startup when sync is set up
 func (a *App) Init() tea.Cmd {
-	return nil
+	return tea.Batch(a.startSync(), draftTick())
 }
 
 func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -115,6 +175,10 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.successMsg = ""
 	}
 
+	if cmd, handled := a.handleSyncMsg(msg); handled {
+		return a, cmd
+	}
+
 	if msg, ok := msg.(tea.WindowSizeMsg); ok {
 		a.width = msg.Width
 		a.height = msg.Height
@@ -127,19 +191,41 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Update viewport dimensions
 		if a.screen == ViewEntryScreen {
-			a.entryViewport.Width = msg.Width - 6
+			a.entryViewport.Width = msg.Width - 8
 			a.entryViewport.Height = msg.Height - 16
+			a.renderEntry()
+		}
+
+		if a.screen == MergeScreen && a.mergePlan != nil {
+			a.refreshMergeDiff()
 		}
 
-		// Update textarea dimensions
-		if a.screen == NewEntryScreen || a.screen == EditEntryScreen {
-			a.entryContent.SetWidth(msg.Width - 6)
-			a.entryContent.SetHeight(msg.Height - 20)
+		if a.screen == SyncScreen {
+			a.refreshConflictDiff()
 		}
 
 		return a, nil
 	}
 
+	if msg, ok := msg.(tea.MouseMsg); ok {
+		return a.updateMouse(msg)
+	}
+
+	if _, ok := msg.(draftTickMsg); ok {
+		if a.entryFormOpen() {
+			a.saveDraft()
+		}
+		return a, draftTick()
+	}
+
+	if a.palette.open {
+		return a.updatePalette(msg)
+	}
+	if msg, ok := msg.(tea.KeyMsg); ok && a.screen != SetupScreen && key.Matches(msg, Keys.Palette) {
+		a.openPalette()
+		return a, textinput.Blink
+	}
+
 	switch a.screen {
 	case SetupScreen:
 		return a.updateSetupScreen(msg)
@@ -159,6 +245,14 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return a.updateImportScreen(msg)
 	case ExportScreen:
 		return a.updateExportScreen(msg)
+	case MergeScreen:
+		return a.updateMergeScreen(msg)
+	case AttachmentsScreen:
+		return a.updateAttachmentsScreen(msg)
+	case SyncScreen:
+		return a.updateSyncScreen(msg)
+	case ThemeScreen:
+		return a.updateThemeScreen(msg)
 	}
 
 	return a, cmd
@@ -186,6 +280,22 @@ func (a *App) View() string {
 		view = a.viewImportScreen()
 	case ExportScreen:
 		view = a.viewExportScreen()
+	case MergeScreen:
+		view = a.viewMergeScreen()
+	case AttachmentsScreen:
+		view = a.viewAttachmentsScreen()
+	case SyncScreen:
+		view = a.viewSyncScreen()
+	case ThemeScreen:
+		view = a.viewThemeScreen()
+	}
+
+	if crumb := a.breadcrumb(); crumb != "" {
+		view = crumb + "\n" + view
+	}
+
+	if a.palette.open {
+		view = overlay(view, a.viewPalette(), a.width-4)
 	}
 
 	statusView := ""
@@ -196,7 +306,7 @@ func (a *App) View() string {
 		statusView = "\n" + SuccessView(a.successMsg)
 	}
 
-	return AppStyle.Render(view + statusView)
+	return scanZones(AppStyle.Render(view+statusView), a.height)
 }
 
 func (a *App) loadTables() {
@@ -204,6 +314,15 @@ func (a *App) loadTables() {
 	if err == nil {
 		a.tables = tables
 
+		// Drop marks of tables that no longer exist
+		marked := map[uint]bool{}
+		for _, table := range tables {
+			if a.markedTables[table.ID] {
+				marked[table.ID] = true
+			}
+		}
+		a.markedTables = marked
+
 		items := make([]list.Item, len(tables))
 		for i, table := range tables {
 			desc := fmt.Sprintf("Created by %s on %s",
@@ -214,6 +333,7 @@ func (a *App) loadTables() {
 				Title:       table.Name,
 				Description: desc,
 				ID:          table.ID,
+				Marked:      a.markedTables[table.ID],
 			}
 		}
 
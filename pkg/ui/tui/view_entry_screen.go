@@ -2,11 +2,15 @@ package tui
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/atotto/clipboard"
-	"github.com/charmbracelet/bubbles/textarea"
-	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/s42yt/thighpads/pkg/data"
 )
 
 func (a *App) updateViewEntryScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -14,31 +18,54 @@ func (a *App) updateViewEntryScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "e":
-			a.screen = EditEntryScreen
-			a.entryTitleInput = TextInputField(a.currentEntry.Title)
-			a.entryTagsInput = TextInputField(a.currentEntry.Tags)
-
-			a.entryContent = textarea.New()
-			a.entryContent.Placeholder = "Enter your content here..."
-			a.entryContent.SetValue(a.currentEntry.Content)
-			a.entryContent.SetWidth(a.width - 6)
-			a.entryContent.SetHeight(a.height - 20)
-			a.entryContent.Focus()
+		if a.search.typing {
+			return a.updateEntrySearch(msg)
+		}
+		if a.copyingCode {
+			return a.updateCopyPrompt(msg)
+		}
+
+		switch {
+		case key.Matches(msg, Keys.Find):
+			return a, a.startEntrySearch()
+		case key.Matches(msg, Keys.NextMatch) && a.search.active():
+			a.jumpToMatch(1)
+			return a, nil
+		case key.Matches(msg, Keys.PreviousMatch) && a.search.active():
+			a.jumpToMatch(-1)
+			return a, nil
+		case key.Matches(msg, Keys.MatchCase) && a.search.active():
+			a.search.matchCase = !a.search.matchCase
+			a.findMatches()
+			return a, nil
+		case key.Matches(msg, Keys.UseRegex) && a.search.active():
+			a.search.regex = !a.search.regex
+			a.findMatches()
 			return a, nil
-		case "c":
-			err := clipboard.WriteAll(a.currentEntry.Content)
-			if err != nil {
-				a.errorMsg = "Failed to copy to clipboard: " + err.Error()
-			} else {
-				a.successMsg = "Entry content copied to clipboard."
+		case key.Matches(msg, Keys.Cancel) && a.search.active():
+			a.stopEntrySearch()
+			return a, nil
+		case key.Matches(msg, Keys.Edit):
+			a.openEditEntryScreen()
+			return a, nil
+		case key.Matches(msg, Keys.Copy):
+			// With code blocks to choose from, ask which one to copy
+			if len(data.CodeBlocks(a.currentEntry.Content)) > 0 {
+				a.copyingCode, a.copyNumber = true, ""
+				return a, nil
 			}
+			a.copyEntryContent()
+			return a, nil
+		case key.Matches(msg, Keys.Attachments):
+			a.openAttachmentsScreen()
 			return a, nil
-		case "b":
-			a.screen = TableScreen
+		case key.Matches(msg, Keys.Back):
+			a.goBack()
 			return a, nil
-		case "q", "ctrl+c", "esc":
+		case key.Matches(msg, Keys.Forward):
+			a.goForward()
+			return a, nil
+		case key.Matches(msg, Keys.Quit):
 			return a, tea.Quit
 		}
 	}
@@ -47,19 +74,177 @@ func (a *App) updateViewEntryScreen(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return a, cmd
 }
 
-func (a *App) viewViewEntryScreen() string {
+// updateCopyPrompt handles the keys of the question what to copy: the whole
+// content or one of its code blocks, by number. A number is copied as soon as
+// no further digit could make it another block, otherwise on Enter.
+func (a *App) updateCopyPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if _, err := strconv.Atoi(msg.String()); err == nil && len(a.copyNumber) < 4 {
+		a.copyNumber += msg.String()
+		n, _ := strconv.Atoi(a.copyNumber)
+		if n*10 > len(data.CodeBlocks(a.currentEntry.Content)) {
+			a.copyingCode = false
+			a.copyCodeBlock(n - 1)
+		}
+		return a, nil
+	}
+
+	switch {
+	case key.Matches(msg, editorKeys.Backspace) && a.copyNumber != "":
+		a.copyNumber = a.copyNumber[:len(a.copyNumber)-1]
+		return a, nil
+	case key.Matches(msg, Keys.Confirm) && a.copyNumber != "":
+		n, _ := strconv.Atoi(a.copyNumber)
+		a.copyCodeBlock(n - 1)
+	case key.Matches(msg, Keys.Copy):
+		a.copyEntryContent()
+	case key.Matches(msg, Keys.ForceQuit):
+		return a, tea.Quit
+	}
+	a.copyingCode = false
+	return a, nil
+}
+
+func (a *App) copyEntryContent() {
+	err := clipboard.WriteAll(a.currentEntry.Content)
+	if err != nil {
+		a.errorMsg = "Failed to copy to clipboard: " + err.Error()
+	} else {
+		a.successMsg = "Entry content copied to clipboard."
+	}
+}
+
+// copyCodeBlock copies the code of the block numbered i, counting from 0
+func (a *App) copyCodeBlock(i int) {
+	blocks := data.CodeBlocks(a.currentEntry.Content)
+	if i < 0 || i >= len(blocks) {
+		a.errorMsg = fmt.Sprintf("There is no code block %d.", i+1)
+		return
+	}
+
+	err := clipboard.WriteAll(blocks[i].Code)
+	if err != nil {
+		a.errorMsg = "Failed to copy to clipboard: " + err.Error()
+	} else {
+		a.successMsg = fmt.Sprintf("Code block %d copied to clipboard.", i+1)
+	}
+}
+
+// codeBlockSummary describes a code block by its language and length
+func codeBlockSummary(block data.CodeBlock) string {
+	lines := "1 line"
+	if n := strings.Count(block.Code, "\n") + 1; n != 1 {
+		lines = fmt.Sprintf("%d lines", n)
+	}
+	if block.Language == "" {
+		return lines
+	}
+	return block.Language + ", " + lines
+}
+
+// copyPromptView lists the code blocks with the numbers that copy them
+func (a *App) copyPromptView() string {
+	var blocks []string
+	for i, block := range data.CodeBlocks(a.currentEntry.Content) {
+		blocks = append(blocks, Normal.Render(fmt.Sprintf("[%d]", i+1))+" "+Subtle.Render(codeBlockSummary(block)))
+	}
+	prompt := Subtitle.Render("Copy code block:") + " " + strings.Join(blocks, "  ")
+	if a.copyNumber != "" {
+		prompt = Subtitle.Render("Copy code block:") + " " + Normal.Render(a.copyNumber+"_") + "  " + strings.Join(blocks, "  ")
+	}
+	return ansi.Truncate(prompt, a.width-4, "…")
+}
 
-	a.entryViewport = viewport.New(a.width-6, a.height-16)
-	a.entryViewport.SetContent(a.currentEntry.Content)
+func (a *App) openEditEntryScreen() {
+	a.navigate(EditEntryScreen)
+	a.entryTitleInput = TextInputField(a.currentEntry.Title)
+	a.entryTitleInput.SetValue(a.currentEntry.Title)
+	a.entryTagsInput = TextInputField(a.currentEntry.Tags)
+	a.entryTagsInput.SetValue(a.currentEntry.Tags)
+	a.entryLangInput = TextInputField("e.g. go or shell")
+	a.entryLangInput.SetValue(a.currentEntry.Language)
+
+	a.entryContent = newEditor("Enter your content here...")
+	a.entryContent.SetValue(a.currentEntry.Content)
+	a.focusEntryField("title")
+
+	a.startEntryForm(a.currentEntry)
+}
 
+func (a *App) viewViewEntryScreen() string {
 	title := Title.Copy().Width(a.width - 4).Render(a.currentEntry.Title)
-	tags := Subtitle.Copy().Width(a.width - 4).Render("Tags: " + a.currentEntry.Tags)
+	tagsLine := "Tags: " + a.currentEntry.Tags
+	if language, detected := data.EntryLanguage(a.currentEntry.Language, a.currentEntry.Content); detected {
+		tagsLine += "  •  Language: " + language + " (detected)"
+	} else if language != "" {
+		tagsLine += "  •  Language: " + language
+	}
+	tags := Subtitle.Copy().Width(a.width - 4).Render(tagsLine)
 	date := Subtle.Copy().Width(a.width - 4).Render("Created on " + a.currentEntry.CreatedAt.Format("Jan 02, 2006"))
+	if n := len(a.currentEntry.Attachments); n == 1 {
+		date += "\n" + Subtle.Render("1 attachment")
+	} else if n > 1 {
+		date += "\n" + Subtle.Render(fmt.Sprintf("%d attachments", n))
+	}
+
+	help := HelpView(
+		Help("Scroll", Keys.Up, Keys.Down),
+		Help("Find", Keys.Find),
+		Help("Edit", Keys.Edit),
+		Help("Copy to clipboard", Keys.Copy),
+		Help("Attachments", Keys.Attachments),
+		Help("Back", Keys.Back),
+		a.forwardHelp(),
+		Help("Quit", Keys.Quit),
+	)
+
+	if a.copyingCode {
+		numbers := "1"
+		if n := len(data.CodeBlocks(a.currentEntry.Content)); n > 1 {
+			numbers = fmt.Sprintf("1-%d", n)
+		}
+		help = HelpView(
+			HelpKey{Description: "Copy code block", Keys: numbers, Key: "1"},
+			Help("Copy everything", Keys.Copy),
+			Help("Cancel", Keys.Cancel),
+		)
+		if a.copyNumber != "" {
+			help = HelpView(
+				Help("Copy code block "+a.copyNumber, Keys.Confirm),
+				HelpKey{Description: "Delete digit", Keys: keyName("backspace"), Key: "backspace"},
+				Help("Cancel", Keys.Cancel),
+			)
+		}
+	} else if a.search.typing {
+		help = HelpView(
+			Help("Done", Keys.Confirm),
+			Help("Clear search", Keys.Cancel),
+			Help("Match case", Keys.MatchCase),
+			Help("Regex", Keys.UseRegex),
+		)
+	} else if a.search.active() {
+		help = HelpView(
+			Help("Next/previous match", Keys.NextMatch, Keys.PreviousMatch),
+			Help("Find", Keys.Find),
+			Help("Clear search", Keys.Cancel),
+			Help("Edit", Keys.Edit),
+			Help("Back", Keys.Back),
+			Help("Quit", Keys.Quit),
+		)
+	}
+
+	// One line below the entry is kept for the scroll position, or the search
+	header := lipgloss.JoinVertical(lipgloss.Left, title, tags, date)
+	a.entryViewport.Width = a.width - 8
+	a.entryViewport.Height = max(a.contentHeight(header, help)-1, 3)
 
 	content := BoxStyle.Width(a.width - 4).Render(a.entryViewport.View())
 
 	scrollInfo := ""
-	if a.entryViewport.TotalLineCount() > a.entryViewport.Height {
+	if a.copyingCode {
+		scrollInfo = a.copyPromptView()
+	} else if a.search.active() {
+		scrollInfo = a.entrySearchView()
+	} else if a.entryViewport.TotalLineCount() > a.entryViewport.Height {
 		scrollPercent := 0
 		if a.entryViewport.TotalLineCount()-a.entryViewport.Height > 0 {
 			scrollPercent = int(float64(a.entryViewport.YOffset) / float64(a.entryViewport.TotalLineCount()-a.entryViewport.Height) * 100)
@@ -68,19 +253,9 @@ func (a *App) viewViewEntryScreen() string {
 			scrollPercent, a.entryViewport.YOffset+1, a.entryViewport.TotalLineCount()))
 	}
 
-	help := HelpView(map[string]string{
-		"↑/↓": "Scroll",
-		"e":   "Edit",
-		"c":   "Copy to clipboard",
-		"b":   "Back",
-		"q":   "Quit",
-	})
-
 	return fmt.Sprintf(
-		"%s\n%s\n%s\n\n%s\n%s\n\n%s",
-		title,
-		tags,
-		date,
+		"%s\n\n%s\n%s\n\n%s",
+		header,
 		content,
 		scrollInfo,
 		help,
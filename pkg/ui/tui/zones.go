@@ -0,0 +1,112 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Zones mark the parts of a frame that react to the mouse. markZone wraps a
+// part in two escape sequences that take no room on screen; once the frame
+// is complete, scanZones strips them and remembers where each zone was
+// drawn, so that clicks can be matched to zones by their name.
+
+// zoneBase keeps the marker numbers clear of real escape sequences
+const zoneBase = 5000
+
+var zoneMarker = regexp.MustCompile(`\x1b\[(\d+)z`)
+
+// zoneRect is where a zone was drawn, from the cell its first line starts at
+// to the last cell of its last line
+type zoneRect struct {
+	x0, y0, x1, y1 int
+}
+
+func (r zoneRect) contains(x, y int) bool {
+	return y >= r.y0 && y <= r.y1 && x >= r.x0 && x <= r.x1
+}
+
+var (
+	// zoneNames are the zones marked in the frame being drawn
+	zoneNames []string
+	// zones are the zones of the last frame drawn
+	zones = map[string]zoneRect{}
+)
+
+// markZone makes s the zone called name
+func markZone(name, s string) string {
+	id := len(zoneNames)
+	zoneNames = append(zoneNames, name)
+	return fmt.Sprintf("\x1b[%dz%s\x1b[%dz", zoneBase+id*2, s, zoneBase+id*2+1)
+}
+
+// scanZones strips the zone markers from frame and records where the zones
+// were drawn on a screen height lines high
+func scanZones(frame string, height int) string {
+	found := map[string]zoneRect{}
+	starts := map[int][2]int{}
+
+	lines := strings.Split(frame, "\n")
+	// Frames taller than the screen lose their first lines
+	top := 0
+	if height > 0 && len(lines) > height {
+		top = len(lines) - height
+	}
+	for i, line := range lines {
+		y := i - top
+		matches := zoneMarker.FindAllStringSubmatchIndex(line, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		var clean strings.Builder
+		last := 0
+		for _, match := range matches {
+			clean.WriteString(line[last:match[0]])
+			last = match[1]
+
+			n, _ := strconv.Atoi(line[match[2]:match[3]])
+			id := (n - zoneBase) / 2
+			if n < zoneBase || id >= len(zoneNames) {
+				continue
+			}
+			x := ansi.StringWidth(clean.String())
+			if (n-zoneBase)%2 == 0 {
+				starts[id] = [2]int{x, y}
+			} else if start, ok := starts[id]; ok {
+				found[zoneNames[id]] = zoneRect{x0: start[0], y0: start[1], x1: x - 1, y1: y}
+			}
+		}
+		clean.WriteString(line[last:])
+		lines[i] = clean.String()
+	}
+
+	zones = found
+	zoneNames = nil
+	return strings.Join(lines, "\n")
+}
+
+// zoneAt returns the name of the zone starting with prefix that contains the
+// cell x, y, along with the rest of its name
+func zoneAt(prefix string, x, y int) (string, bool) {
+	for name, rect := range zones {
+		if strings.HasPrefix(name, prefix) && rect.contains(x, y) {
+			return strings.TrimPrefix(name, prefix), true
+		}
+	}
+	return "", false
+}
+
+// zoneAtRow is like zoneAt but only looks at the lines a zone covers, for
+// zones that stretch across the screen
+func zoneAtRow(prefix string, y int) (string, bool) {
+	for name, rect := range zones {
+		if strings.HasPrefix(name, prefix) && y >= rect.y0 && y <= rect.y1 {
+			return strings.TrimPrefix(name, prefix), true
+		}
+	}
+	return "", false
+}